@@ -25,6 +25,7 @@ import (
 	log "github.com/superchalupa/sailfish/src/log"
 	applog "github.com/superchalupa/sailfish/src/log15adapter"
 
+	"github.com/superchalupa/sailfish/src/csdl"
 	"github.com/superchalupa/sailfish/src/http_redfish_sse"
 	"github.com/superchalupa/sailfish/src/http_sse"
 	domain "github.com/superchalupa/sailfish/src/redfishresource"
@@ -72,6 +73,8 @@ func main() {
 	// Defaults
 	cfgMgr.SetDefault("listen", []string{"https::8443"})
 	cfgMgr.SetDefault("main.server_name", "mockup")
+	cfgMgr.SetDefault("main.page_size", domain.DefaultPageSize)
+	cfgMgr.SetDefault("main.subscription_store", "subscriptions.json")
 
 	flag.Parse()
 
@@ -83,6 +86,19 @@ func main() {
 	// redo this later to observe events
 	//domainObjs.EventPublisher.AddObserver(logger)
 	domainObjs.CommandHandler = makeLoggingCmdHandler(logger, domainObjs.CommandHandler)
+	domainObjs.PageSize = cfgMgr.GetInt("main.page_size")
+
+	// validate request bodies against the same schemas we serve up
+	if schemas, err := csdl.LoadDir("./v1/schemas/"); err == nil {
+		domainObjs.Validator = schemas
+	} else {
+		logger.Warn("Could not load schemas, request bodies will not be validated", "err", err)
+	}
+
+	// fills in Message, Severity, and Resolution for anything that only has a MessageId
+	if err := domain.LoadMessageRegistries("./v1/registries/"); err != nil {
+		logger.Warn("Could not load all of the message registries", "err", err)
+	}
 
 	// This also initializes all of the plugins
 	domain.InitDomain(ctx, domainObjs.CommandHandler, domainObjs.EventBus, domainObjs.EventWaiter)
@@ -99,9 +115,9 @@ func main() {
 	// per spec: redirect /redfish/ to /redfish/v1
 	m.Path("/redfish/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/redfish/v1", 301) })
 
-	// some static files that we should generate at some point
-	m.Path("/redfish/v1/$metadata").HandlerFunc(func(w http.ResponseWriter, r *http.Request) { http.ServeFile(w, r, "v1/metadata.xml") })
-	m.Path("/redfish/v1/odata").HandlerFunc(func(w http.ResponseWriter, r *http.Request) { http.ServeFile(w, r, "v1/odata.json") })
+	// odata documents, generated from what is actually in the tree
+	m.Path("/redfish/v1/$metadata").Handler(domainObjs.MetadataHandler())
+	m.Path("/redfish/v1/odata").Handler(domainObjs.ODataHandler())
 
 	// generic handler for redfish output on most http verbs
 	// Note: this works by using the session service to get user details from token to pass up the stack using the embedded struct
@@ -0,0 +1,277 @@
+// Package csdl loads the Redfish CSDL schemas and validates request bodies against them.
+package csdl
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// raw xml layout, only the bits we need to validate requests
+type xmlEdmx struct {
+	Schemas []xmlSchema `xml:"DataServices>Schema"`
+}
+
+type xmlSchema struct {
+	Namespace       string          `xml:"Namespace,attr"`
+	EntityTypes     []xmlStructured `xml:"EntityType"`
+	ComplexTypes    []xmlStructured `xml:"ComplexType"`
+	EnumTypes       []xmlEnum       `xml:"EnumType"`
+	TypeDefinitions []xmlTypeDef    `xml:"TypeDefinition"`
+}
+
+type xmlStructured struct {
+	Name                 string          `xml:"Name,attr"`
+	BaseType             string          `xml:"BaseType,attr"`
+	Annotations          []xmlAnnotation `xml:"Annotation"`
+	Properties           []xmlProperty   `xml:"Property"`
+	NavigationProperties []xmlProperty   `xml:"NavigationProperty"`
+}
+
+type xmlProperty struct {
+	Name        string          `xml:"Name,attr"`
+	Type        string          `xml:"Type,attr"`
+	Nullable    string          `xml:"Nullable,attr"`
+	Annotations []xmlAnnotation `xml:"Annotation"`
+}
+
+type xmlAnnotation struct {
+	Term       string `xml:"Term,attr"`
+	EnumMember string `xml:"EnumMember,attr"`
+	Bool       string `xml:"Bool,attr"`
+}
+
+type xmlEnum struct {
+	Name    string `xml:"Name,attr"`
+	Members []struct {
+		Name string `xml:"Name,attr"`
+	} `xml:"Member"`
+}
+
+type xmlTypeDef struct {
+	Name           string `xml:"Name,attr"`
+	UnderlyingType string `xml:"UnderlyingType,attr"`
+}
+
+type propDef struct {
+	typ        string // qualified type name, possibly "Collection(...)"
+	nullable   bool
+	permission string // "Read", "ReadWrite", "Write", "None", or "" if not annotated
+	navigation bool
+}
+
+type typeDef struct {
+	baseType       string
+	entity         bool
+	additionalProp bool
+	props          map[string]*propDef
+}
+
+// Schemas is the set of CSDL types loaded from a schema directory
+type Schemas struct {
+	types    map[string]*typeDef // qualified name -> type
+	enums    map[string][]string // qualified name -> members
+	typeDefs map[string]string   // qualified name -> underlying type
+
+	// unversioned name ("Chassis.Links") -> every versioned qualified name, oldest first
+	versions map[string][]string
+}
+
+// LoadDir parses every CSDL xml file in the directory
+func LoadDir(dir string) (*Schemas, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.xml"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Schemas{
+		types:    map[string]*typeDef{},
+		enums:    map[string][]string{},
+		typeDefs: map[string]string{},
+		versions: map[string][]string{},
+	}
+
+	for _, fn := range files {
+		f, err := os.Open(fn)
+		if err != nil {
+			return nil, err
+		}
+		var doc xmlEdmx
+		err = xml.NewDecoder(f).Decode(&doc)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, schema := range doc.Schemas {
+			s.addSchema(schema)
+		}
+	}
+
+	for k, v := range s.versions {
+		sort.Slice(v, func(i, j int) bool { return versionLess(namespaceVersion(v[i]), namespaceVersion(v[j])) })
+		s.versions[k] = v
+	}
+
+	return s, nil
+}
+
+func (s *Schemas) addSchema(schema xmlSchema) {
+	add := func(t xmlStructured, entity bool) {
+		td := &typeDef{baseType: t.BaseType, entity: entity, props: map[string]*propDef{}}
+		for _, a := range t.Annotations {
+			if a.Term == "OData.AdditionalProperties" && a.Bool == "true" {
+				td.additionalProp = true
+			}
+			// property names that match a pattern, like HttpHeaders. The names aren't checked.
+			if a.Term == "Redfish.DynamicPropertyPatterns" {
+				td.additionalProp = true
+			}
+		}
+		for _, p := range t.Properties {
+			td.props[p.Name] = newPropDef(p, false)
+		}
+		for _, p := range t.NavigationProperties {
+			td.props[p.Name] = newPropDef(p, true)
+		}
+		s.addType(schema.Namespace, t.Name)
+		s.types[schema.Namespace+"."+t.Name] = td
+	}
+
+	for _, t := range schema.EntityTypes {
+		add(t, true)
+	}
+	for _, t := range schema.ComplexTypes {
+		add(t, false)
+	}
+	for _, e := range schema.EnumTypes {
+		members := make([]string, 0, len(e.Members))
+		for _, m := range e.Members {
+			members = append(members, m.Name) // preallocated
+		}
+		s.addType(schema.Namespace, e.Name)
+		s.enums[schema.Namespace+"."+e.Name] = members
+	}
+	for _, t := range schema.TypeDefinitions {
+		s.addType(schema.Namespace, t.Name)
+		s.typeDefs[schema.Namespace+"."+t.Name] = t.UnderlyingType
+	}
+}
+
+func (s *Schemas) addType(namespace, name string) {
+	unversioned := unversionedName(namespace + "." + name)
+	s.versions[unversioned] = append(s.versions[unversioned], namespace+"."+name)
+}
+
+func newPropDef(p xmlProperty, navigation bool) *propDef {
+	pd := &propDef{typ: p.Type, nullable: p.Nullable != "false", navigation: navigation}
+	for _, a := range p.Annotations {
+		if a.Term == "OData.Permissions" {
+			pd.permission = strings.TrimPrefix(a.EnumMember, "OData.Permission/")
+		}
+	}
+	return pd
+}
+
+// "Chassis.v1_0_0.Links" -> "Chassis.Links"
+func unversionedName(qualified string) string {
+	parts := strings.Split(qualified, ".")
+	if len(parts) == 3 && strings.HasPrefix(parts[1], "v") {
+		return parts[0] + "." + parts[2]
+	}
+	return qualified
+}
+
+// "Chassis.v1_2_0.Chassis" -> [1 2 0], unversioned names sort first
+func namespaceVersion(qualified string) []int {
+	parts := strings.Split(qualified, ".")
+	if len(parts) != 3 || !strings.HasPrefix(parts[1], "v") {
+		return nil
+	}
+	var ver []int
+	for _, n := range strings.Split(parts[1][1:], "_") {
+		i, _ := strconv.Atoi(n)
+		ver = append(ver, i)
+	}
+	return ver
+}
+
+func versionLess(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// entityType finds the type for an @odata.type, falling back to the newest
+// version we have if the schema files are older than the resource
+func (s *Schemas) entityType(odataType string) (string, bool) {
+	qualified := strings.TrimPrefix(odataType, "#")
+	if t, ok := s.types[qualified]; ok && t.entity {
+		return qualified, true
+	}
+	return s.newest(unversionedName(qualified))
+}
+
+func (s *Schemas) newest(unversioned string) (string, bool) {
+	v := s.versions[unversioned]
+	for i := len(v) - 1; i >= 0; i-- {
+		if t, ok := s.types[v[i]]; ok && t.entity {
+			return v[i], true
+		}
+	}
+	return "", false
+}
+
+// entityProps walks the BaseType chain of an entity type
+func (s *Schemas) entityProps(qualified string) (map[string]*propDef, bool) {
+	props := map[string]*propDef{}
+	additional := false
+	for t, ok := s.types[qualified]; ok; t, ok = s.types[t.baseType] {
+		for k, v := range t.props {
+			if _, seen := props[k]; !seen {
+				props[k] = v
+			}
+		}
+		additional = additional || t.additionalProp
+	}
+	return props, additional
+}
+
+// complexProps merges every version of a complex type. Entities refer to the
+// complex type version they were introduced with, but newer versions of the
+// entity can be populated with properties from newer versions of the complex type.
+func (s *Schemas) complexProps(qualified string) (map[string]*propDef, bool) {
+	versions := s.versions[unversionedName(qualified)]
+	if len(versions) == 0 {
+		versions = []string{qualified}
+	}
+	props := map[string]*propDef{}
+	additional := false
+	for i := len(versions) - 1; i >= 0; i-- {
+		p, a := s.entityProps(versions[i])
+		for k, v := range p {
+			if _, seen := props[k]; !seen {
+				props[k] = v
+			}
+		}
+		additional = additional || a
+	}
+	return props, additional
+}
+
+// enumMembers merges every version of an enum, for the same reasons as complexProps
+func (s *Schemas) enumMembers(qualified string) ([]string, bool) {
+	if _, ok := s.enums[qualified]; !ok {
+		return nil, false
+	}
+	members := []string{}
+	for _, v := range s.versions[unversionedName(qualified)] {
+		members = append(members, s.enums[v]...)
+	}
+	return members, true
+}
@@ -0,0 +1,50 @@
+package csdl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRequest(t *testing.T) {
+	s, err := LoadDir("../../v1/schemas")
+	assert.Nil(t, err)
+
+	var tests = []struct {
+		testname  string
+		method    string
+		odataType string
+		body      map[string]interface{}
+		expected  []string
+	}{
+		{"patch plugin overrides read only", "PATCH", "#Chassis.v1_0_2.Chassis", map[string]interface{}{"SKU": "1234"}, nil},
+		{"writable", "PATCH", "#Chassis.v1_6_0.Chassis", map[string]interface{}{"AssetTag": "foo"}, nil},
+		{"annotations ignored", "PATCH", "#Chassis.v1_6_0.Chassis", map[string]interface{}{"@odata.etag": "1", "AssetTag": "foo"}, nil},
+		{"patch plugin overrides unknown", "PATCH", "#Chassis.v1_6_0.Chassis", map[string]interface{}{"SledProfile": "foo"}, nil},
+		{"unknown", "PATCH", "#Chassis.v1_6_0.Chassis", map[string]interface{}{"Bogus": 1}, []string{"Base.1.0.PropertyUnknown"}},
+		{"read only", "PATCH", "#Chassis.v1_6_0.Chassis", map[string]interface{}{"SerialNumber": "1"}, []string{"Base.1.0.PropertyNotWritable"}},
+		{"type", "PATCH", "#Chassis.v1_6_0.Chassis", map[string]interface{}{"AssetTag": 42.0}, []string{"Base.1.0.PropertyValueTypeError"}},
+		{"enum", "PATCH", "#Chassis.v1_6_0.Chassis", map[string]interface{}{"IndicatorLED": "Purple"}, []string{"Base.1.0.PropertyValueNotInList"}},
+		{"enum ok", "PATCH", "#Chassis.v1_6_0.Chassis", map[string]interface{}{"IndicatorLED": "Lit"}, nil},
+		{"nested read only", "PATCH", "#Chassis.v1_6_0.Chassis", map[string]interface{}{"Status": map[string]interface{}{"Health": "OK"}}, []string{"Base.1.0.PropertyNotWritable"}},
+		{"oem is open", "PATCH", "#Chassis.v1_6_0.Chassis", map[string]interface{}{"Oem": map[string]interface{}{"Dell": map[string]interface{}{"Anything": 1}}}, nil},
+		{"newer than schema", "PATCH", "#Chassis.v1_99_0.Chassis", map[string]interface{}{"AssetTag": "foo"}, nil},
+		{"unknown type", "PATCH", "#Bogus.v1_0_0.Bogus", map[string]interface{}{"Bogus": 1}, nil},
+		{"post to collection", "POST", "#SessionCollection.SessionCollection", map[string]interface{}{"UserName": "root", "Password": "calvin"}, nil},
+		{"post unknown", "POST", "#SessionCollection.SessionCollection", map[string]interface{}{"Bogus": "root"}, []string{"Base.1.0.PropertyUnknown"}},
+		{"post subscription filters", "POST", "#EventDestinationCollection.EventDestinationCollection", map[string]interface{}{"Destination": "https://1.2.3.4", "RegistryPrefixes": []interface{}{"Base"}, "SubordinateResources": true, "EventFormatType": "MetricReport"}, nil},
+		{"post subscription headers", "POST", "#EventDestinationCollection.EventDestinationCollection", map[string]interface{}{"Destination": "https://1.2.3.4", "HttpHeaders": []interface{}{map[string]interface{}{"Authorization": "Bearer x"}}, "VerifyCertificate": true}, nil},
+		{"post certificate", "POST", "#CertificateCollection.CertificateCollection", map[string]interface{}{"CertificateString": "-----BEGIN CERTIFICATE-----", "CertificateType": "PEM"}, nil},
+		{"post certificate type", "POST", "#CertificateCollection.CertificateCollection", map[string]interface{}{"CertificateString": "-----BEGIN CERTIFICATE-----", "CertificateType": "DER"}, []string{"Base.1.0.PropertyValueNotInList"}},
+	}
+	for _, subtest := range tests {
+		t.Run(subtest.testname, func(t *testing.T) {
+			errs := s.ValidateRequest(subtest.method, subtest.odataType, subtest.body, func(path string) bool { return path == "SKU" || path == "SledProfile" })
+			var ids []string
+			for _, e := range errs {
+				ids = append(ids, e.MessageId)
+			}
+			assert.Equal(t, subtest.expected, ids)
+		})
+	}
+}
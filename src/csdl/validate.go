@@ -0,0 +1,180 @@
+package csdl
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+
+	domain "github.com/superchalupa/sailfish/src/redfishresource"
+)
+
+// ValidateRequest checks a PATCH, PUT, or POST body against the schema for the
+// resource type. POST bodies are checked against the member type of a
+// collection, and aren't checked for read only properties since those are
+// commonly settable on create. Types we don't have a schema for aren't checked.
+// Read only and unknown properties are allowed if patchable says the resource
+// implements them anyway.
+func (s *Schemas) ValidateRequest(method string, odataType string, body map[string]interface{}, patchable func(path string) bool) []domain.ExtendedInfo {
+	qualified, ok := s.entityType(odataType)
+	if !ok {
+		return nil
+	}
+
+	checkWritable := true
+	if method == "POST" {
+		members, ok := s.memberType(qualified)
+		if !ok {
+			// POST to something that isn't a collection: actions and such, nothing to check
+			return nil
+		}
+		qualified = members
+		checkWritable = false
+	}
+
+	props, additional := s.entityProps(qualified)
+	v := &validator{s: s, checkWritable: checkWritable, patchable: patchable}
+	v.validateObject("", props, additional, body)
+	return v.errs
+}
+
+// memberType returns the newest version of the type in Members for a collection
+func (s *Schemas) memberType(qualified string) (string, bool) {
+	props, _ := s.entityProps(qualified)
+	members, ok := props["Members"]
+	if !ok || !strings.HasPrefix(members.typ, "Collection(") {
+		return "", false
+	}
+	return s.newest(unversionedName(strings.TrimSuffix(strings.TrimPrefix(members.typ, "Collection("), ")")))
+}
+
+type validator struct {
+	s             *Schemas
+	checkWritable bool
+	patchable     func(path string) bool
+	errs          []domain.ExtendedInfo
+}
+
+func (v *validator) validateObject(path string, props map[string]*propDef, additional bool, obj map[string]interface{}) {
+	for name, val := range obj {
+		// annotations like @odata.id and @Redfish.SettingsApplyTime
+		if strings.Contains(name, "@") {
+			continue
+		}
+
+		p, ok := props[name]
+		if !ok {
+			// a PATCH plugin can take properties the schema doesn't have
+			if !additional && (v.patchable == nil || !v.patchable(path+name)) {
+				v.errs = append(v.errs, domain.PropertyUnknown(path+name))
+			}
+			continue
+		}
+
+		if v.checkWritable && p.permission == "Read" && (v.patchable == nil || !v.patchable(path+name)) {
+			v.errs = append(v.errs, domain.PropertyNotWritable(path+name))
+			continue
+		}
+
+		v.validateValue(path+name, p.typ, p.nullable, p.navigation, val)
+	}
+}
+
+func (v *validator) validateValue(name string, typ string, nullable bool, navigation bool, val interface{}) {
+	if val == nil {
+		if !nullable {
+			v.typeError(name, val)
+		}
+		return
+	}
+
+	if strings.HasPrefix(typ, "Collection(") {
+		arr, ok := val.([]interface{})
+		if !ok {
+			v.typeError(name, val)
+			return
+		}
+		typ = strings.TrimSuffix(strings.TrimPrefix(typ, "Collection("), ")")
+		for _, item := range arr {
+			v.validateValue(name, typ, true, navigation, item)
+		}
+		return
+	}
+
+	// references to other resources, just has to be an object
+	if navigation {
+		if _, ok := val.(map[string]interface{}); !ok {
+			v.typeError(name, val)
+		}
+		return
+	}
+
+	if underlying, ok := v.s.typeDefs[typ]; ok {
+		typ = underlying
+	}
+
+	if strings.HasPrefix(typ, "Edm.") {
+		if !primitiveOK(typ, val) {
+			v.typeError(name, val)
+		}
+		return
+	}
+
+	if members, ok := v.s.enumMembers(typ); ok {
+		str, ok := val.(string)
+		if !ok {
+			v.typeError(name, val)
+			return
+		}
+		for _, m := range members {
+			if m == str {
+				return
+			}
+		}
+		v.errs = append(v.errs, domain.PropertyValueNotInList(str, name))
+		return
+	}
+
+	if _, ok := v.s.types[typ]; ok {
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			v.typeError(name, val)
+			return
+		}
+		props, additional := v.s.complexProps(typ)
+		v.validateObject(name+"/", props, additional, obj)
+	}
+
+	// anything else is a type we don't know about, let it through
+}
+
+func (v *validator) typeError(name string, val interface{}) {
+	b, _ := json.Marshal(val)
+	v.errs = append(v.errs, domain.PropertyValueTypeError(string(b), name))
+}
+
+func primitiveOK(typ string, val interface{}) bool {
+	switch typ {
+	case "Edm.String", "Edm.DateTimeOffset", "Edm.Duration", "Edm.TimeOfDay", "Edm.Date", "Edm.Guid":
+		_, ok := val.(string)
+		return ok
+	case "Edm.Boolean":
+		_, ok := val.(bool)
+		return ok
+	case "Edm.Int64", "Edm.Int32", "Edm.Int16", "Edm.Byte", "Edm.SByte":
+		switch n := val.(type) {
+		case float64:
+			return n == math.Trunc(n)
+		case int, int64, int32:
+			return true
+		}
+		return false
+	case "Edm.Decimal", "Edm.Double", "Edm.Single":
+		switch val.(type) {
+		case float64, float32, int, int64, int32:
+			return true
+		}
+		return false
+	}
+	// Edm.PrimitiveType and friends
+	return true
+}
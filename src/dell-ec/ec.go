@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"strings"
 	"sync"
 
 	"github.com/spf13/viper"
@@ -109,7 +110,7 @@ func New(ctx context.Context, logger log.Logger, cfgMgr *viper.Viper, cfgMgrMu *
 	inithealth(ctx, logger, ch, d)
 	stdmeta.InitializeSsoinfo(d)
 	telemetryservice.RegisterAggregate(instantiateSvc)
-	telemetryservice.New(ctx, logger, ch, d)
+	telemetryservice.New(ctx, logger, cfgMgr, cfgMgrMu, ch, d)
 
 	stdmeta.SetupSledProfilePlugin(d)
 	stdmeta.InitializeCertInfo(d)
@@ -194,12 +195,42 @@ func New(ctx context.Context, logger log.Logger, cfgMgr *viper.Viper, cfgMgrMu *
 	//*********************************************************************
 	// /redfish/v1/Registries
 	//*********************************************************************
-	for regName, location := range map[string]interface{}{
-		"idrac_registry":    []map[string]string{{"Language": "En", "Uri": "/redfish/v1/Registries/Messages/EEMIRegistry.v1_5_0.json"}},
-		"base_registry":     []map[string]string{{"Language": "En", "Uri": "/redfish/v1/Registries/BaseMessages/BaseRegistry.v1_0_0.json", "PublicationUri": "http://www.dmtf.org/sites/default/files/standards/documents/DSP8011_1.0.0a.json"}},
-		"mgr_attr_registry": []map[string]string{{"Language": "En", "Uri": "/redfish/v1/Registries/ManagerAttributeRegistry/ManagerAttributeRegistry.v1_0_0.json"}},
-	} {
-		instantiateSvc.Instantiate(regName, map[string]interface{}{"location": location})
+	instantiateSvc.Instantiate("mgr_attr_registry", map[string]interface{}{
+		"location": []map[string]string{{"Language": "En", "Uri": "/redfish/v1/Registries/ManagerAttributeRegistry/ManagerAttributeRegistry.v1_0_0.json"}},
+	})
+
+	// the message registries are whatever versions the message registry engine loaded
+	for _, reg := range domain.MessageRegistries() {
+		id := reg.RegistryPrefix
+		// where they have always been
+		switch reg.RegistryPrefix {
+		case "Base":
+			id = "BaseMessages"
+		case "iDRAC":
+			id = "Messages"
+		}
+		version := strings.Split(reg.RegistryVersion+".0.0", ".")
+		uri := "/redfish/v1/Registries/" + id + "/" + reg.Id
+		location := map[string]string{"Language": "En", "Uri": uri}
+		if reg.OwningEntity == "DMTF" {
+			location["PublicationUri"] = "http://redfish.dmtf.org/registries/" + reg.Id + ".json"
+		}
+		instantiateSvc.Instantiate("message_registry", map[string]interface{}{
+			"id":           id,
+			"name":         reg.Name,
+			"description":  reg.Description,
+			"type":         reg.RegistryPrefix + "." + version[0] + "." + version[1],
+			"location":     []map[string]string{location},
+			"location_uri": uri,
+			"registry":     reg,
+		})
+	}
+
+	//*********************************************************************
+	// /redfish/v1/TelemetryService/MetricDefinitions
+	//*********************************************************************
+	for _, md := range telemetryservice.SensorMetricDefinitions {
+		instantiateSvc.Instantiate("metric_definition", map[string]interface{}{"id": md.Id, "definition": md})
 	}
 
 	_, updSvcVw, _ := instantiateSvc.Instantiate("update_service", map[string]interface{}{})
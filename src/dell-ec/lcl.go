@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"path"
 	"sort"
 	"strconv"
@@ -76,6 +77,10 @@ func initLCL(logger log.Logger, instantiateSvc *testaggregate.Service, ch eh.Com
 		createdTime := time.Unix(int64(timeF), 0)
 		cTime := createdTime.Format("2006-01-02T15:04:05-07:00")
 
+		// some entries only come with the MessageID and args, LogEntry doesn't have a Resolution
+		var resolution string
+		domain.FillMessage(logEntry.MessageID, logEntry.MessageArgs, &logEntry.Message, &logEntry.Severity, &resolution)
+
 		severity := logEntry.Severity
 		if logEntry.Severity == "Informational" {
 			severity = "OK"
@@ -239,6 +244,9 @@ func initLCL(logger log.Logger, instantiateSvc *testaggregate.Service, ch eh.Com
 		createdTime := time.Unix(int64(timeF), 0)
 		cTime := createdTime.Format("2006-01-02T15:04:05-07:00")
 
+		var resolution string
+		domain.FillMessage(faultEntry.MessageID, faultEntry.MessageArgs, &faultEntry.Message, &faultEntry.Severity, &resolution)
+
 		uuid := eh.NewUUID()
 		uri := fmt.Sprintf("%s/%s", logUri, faultEntry.Name)
 		//fmt.Printf("%s/%s", logUri, faultEntry.Name)
@@ -263,6 +271,7 @@ func initLCL(logger log.Logger, instantiateSvc *testaggregate.Service, ch eh.Com
 				Headers: map[string]string{
 					"Location": uri,
 				},
+				Deletable: true,
 				Privileges: map[string]interface{}{
 					"GET":    []string{"Login"},
 					"DELETE": []string{"ConfigureManager"},
@@ -626,6 +635,7 @@ var _ = eh.Command(&FaultDELETE{})
 type FaultDELETE struct {
 	ID    eh.UUID `json:"id"`
 	CmdID eh.UUID `json:"cmdid"`
+	auth  *domain.RedfishAuthorizationProperty
 }
 
 type RequestFaultRemoveData struct {
@@ -639,12 +649,23 @@ func (c *FaultDELETE) AggregateID() eh.UUID            { return c.ID }
 func (c *FaultDELETE) CommandType() eh.CommandType     { return FaultDELETECommand }
 func (c *FaultDELETE) SetAggID(id eh.UUID)             { c.ID = id }
 func (c *FaultDELETE) SetCmdID(id eh.UUID)             { c.CmdID = id }
+func (c *FaultDELETE) SetUserDetails(a *domain.RedfishAuthorizationProperty) string {
+	c.auth = a
+	return "checkMaster"
+}
 func (c *FaultDELETE) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) error {
-	// TODO: "Services may return a representation of the just deleted resource in the response body."
-	// - can create a new CMD for GET with an identical CMD ID. Is that cheating?
-	// TODO: return http 405 status for undeletable objects. right now we use privileges
+	if msg := domain.VetoDelete(ctx, a); msg != nil {
+		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, &domain.HTTPCmdProcessedData{
+			CommandID:  c.CmdID,
+			Results:    domain.ErrorResponse(*msg),
+			StatusCode: http.StatusConflict,
+			Headers:    map[string]string{},
+		}, time.Now()))
+		return nil
+	}
 
-	//data.Results, _ = ProcessDELETE(ctx, a.Properties, c.Body)
+	// respond with the fault as it was before it goes away
+	data := domain.DeleteResponse(ctx, a, c.CmdID, c.auth)
 
 	faultID := ""
 	// send event to trigger delete
@@ -660,11 +681,6 @@ func (c *FaultDELETE) Handle(ctx context.Context, a *domain.RedfishResourceAggre
 	}, time.Now()))
 
 	// send http response
-	a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, &domain.HTTPCmdProcessedData{
-		CommandID:  c.CmdID,
-		Results:    map[string]interface{}{},
-		StatusCode: 200,
-		Headers:    map[string]string{},
-	}, time.Now()))
+	a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, data, time.Now()))
 	return nil
 }
@@ -55,6 +55,8 @@ func RegisterAggregate(s *testaggregate.Service) {
 					ResourceURI: vw.GetURI(),
 					Type:        "#DellSlot.v1_0_0.DellSlot",
 					Context:     params["rooturi"].(string) + "/$metadata#DellSlot.DellSlot",
+					// more than one console can manage the sled profile, make them prove they saw the latest one
+					RequireIfMatch: strings.Contains(params["FQDD"].(string), "SledSlot"),
 					Privileges: map[string]interface{}{
 						"GET":   []string{"Login"},
 						"PATCH": []string{"ConfigureManager"},
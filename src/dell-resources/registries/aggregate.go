@@ -2,6 +2,7 @@ package registries
 
 import (
 	"context"
+	"errors"
 	"sync"
 
 	eh "github.com/looplab/eventhorizon"
@@ -61,4 +62,71 @@ func RegisterAggregate(s *testaggregate.Service) {
 					}},
 			}, nil
 		})
+	// message_registry is a registry loaded by the message registry engine. It
+	// has the file entry, and the registry itself at the Location.
+	s.RegisterAggregateFunction("message_registry",
+		func(ctx context.Context, subLogger log.Logger, cfgMgr *viper.Viper, cfgMgrMu *sync.RWMutex, vw *view.View, extra interface{}, params map[string]interface{}) ([]eh.Command, error) {
+			reg, ok := params["registry"].(*domain.MessageRegistry)
+			if !ok {
+				return nil, errors.New("message_registry needs a 'registry' parameter")
+			}
+			location, ok := params["location_uri"].(string)
+			if !ok {
+				return nil, errors.New("message_registry needs a 'location_uri' parameter")
+			}
+
+			messages := map[string]interface{}{}
+			for key, msg := range reg.Messages {
+				m := map[string]interface{}{
+					"Description":  msg.Description,
+					"Message":      msg.Message,
+					"Severity":     msg.Severity,
+					"NumberOfArgs": msg.NumberOfArgs,
+					"Resolution":   msg.Resolution,
+				}
+				if len(msg.ParamTypes) > 0 {
+					m["ParamTypes"] = msg.ParamTypes
+				}
+				messages[key] = m
+			}
+
+			return []eh.Command{
+				&domain.CreateRedfishResource{
+					ID:          vw.GetUUID(),
+					ResourceURI: vw.GetURI(),
+					Type:        "#MessageRegistryFile.v1_0_2.MessageRegistryFile",
+					Context:     "/redfish/v1/$metadata#MessageRegistryFile.MessageRegistryFile",
+					Privileges: map[string]interface{}{
+						"GET": []string{"Login"},
+					},
+					Properties: map[string]interface{}{
+						"Id@meta":                    vw.Meta(view.GETProperty("id"), view.GETModel("default")),
+						"Name@meta":                  vw.Meta(view.GETProperty("name"), view.GETModel("default")),
+						"Description@meta":           vw.Meta(view.GETProperty("description"), view.GETModel("default")),
+						"Registry@meta":              vw.Meta(view.GETProperty("type"), view.GETModel("default")),
+						"Languages@meta":             vw.Meta(view.GETProperty("languages"), view.GETModel("default")),
+						"Languages@odata.count@meta": vw.Meta(view.GETProperty("languages"), view.GETFormatter("count"), view.GETModel("default")),
+						"Location@meta":              vw.Meta(view.GETProperty("location"), view.GETModel("default")),
+						"Location@odata.count@meta":  vw.Meta(view.GETProperty("location"), view.GETFormatter("count"), view.GETModel("default")),
+					}},
+				&domain.CreateRedfishResource{
+					ID:          eh.NewUUID(),
+					ResourceURI: location,
+					Type:        "#MessageRegistry.v1_0_2.MessageRegistry",
+					Context:     "/redfish/v1/$metadata#MessageRegistry.MessageRegistry",
+					Privileges: map[string]interface{}{
+						"GET": []string{"Login"},
+					},
+					Properties: map[string]interface{}{
+						"Id":              reg.Id,
+						"Name":            reg.Name,
+						"Language":        reg.Language,
+						"Description":     reg.Description,
+						"RegistryPrefix":  reg.RegistryPrefix,
+						"RegistryVersion": reg.RegistryVersion,
+						"OwningEntity":    reg.OwningEntity,
+						"Messages":        messages,
+					}},
+			}, nil
+		})
 }
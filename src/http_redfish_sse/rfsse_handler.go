@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	eh "github.com/looplab/eventhorizon"
 	log "github.com/superchalupa/sailfish/src/log"
@@ -33,13 +34,21 @@ func (rh *RedfishSSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		requestLogger.Crit("Streaming is not supported by the underlying http handler.")
-		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		domain.WriteErrorResponse(w, http.StatusInternalServerError, domain.InternalError())
 		return
 	}
 
 	rfSubContext := r.URL.Query().Get("context")
+	rfFilter := r.URL.Query().Get("$filter")
 
-	requestLogger.Info("Trying to start RedfishSSE Stream for request.", "context", rfSubContext)
+	sseFilter, err := eventservice.ParseSSEFilter(rfFilter)
+	if err != nil {
+		requestLogger.Info("Bad $filter for RedfishSSE Stream.", "filter", rfFilter, "err", err)
+		domain.WriteErrorResponse(w, http.StatusBadRequest, domain.QueryParameterValueFormatError(rfFilter, "$filter"))
+		return
+	}
+
+	requestLogger.Info("Trying to start RedfishSSE Stream for request.", "context", rfSubContext, "filter", rfFilter)
 
 	l, err := rh.d.EventWaiter.Listen(ctx, func(event eh.Event) bool {
 		if event.EventType() == eventservice.ExternalRedfishEvent || event.EventType() == eventservice.ExternalMetricEvent {
@@ -49,7 +58,7 @@ func (rh *RedfishSSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	})
 	if err != nil {
 		requestLogger.Crit("Could not create an event waiter.", "err", err)
-		http.Error(w, "could not create waiter"+err.Error(), http.StatusInternalServerError)
+		domain.WriteErrorResponse(w, http.StatusInternalServerError, domain.InternalError())
 		return
 	}
 
@@ -87,54 +96,115 @@ func (rh *RedfishSSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	sub := eventservice.Subscription{
 		Protocol:    "SSE",
 		Destination: "",
-		EventTypes:  []string{"ResourceUpdated", "ResourceAdded", "ResourceRemoved", "Alert", "StateChanged"},
+		EventTypes:  []string{"StatusChange", "ResourceUpdated", "ResourceAdded", "ResourceRemoved", "Alert", "StateChanged"},
 		Context:     rfSubContext,
 	}
 	sseContext, cancel := context.WithCancel(ctx)
 	view := eventservice.GlobalEventService.CreateSubscription(sseContext, requestLogger, sub, cancel)
 	_ = view
 
-	for {
-		event, err := l.Wait(sseContext)
-		if err != nil {
-			requestLogger.Warn("Context was cancelled.", "err", err)
-			break
-		}
+	filter := sub.Filter()
+	resourceType := func(uri string) string { return rh.d.GetResourceType(sseContext, uri) }
 
-		if evt, ok := event.Data().(*eventservice.ExternalRedfishEventData); ok {
+	// write sends an event or metric report to the client, unless the filters say to skip it
+	write := func(data eh.EventData) error {
+		if evt, ok := data.(*eventservice.ExternalRedfishEventData); ok {
 			// Handle redfish events
-			d, err := json.MarshalIndent(
-				&struct {
-					*eventservice.ExternalRedfishEventData
-					Context string `json:",omitempty"`
-				}{
-					ExternalRedfishEventData: evt,
-					Context:                  rfSubContext,
-				},
-				"data: ", "    ",
-			)
-
+			if !filter.WantsEvents() {
+				return nil
+			}
+			// the event data is shared with the other subscribers, so filter a copy
+			filtered := *evt
+			filtered.Events = sseFilter.FilterEvents(filter.FilterEvents(evt.Events, resourceType), resourceType)
+			if len(filtered.Events) == 0 {
+				return nil
+			}
+			d, err := marshalEvent(&filtered, rfSubContext)
 			if err != nil {
-				requestLogger.Error("MARSHAL SSE (event) FAILED", "err", err, "data", event.Data(), "event", event)
-				return
+				requestLogger.Error("MARSHAL SSE (event) FAILED", "err", err, "data", data)
+				return err
 			}
 			// TODO: we should encode to output rather than buffering internally in a string
 			fmt.Fprintf(w, "id: %d\n", evt.Id)
 			fmt.Fprintf(w, "data: %s\n\n", d)
-		} else if evt, ok := event.Data().(eventservice.MetricReportData); ok {
+		} else if evt, ok := data.(eventservice.MetricReportData); ok {
 			// Handle metric reports
+			if !filter.WantsMetricReports() || !sseFilter.MatchMetricReport(evt.Data) {
+				return nil
+			}
 			// TODO: find a better way to unify these
 			// sucks that we have to handle these two separately, but for now have to do it this way
 			d, err := json.MarshalIndent(evt.Data, "data: ", "    ")
 			if err != nil {
-				requestLogger.Error("MARSHAL SSE (metric report) FAILED", "err", err, "data", event.Data(), "event", event)
-				return
+				requestLogger.Error("MARSHAL SSE (metric report) FAILED", "err", err, "data", data)
+				return err
 			}
 			fmt.Fprintf(w, "data: %s\n\n", d)
 		}
 
 		flusher.Flush()
+		return nil
+	}
+
+	// a client that reconnects gets what it missed, as long as it's still around
+	replayed := map[interface{}]bool{}
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		id, err := strconv.Atoi(lastEventID)
+		if err != nil || id < 0 {
+			// can't tell what was missed, so send everything
+			id = -1
+		}
+		missed, gap := eventservice.GlobalEventService.Replay(id)
+		requestLogger.Info("Replaying events for RedfishSSE Stream.", "Last-Event-ID", lastEventID, "count", len(missed), "gap", gap)
+		if gap || id < 0 {
+			// no id line, so the client keeps the Last-Event-ID it has
+			d, err := marshalEvent(eventservice.EventBufferExceeded(), rfSubContext)
+			if err != nil {
+				requestLogger.Error("MARSHAL SSE (event) FAILED", "err", err)
+				return
+			}
+			fmt.Fprintf(w, "data: %s\n\n", d)
+			flusher.Flush()
+		}
+		for _, data := range missed {
+			replayed[eventservice.ReplayKey(data)] = true
+			if write(data) != nil {
+				return
+			}
+		}
+	}
+
+	for {
+		event, err := l.Wait(sseContext)
+		if err != nil {
+			requestLogger.Warn("Context was cancelled.", "err", err)
+			break
+		}
+
+		// the listener was started before the replay, so some events could come both ways
+		if key := eventservice.ReplayKey(event.Data()); replayed[key] {
+			delete(replayed, key)
+			continue
+		}
+
+		if write(event.Data()) != nil {
+			return
+		}
 	}
 
 	requestLogger.Debug("Closed session")
 }
+
+// marshalEvent formats an event for the stream, with the context for this client
+func marshalEvent(evt *eventservice.ExternalRedfishEventData, subContext string) ([]byte, error) {
+	return json.MarshalIndent(
+		&struct {
+			*eventservice.ExternalRedfishEventData
+			Context string `json:",omitempty"`
+		}{
+			ExternalRedfishEventData: evt,
+			Context:                  subContext,
+		},
+		"data: ", "    ",
+	)
+}
@@ -0,0 +1,126 @@
+package http_redfish_sse
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+	eventpublisher "github.com/looplab/eventhorizon/publisher/local"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/superchalupa/sailfish/src/log"
+	"github.com/superchalupa/sailfish/src/log15adapter"
+	"github.com/superchalupa/sailfish/src/looplab/eventbus"
+	"github.com/superchalupa/sailfish/src/looplab/eventwaiter"
+	"github.com/superchalupa/sailfish/src/ocp/eventservice"
+	"github.com/superchalupa/sailfish/src/ocp/testaggregate"
+	domain "github.com/superchalupa/sailfish/src/redfishresource"
+)
+
+func init() {
+	log15adapter.InitializeApplicationLogging("")
+}
+
+// just enough config for the event service to keep a one event replay buffer
+const testConfig = `
+main:
+    subscription_store: ""
+views:
+  "eventservice":
+      "Models":
+        "default": {"sse_replay_buffer_size": "1"}
+  "subscription":
+      "Models":
+        "default": {"destination": "destination", "protocol": "protocol", "context": "context"}
+`
+
+var (
+	startOnce  sync.Once
+	testDomain *domain.DomainObjects
+)
+
+// startEventService runs the real event service on a bus of its own. It
+// registers commands, so there can only be one per test binary.
+func startEventService(t *testing.T) *domain.DomainObjects {
+	startOnce.Do(func() {
+		d := &domain.DomainObjects{
+			EventBus:       eventbus.NewEventBus(),
+			EventPublisher: eventpublisher.NewEventPublisher(),
+			EventWaiter:    eventwaiter.NewEventWaiter(eventwaiter.NoAutoRun),
+			// nothing in the config makes resources
+			CommandHandler: eh.CommandHandlerFunc(func(context.Context, eh.Command) error { return nil }),
+		}
+		d.EventBus.AddHandler(eh.MatchAny(), d.EventPublisher)
+		d.EventPublisher.AddObserver(d.EventWaiter)
+		go d.EventWaiter.Run()
+
+		cfg := viper.New()
+		cfg.SetConfigType("yaml")
+		assert.Nil(t, cfg.ReadConfig(strings.NewReader(testConfig)))
+		cfgMu := &sync.RWMutex{}
+		logger := log.MustLogger("test")
+		instantiateSvc := testaggregate.New(context.Background(), logger, cfg, cfgMu, d.CommandHandler)
+		es := eventservice.New(context.Background(), cfg, cfgMu, d, instantiateSvc, nil, nil)
+		es.StartEventService(context.Background(), logger, instantiateSvc, map[string]interface{}{"rooturi": "/redfish/v1"})
+		testDomain = d
+	})
+	return testDomain
+}
+
+func TestReplayGapMarker(t *testing.T) {
+	d := startEventService(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the client saw 5, and 6 has already been pushed out of the buffer by 7
+	for _, id := range []int{6, 7} {
+		d.EventBus.PublishEvent(ctx, eh.NewEvent(eventservice.ExternalRedfishEvent, &eventservice.ExternalRedfishEventData{
+			Id:     id,
+			Events: []*eventservice.RedfishEventData{{EventType: "Alert", MessageId: "Base.1.0.Success"}},
+		}, time.Now()))
+	}
+	for {
+		if missed, gap := eventservice.GlobalEventService.Replay(5); gap && len(missed) == 1 {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatal("events never made it to the replay buffer")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rh := NewRedfishSSEHandler(d, log.MustLogger("test"), "root", []string{"Login"})
+	srv := httptest.NewServer(rh)
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/redfish/v1/SSE", nil)
+	req.Header.Set("Last-Event-ID", "5")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	marker := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, `"MessageId": "Sailfish.1.0.EventBufferExceeded"`) {
+			marker = true
+		}
+		if strings.HasPrefix(line, "id:") {
+			assert.True(t, marker, "the client is told about the events it missed first")
+			assert.Equal(t, "id: 7", line, "the marker doesn't move the client's Last-Event-ID")
+			break
+		}
+	}
+	assert.True(t, marker)
+}
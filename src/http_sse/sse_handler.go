@@ -34,7 +34,7 @@ func (rh *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		requestLogger.Crit("Streaming is not supported by the underlying http handler.")
-		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		domain.WriteErrorResponse(w, http.StatusInternalServerError, domain.InternalError())
 		return
 	}
 
@@ -45,7 +45,7 @@ func (rh *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	})
 	if err != nil {
 		requestLogger.Crit("Could not create an event waiter.", "err", err)
-		http.Error(w, "could not create waiter"+err.Error(), http.StatusInternalServerError)
+		domain.WriteErrorResponse(w, http.StatusInternalServerError, domain.InternalError())
 		return
 	}
 
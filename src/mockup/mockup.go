@@ -103,6 +103,13 @@ func New(ctx context.Context, logger log.Logger, cfgMgr *viper.Viper, cfgMgrMu *
 	//*********************************************************************
 	stdcollections.AddStandardRoles(ctx, rootView.GetUUID(), rootView.GetURI(), ch)
 
+	//*********************************************************************
+	// /redfish/v1/TelemetryService/MetricDefinitions
+	//*********************************************************************
+	for _, md := range telemetryservice.SensorMetricDefinitions {
+		instantiateSvc.Instantiate("metric_definition", map[string]interface{}{"id": md.Id, "definition": md})
+	}
+
 	//*********************************************************************
 	// /redfish/v1/Sessions
 	//*********************************************************************
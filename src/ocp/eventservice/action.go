@@ -4,16 +4,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	eh "github.com/looplab/eventhorizon"
-	"github.com/mitchellh/mapstructure"
 
 	ah "github.com/superchalupa/sailfish/src/actionhandler"
 	domain "github.com/superchalupa/sailfish/src/redfishresource"
 )
 
-func MakeSubmitTestEvent(eb eh.EventBus) func(context.Context, eh.Event, *domain.HTTPCmdProcessedData) error {
+const submitTestEventAction = "EventService.SubmitTestEvent"
+
+// testEventSeverities are the Severity values a test event can have
+var testEventSeverities = []string{"OK", "Warning", "Critical"}
+
+// MakeSubmitTestEvent sends the test event through the same filtering and
+// delivery as any other event. matched says how many subscriptions will get it.
+func MakeSubmitTestEvent(eb eh.EventBus, matched func(context.Context, *RedfishEventData) int) func(context.Context, eh.Event, *domain.HTTPCmdProcessedData) error {
 	return func(ctx context.Context, event eh.Event, retData *domain.HTTPCmdProcessedData) error {
 		domain.ContextLogger(ctx, "submit_event").Debug("got test event", "event_data", event.Data())
 
@@ -23,14 +31,157 @@ func MakeSubmitTestEvent(eb eh.EventBus) func(context.Context, eh.Event, *domain
 			return errors.New("Didnt get the right kind of event")
 		}
 
-		redfishEvent := &RedfishEventData{}
-		mapstructure.Decode(data.ActionData, redfishEvent)
+		redfishEvent, errs := parseTestEvent(data.ActionData, time.Now())
+		if len(errs) > 0 {
+			retData.Results = domain.ErrorResponse(errs...)
+			retData.StatusCode = 400
+			return nil
+		}
+
+		// count and copy first, the event can be changed once it is on the bus
+		results := testEventResults(redfishEvent, matched(ctx, redfishEvent))
 
 		// need to publish here.
 		responseEvent := eh.NewEvent(RedfishEvent, redfishEvent, time.Now())
 		eb.PublishEvent(ctx, responseEvent)
 
-		retData.Results = redfishEvent
+		retData.Results = results
+		retData.StatusCode = 200
+		return nil
+	}
+}
+
+// testEventResults is the SubmitTestEvent response: the event that was sent,
+// with how many subscriptions it went to in the sailfish Oem
+func testEventResults(evt *RedfishEventData, count int) *RedfishEventData {
+	results := *evt
+	results.Oem = map[string]interface{}{}
+	for k, v := range evt.Oem {
+		results.Oem[k] = v
+	}
+	sailfish := map[string]interface{}{}
+	if m, ok := evt.Oem["sailfish"].(map[string]interface{}); ok {
+		for k, v := range m {
+			sailfish[k] = v
+		}
+	}
+	sailfish["SubscriptionsMatched"] = count
+	results.Oem["sailfish"] = sailfish
+	return &results
+}
+
+// parseTestEvent checks the SubmitTestEvent parameters and fills in the ones
+// that were left out: Message, Severity from the registry, then the defaults.
+func parseTestEvent(body interface{}, now time.Time) (*RedfishEventData, []domain.ExtendedInfo) {
+	params, ok := body.(map[string]interface{})
+	if body == nil {
+		params, ok = map[string]interface{}{}, true
+	}
+	if !ok {
+		return nil, []domain.ExtendedInfo{domain.UnrecognizedRequestBody()}
+	}
+
+	evt := &RedfishEventData{}
+	errs := []domain.ExtendedInfo{}
+	strParam := func(name string, value interface{}, field *string) {
+		if s, ok := value.(string); ok {
+			*field = s
+		} else {
+			errs = append(errs, domain.ActionParameterValueTypeError(fmt.Sprint(value), name, submitTestEventAction))
+		}
+	}
+
+	for name, value := range params {
+		switch name {
+		case "EventType":
+			strParam(name, value, &evt.EventType)
+		case "EventId":
+			strParam(name, value, &evt.EventId)
+		case "EventTimestamp":
+			strParam(name, value, &evt.EventTimestamp)
+		case "Severity":
+			strParam(name, value, &evt.Severity)
+		case "Message":
+			strParam(name, value, &evt.Message)
+		case "MessageId":
+			strParam(name, value, &evt.MessageId)
+		case "MessageArgs":
+			args, ok := value.([]interface{})
+			if !ok {
+				errs = append(errs, domain.ActionParameterValueTypeError(fmt.Sprint(value), name, submitTestEventAction))
+				continue
+			}
+			evt.MessageArgs = []string{}
+			for i, arg := range args {
+				if s, ok := arg.(string); ok {
+					evt.MessageArgs = append(evt.MessageArgs, s)
+				} else {
+					errs = append(errs, domain.ActionParameterValueTypeError(fmt.Sprint(arg), name+"/"+strconv.Itoa(i), submitTestEventAction))
+				}
+			}
+		case "OriginOfCondition":
+			// either the uri, or a link to it like everywhere else
+			if link, ok := value.(map[string]interface{}); ok {
+				value = link["@odata.id"]
+			}
+			strParam(name, value, &evt.OriginOfCondition)
+		default:
+			errs = append(errs, domain.ActionParameterUnknown(submitTestEventAction, name))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if evt.EventType == "" {
+		evt.EventType = "Alert"
+	}
+	if !contains(EventTypesForSubscription, evt.EventType) {
+		errs = append(errs, domain.PropertyValueNotInList(evt.EventType, "EventType"))
+	}
+
+	if evt.EventTimestamp == "" {
+		evt.EventTimestamp = now.UTC().Format(time.RFC3339)
+	} else if _, err := time.Parse(time.RFC3339, evt.EventTimestamp); err != nil {
+		errs = append(errs, domain.ActionParameterValueFormatError(evt.EventTimestamp, "EventTimestamp", submitTestEventAction))
+	}
+
+	if evt.OriginOfCondition != "" && !strings.HasPrefix(evt.OriginOfCondition, "/redfish/v1") {
+		errs = append(errs, domain.ActionParameterValueFormatError(evt.OriginOfCondition, "OriginOfCondition", submitTestEventAction))
+	}
+
+	// test events are for seeing what real ones look like, so only real messages
+	if evt.MessageId != "" && len(domain.MessageRegistries()) > 0 {
+		if msg, ok := domain.LookupMessage(evt.MessageId); !ok {
+			errs = append(errs, domain.PropertyValueNotInList(evt.MessageId, "MessageId"))
+		} else if evt.Message == "" && msg.NumberOfArgs != len(evt.MessageArgs) {
+			// the args go into the registry text, so they have to line up
+			errs = append(errs, domain.ActionParameterValueFormatError(strings.Join(evt.MessageArgs, ", "), "MessageArgs", submitTestEventAction))
+		} else {
+			evt.fillFromRegistry()
+		}
+	}
+
+	if evt.Severity == "" {
+		evt.Severity = "OK"
+	}
+	if !contains(testEventSeverities, evt.Severity) {
+		errs = append(errs, domain.PropertyValueNotInList(evt.Severity, "Severity"))
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return evt, nil
+}
+
+// makeResumeSubscription is the ResumeSubscription action for a subscription that was suspended after its retries ran out
+func makeResumeSubscription(q *deliveryQueue) func(context.Context, eh.Event, *domain.HTTPCmdProcessedData) error {
+	return func(ctx context.Context, event eh.Event, retData *domain.HTTPCmdProcessedData) error {
+		domain.ContextLogger(ctx, "resume_subscription").Info("resuming subscription", "destination", q.dest)
+		q.resume()
+
+		retData.Results = map[string]interface{}{"msg": "Subscription resumed"}
 		retData.StatusCode = 200
 		return nil
 	}
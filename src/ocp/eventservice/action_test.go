@@ -0,0 +1,114 @@
+package eventservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	domain "github.com/superchalupa/sailfish/src/redfishresource"
+)
+
+func TestParseTestEvent(t *testing.T) {
+	defer domain.RemoveMessageRegistry("SubmitTest")
+	assert.Nil(t, domain.AddMessageRegistry(&domain.MessageRegistry{
+		RegistryPrefix:  "SubmitTest",
+		RegistryVersion: "1.0.0",
+		Messages: map[string]domain.RegistryMessage{
+			"FanFailed": {Message: "Fan %1 failed.", Severity: "Critical", NumberOfArgs: 1},
+		},
+	}))
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		testname string
+		body     interface{}
+		expected *RedfishEventData
+		errors   []string
+	}{
+		{"defaults", nil,
+			&RedfishEventData{EventType: "Alert", EventTimestamp: "2019-03-01T12:00:00Z", Severity: "OK"}, nil},
+		{"everything", map[string]interface{}{
+			"EventType":         "StatusChange",
+			"EventId":           "42",
+			"EventTimestamp":    "2019-02-01T08:00:00-06:00",
+			"Severity":          "Warning",
+			"Message":           "Fan 3 is slow.",
+			"MessageId":         "SubmitTest.1.0.FanFailed",
+			"MessageArgs":       []interface{}{"3"},
+			"OriginOfCondition": map[string]interface{}{"@odata.id": "/redfish/v1/Chassis/System.Modular.1"},
+		}, &RedfishEventData{
+			EventType:         "StatusChange",
+			EventId:           "42",
+			EventTimestamp:    "2019-02-01T08:00:00-06:00",
+			Severity:          "Warning",
+			Message:           "Fan 3 is slow.",
+			MessageId:         "SubmitTest.1.0.FanFailed",
+			MessageArgs:       []string{"3"},
+			OriginOfCondition: "/redfish/v1/Chassis/System.Modular.1",
+		}, nil},
+		{"from the registry", map[string]interface{}{"MessageId": "SubmitTest.1.0.FanFailed", "MessageArgs": []interface{}{"3"}},
+			&RedfishEventData{EventType: "Alert", EventTimestamp: "2019-03-01T12:00:00Z", Severity: "Critical",
+				Message: "Fan 3 failed.", MessageId: "SubmitTest.1.0.FanFailed", MessageArgs: []string{"3"}}, nil},
+		{"not an object", []interface{}{}, nil, []string{"Base.1.0.UnrecognizedRequestBody"}},
+		{"unknown parameter", map[string]interface{}{"Color": "red"}, nil, []string{"Base.1.0.ActionParameterUnknown"}},
+		{"wrong types", map[string]interface{}{"Severity": 3.0, "MessageArgs": []interface{}{"a", true}}, nil,
+			[]string{"Base.1.0.ActionParameterValueTypeError", "Base.1.0.ActionParameterValueTypeError"}},
+		{"bad values", map[string]interface{}{
+			"EventType":         "Whenever",
+			"EventTimestamp":    "yesterday",
+			"Severity":          "Informational",
+			"OriginOfCondition": "Chassis",
+		}, nil, []string{
+			"Base.1.0.PropertyValueNotInList",
+			"Base.1.0.ActionParameterValueFormatError",
+			"Base.1.0.ActionParameterValueFormatError",
+			"Base.1.0.PropertyValueNotInList",
+		}},
+		{"unknown message", map[string]interface{}{"MessageId": "SubmitTest.1.0.FanFine"}, nil, []string{"Base.1.0.PropertyValueNotInList"}},
+		{"wrong number of args", map[string]interface{}{"MessageId": "SubmitTest.1.0.FanFailed"}, nil, []string{"Base.1.0.ActionParameterValueFormatError"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testname, func(t *testing.T) {
+			evt, errs := parseTestEvent(tc.body, now)
+			assert.Equal(t, tc.expected, evt)
+			ids := []string{}
+			for _, e := range errs {
+				ids = append(ids, e.MessageId)
+			}
+			if tc.errors == nil {
+				tc.errors = []string{}
+			}
+			assert.Equal(t, tc.errors, ids)
+		})
+	}
+}
+
+func TestMatchingSubscriptions(t *testing.T) {
+	es := &EventService{subs: map[string]openSubscription{
+		"/redfish/v1/EventService/Subscriptions/1": {id: "1", Subscription: Subscription{
+			Destination: "https://console.example.com/events", Protocol: "Redfish", EventTypes: []string{"Alert"}}},
+		"/redfish/v1/EventService/Subscriptions/2": {id: "2", Subscription: Subscription{
+			Destination: "https://console.example.com/status", Protocol: "Redfish", EventTypes: []string{"StatusChange"}}},
+		"/redfish/v1/EventService/Subscriptions/3": {id: "3", Subscription: Subscription{
+			Destination: "https://console.example.com/reports", Protocol: "Redfish", EventFormatType: "MetricReport"}},
+		"/redfish/v1/EventService/Subscriptions/4": {id: "4", Subscription: Subscription{
+			Protocol: "SSE", EventTypes: []string{"Alert", "StatusChange"}}},
+	}}
+
+	assert.Equal(t, 2, es.matchingSubscriptions(context.Background(), &RedfishEventData{EventType: "Alert"}))
+	assert.Equal(t, 2, es.matchingSubscriptions(context.Background(), &RedfishEventData{EventType: "StatusChange"}))
+	assert.Equal(t, 0, es.matchingSubscriptions(context.Background(), &RedfishEventData{EventType: "ResourceAdded"}))
+}
+
+func TestTestEventResults(t *testing.T) {
+	evt := &RedfishEventData{EventType: "Alert", Oem: map[string]interface{}{"sailfish": map[string]interface{}{"RepeatCount": 2}}}
+	results := testEventResults(evt, 3)
+
+	assert.Equal(t, map[string]interface{}{"sailfish": map[string]interface{}{"RepeatCount": 2, "SubscriptionsMatched": 3}}, results.Oem)
+	// the event that went out is left alone
+	assert.Equal(t, map[string]interface{}{"sailfish": map[string]interface{}{"RepeatCount": 2}}, evt.Oem)
+	assert.Equal(t, "Alert", results.EventType)
+}
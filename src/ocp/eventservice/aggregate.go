@@ -36,34 +36,27 @@ func RegisterAggregate(s *testaggregate.Service) {
 							"HealthRollup": "OK", //hardcoded
 							"Health":       "OK", //hardcoded
 						},
-						"ServiceEnabled":                    true,
-						"DeliveryRetryAttempts@meta":        vw.Meta(view.PropGET("delivery_retry_attempts")),
-						"DeliveryRetryIntervalSeconds@meta": vw.Meta(view.PropGET("delivery_retry_interval_seconds")),
-						"EventTypesForSubscription": []string{
-							"StatusChange",
-							"ResourceUpdated",
-							"ResourceAdded",
-							"ResourceRemoved",
-							"Alert",
-						},
+						"ServiceEnabled":                        true,
+						"DeliveryRetryAttempts@meta":            vw.Meta(view.PropGET("delivery_retry_attempts")),
+						"DeliveryRetryIntervalSeconds@meta":     vw.Meta(view.PropGET("delivery_retry_interval_seconds")),
+						"EventTypesForSubscription":             EventTypesForSubscription,
 						"EventFormatTypes":                      []string{"Event", "MetricReport"},
-						"EventTypesForSubscription@odata.count": 5,
+						"EventTypesForSubscription@odata.count": len(EventTypesForSubscription),
 						"Actions": map[string]interface{}{
 							"#EventService.SubmitTestEvent": map[string]interface{}{
-								"target": vw.GetActionURI("submit.test.event"),
-								"EventType@Redfish.AllowableValues": []string{
-									"StatusChange",
-									"ResourceUpdated",
-									"ResourceAdded",
-									"ResourceRemoved",
-									"Alert",
-								},
+								"target":                            vw.GetActionURI("submit.test.event"),
+								"EventType@Redfish.AllowableValues": EventTypesForSubscription,
 							},
 						},
 						"Oem": map[string]interface{}{ //??
 							"sailfish": map[string]interface{}{
-								"max_milliseconds_to_queue@meta": vw.Meta(view.PropGET("max_milliseconds_to_queue")),
-								"max_events_to_queue@meta":       vw.Meta(view.PropGET("max_events_to_queue")),
+								"max_milliseconds_to_queue@meta":     vw.Meta(view.PropGET("max_milliseconds_to_queue")),
+								"max_events_to_queue@meta":           vw.Meta(view.PropGET("max_events_to_queue")),
+								"storm_max_events@meta":              vw.Meta(view.PropGET("storm_max_events")),
+								"storm_window_seconds@meta":          vw.Meta(view.PropGET("storm_window_seconds")),
+								"sse_replay_buffer_size@meta":        vw.Meta(view.PropGET("sse_replay_buffer_size")),
+								"max_subscriptions@meta":             vw.Meta(view.PropGET("max_subscriptions")),
+								"duplicate_subscription_policy@meta": vw.Meta(view.PropGET("duplicate_subscription_policy")),
 							},
 						},
 					}},
@@ -112,7 +105,7 @@ func RegisterAggregate(s *testaggregate.Service) {
 				&domain.CreateRedfishResource{
 					ResourceURI: vw.GetURI(),
 
-					Type:    "#EventDestination.v1_2_0.EventDestination",
+					Type:    "#EventDestination.v1_9_0.EventDestination",
 					Context: params["rooturi"].(string) + "/$metadata#EventDestination.EventDestination",
 					// Plugin is how we find the POST command handler
 					Plugin: "EventService",
@@ -130,6 +123,65 @@ func RegisterAggregate(s *testaggregate.Service) {
 						"EventTypes@meta":             vw.Meta(view.GETProperty("event_types"), view.GETModel("default")),
 						"EventTypes@odata.count@meta": vw.Meta(view.GETProperty("event_types"), view.GETFormatter("count"), view.GETModel("default")),
 						"Context@meta":                vw.Meta(view.GETProperty("context"), view.GETModel("default")),
+
+						"EventFormatType@meta":              vw.Meta(view.GETProperty("event_format_type"), view.GETModel("default")),
+						"RegistryPrefixes@meta":             vw.Meta(view.GETProperty("registry_prefixes"), view.GETModel("default")),
+						"RegistryPrefixes@odata.count@meta": vw.Meta(view.GETProperty("registry_prefixes"), view.GETFormatter("count"), view.GETModel("default")),
+						"ResourceTypes@meta":                vw.Meta(view.GETProperty("resource_types"), view.GETModel("default")),
+						"ResourceTypes@odata.count@meta":    vw.Meta(view.GETProperty("resource_types"), view.GETFormatter("count"), view.GETModel("default")),
+						"OriginResources@meta":              vw.Meta(view.GETProperty("origin_resources"), view.GETModel("default")),
+						"OriginResources@odata.count@meta":  vw.Meta(view.GETProperty("origin_resources"), view.GETFormatter("count"), view.GETModel("default")),
+						"MessageIds@meta":                   vw.Meta(view.GETProperty("message_ids"), view.GETModel("default")),
+						"MessageIds@odata.count@meta":       vw.Meta(view.GETProperty("message_ids"), view.GETFormatter("count"), view.GETModel("default")),
+						"SubordinateResources@meta":         vw.Meta(view.GETProperty("subordinate_resources"), view.GETModel("default")),
+						"VerifyCertificate@meta":            vw.Meta(view.GETProperty("verify_certificate"), view.GETModel("default")),
+						"Certificates":                      map[string]interface{}{"@odata.id": vw.GetURI() + "/Certificates"},
+						// write only, so it's always empty
+						"HttpHeaders":         []interface{}{},
+						"DeliveryRetryPolicy": "SuspendRetries",
+
+						"Status": map[string]interface{}{
+							"State@meta": vw.Meta(view.GETProperty("state"), view.GETModel("default")),
+						},
+						"Actions": map[string]interface{}{
+							"#EventDestination.ResumeSubscription": map[string]interface{}{
+								"target": vw.GetActionURI("resume.subscription"),
+							},
+						},
+						"Oem": map[string]interface{}{
+							"sailfish": map[string]interface{}{
+								"DeliveryStats": map[string]interface{}{
+									"Delivered@meta":        vw.Meta(view.GETProperty("delivered"), view.GETModel("stats")),
+									"Retries@meta":          vw.Meta(view.GETProperty("retries"), view.GETModel("stats")),
+									"Failed@meta":           vw.Meta(view.GETProperty("failed"), view.GETModel("stats")),
+									"Dropped@meta":          vw.Meta(view.GETProperty("dropped"), view.GETModel("stats")),
+									"LastError@meta":        vw.Meta(view.GETProperty("last_error"), view.GETModel("stats")),
+									"LastDeliveryTime@meta": vw.Meta(view.GETProperty("last_delivery_time"), view.GETModel("stats")),
+								},
+							},
+						},
+					}},
+			}, nil
+		})
+
+	s.RegisterAggregateFunction("subscriptioncertificates",
+		func(ctx context.Context, subLogger log.Logger, cfgMgr *viper.Viper, cfgMgrMu *sync.RWMutex, vw *view.View, extra interface{}, params map[string]interface{}) ([]eh.Command, error) {
+			return []eh.Command{
+				&domain.CreateRedfishResource{
+					ResourceURI: vw.GetURI(),
+					Type:        "#CertificateCollection.CertificateCollection",
+					Context:     params["rooturi"].(string) + "/$metadata#CertificateCollection.CertificateCollection",
+					// Plugin is how we find the POST command handler
+					Plugin: "EventDestinationCertificates",
+					Privileges: map[string]interface{}{
+						"GET":  []string{"Login"},
+						"POST": []string{"ConfigureManager"},
+					},
+					Properties: map[string]interface{}{
+						"Name":                     "Event Destination Certificates",
+						"Description":              "Certificates trusted for the event destination",
+						"Members@meta":             vw.Meta(view.GETProperty("members"), view.GETFormatter("formatOdataList"), view.GETModel("default")),
+						"Members@odata.count@meta": vw.Meta(view.GETProperty("members"), view.GETFormatter("count"), view.GETModel("default")),
 					}},
 			}, nil
 		})
@@ -15,11 +15,36 @@ const (
 	POSTCommand = eh.CommandType("EventService:POST")
 )
 
+const defaultMaxSubscriptions = 100
+
+// defaultDuplicatePolicy is what to do with a POST that matches an open
+// subscription, "ReturnExisting" or "Reject"
+const defaultDuplicatePolicy = "ReturnExisting"
+
 type Subscription struct {
-	Destination string
-	Protocol    string
-	EventTypes  []string
-	Context     string
+	Destination          string
+	Protocol             string
+	EventTypes           []string
+	Context              string
+	RegistryPrefixes     []string
+	ResourceTypes        []string
+	OriginResources      []OdataID
+	MessageIds           []string
+	SubordinateResources bool
+	EventFormatType      string
+	HttpHeaders          []map[string]string
+	VerifyCertificate    bool
+}
+
+// Headers returns the HttpHeaders as one map
+func (s Subscription) Headers() map[string]string {
+	headers := map[string]string{}
+	for _, h := range s.HttpHeaders {
+		for k, v := range h {
+			headers[k] = v
+		}
+	}
+	return headers
 }
 
 // HTTP POST Command
@@ -51,16 +76,46 @@ func (c *POST) ParseHTTPRequest(r *http.Request) error {
 	return nil
 }
 func (c *POST) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) error {
+	if errs := c.Sub.Validate(c.d.HasAggregateID); len(errs) > 0 {
+		c.fail(a, http.StatusBadRequest, errs...)
+		return nil
+	}
+
+	// the check and the create have to happen together, or two identical POSTs could both get through
+	c.es.postMu.Lock()
+	defer c.es.postMu.Unlock()
+
+	if uri, ok := c.es.findDuplicate(c.Sub); ok {
+		if getString(c.es.settings, "duplicate_subscription_policy", defaultDuplicatePolicy) == "Reject" {
+			c.fail(a, http.StatusConflict, domain.ResourceAlreadyExists("EventDestination", "Destination", c.Sub.Destination))
+			return nil
+		}
+		// consoles re-subscribe every time they start, hand them the one they
+		// made last time, with the credentials they have now
+		c.es.updateDuplicate(uri, c.Sub)
+		return c.respond(ctx, a, c.d.GetAggregateID(uri), uri)
+	}
+
+	if c.es.subscriptionCount() >= getInt(c.es.settings, "max_subscriptions", defaultMaxSubscriptions) {
+		c.fail(a, http.StatusBadRequest, domain.CreateLimitReachedForResource())
+		return nil
+	}
+
 	subctx, cancel := context.WithCancel(ctx)
 	view := c.es.CreateSubscription(subctx, domain.ContextLogger(subctx, "eventservice"), c.Sub, cancel)
 
+	return c.respond(subctx, a, view.GetUUID(), view.GetURI())
+}
+
+// respond sends back the subscription, with its Location
+func (c *POST) respond(ctx context.Context, a *domain.RedfishResourceAggregate, id eh.UUID, uri string) error {
 	data := &domain.HTTPCmdProcessedData{
 		CommandID:  c.CmdID,
 		Results:    map[string]interface{}{"msg": "Error creating subscription"},
 		StatusCode: 500,
 		Headers:    map[string]string{}}
 
-	agg, err := c.d.AggregateStore.Load(subctx, domain.AggregateType, view.GetUUID())
+	agg, err := c.d.AggregateStore.Load(ctx, domain.AggregateType, id)
 	if err != nil {
 		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, data, time.Now()))
 		return errors.New("Could not load subscription aggregate")
@@ -71,15 +126,126 @@ func (c *POST) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) e
 		return errors.New("Wrong aggregate type returned")
 	}
 
-	domain.NewGet(subctx, redfishResource, &redfishResource.Properties, c.auth)
+	domain.NewGet(ctx, redfishResource, &redfishResource.Properties, c.auth)
 	data.Results = domain.Flatten(&redfishResource.Properties, false)
 
 	for k, v := range a.Headers {
 		data.Headers[k] = v
 	}
-	data.Headers["Location"] = view.GetURI()
+	data.Headers["Location"] = uri
 	data.StatusCode = 200
 	a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, data, time.Now()))
 
 	return nil
 }
+
+func (c *POST) fail(a *domain.RedfishResourceAggregate, statusCode int, errs ...domain.ExtendedInfo) {
+	a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, &domain.HTTPCmdProcessedData{
+		CommandID:  c.CmdID,
+		Results:    domain.ErrorResponse(errs...),
+		StatusCode: statusCode,
+		Headers:    map[string]string{}}, time.Now()))
+}
+
+const (
+	CertificatesPOSTCommand = eh.CommandType("EventDestinationCertificates:POST")
+)
+
+// Certificate is the body of a POST to a subscription's Certificates collection
+type Certificate struct {
+	CertificateString string
+	CertificateType   string
+}
+
+// HTTP POST Command for the Certificates collection of a subscription
+type CertificatesPOST struct {
+	d    *domain.DomainObjects
+	auth *domain.RedfishAuthorizationProperty
+
+	ID      eh.UUID           `json:"id"`
+	CmdID   eh.UUID           `json:"cmdid"`
+	Headers map[string]string `eh:"optional"`
+	Cert    Certificate       `eh:"optional"`
+}
+
+// Static type checking for commands to prevent runtime errors due to typos
+var _ = eh.Command(&CertificatesPOST{})
+
+func (c *CertificatesPOST) AggregateType() eh.AggregateType { return domain.AggregateType }
+func (c *CertificatesPOST) AggregateID() eh.UUID            { return c.ID }
+func (c *CertificatesPOST) CommandType() eh.CommandType     { return CertificatesPOSTCommand }
+func (c *CertificatesPOST) SetAggID(id eh.UUID)             { c.ID = id }
+func (c *CertificatesPOST) SetCmdID(id eh.UUID)             { c.CmdID = id }
+func (c *CertificatesPOST) SetUserDetails(a *domain.RedfishAuthorizationProperty) string {
+	c.auth = a
+	return "checkMaster"
+}
+func (c *CertificatesPOST) ParseHTTPRequest(r *http.Request) error {
+	return json.NewDecoder(r.Body).Decode(&c.Cert)
+}
+func (c *CertificatesPOST) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) error {
+	data := &domain.HTTPCmdProcessedData{
+		CommandID:  c.CmdID,
+		Results:    map[string]interface{}{"msg": "Error adding certificate"},
+		StatusCode: 500,
+		Headers:    map[string]string{}}
+
+	errs := []domain.ExtendedInfo{}
+	if c.Cert.CertificateType != "" && c.Cert.CertificateType != "PEM" {
+		errs = append(errs, domain.PropertyValueNotInList(c.Cert.CertificateType, "CertificateType"))
+	}
+	cert, err := parseCertificate(c.Cert.CertificateString)
+	if c.Cert.CertificateString == "" {
+		errs = append(errs, domain.PropertyMissing("CertificateString"))
+	} else if err != nil {
+		errs = append(errs, domain.PropertyValueFormatError("CertificateString", "CertificateString"))
+	}
+	if len(errs) > 0 {
+		data.Results = domain.ErrorResponse(errs...)
+		data.StatusCode = http.StatusBadRequest
+		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, data, time.Now()))
+		return nil
+	}
+
+	id := eh.NewUUID()
+	uri := a.ResourceURI + "/" + string(id)
+	properties := map[string]interface{}{
+		"Id":                string(id),
+		"Name":              "Event Destination Certificate",
+		"CertificateString": c.Cert.CertificateString,
+		"CertificateType":   "PEM",
+		"Issuer":            map[string]interface{}{"CommonName": cert.Issuer.CommonName},
+		"Subject":           map[string]interface{}{"CommonName": cert.Subject.CommonName},
+		"ValidNotBefore":    cert.NotBefore.UTC().Format(time.RFC3339),
+		"ValidNotAfter":     cert.NotAfter.UTC().Format(time.RFC3339),
+	}
+	create := &domain.CreateRedfishResource{
+		ID:          id,
+		ResourceURI: uri,
+		Type:        "#Certificate.v1_0_0.Certificate",
+		Context:     "/redfish/v1/$metadata#Certificate.Certificate",
+		Deletable:   true,
+		Privileges: map[string]interface{}{
+			"GET":    []string{"Login"},
+			"DELETE": []string{"ConfigureManager"},
+		},
+		Properties: map[string]interface{}{},
+	}
+	for k, v := range properties {
+		create.Properties[k] = v
+	}
+	if err := c.d.CommandHandler.HandleCommand(ctx, create); err != nil {
+		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, data, time.Now()))
+		return err
+	}
+
+	properties["@odata.id"] = uri
+	properties["@odata.type"] = create.Type
+	properties["@odata.context"] = create.Context
+	data.Results = properties
+	data.Headers["Location"] = uri
+	data.StatusCode = http.StatusCreated
+	a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, data, time.Now()))
+
+	return nil
+}
@@ -56,6 +56,51 @@ func PublishRedfishEvents(ctx context.Context, m propertygetter, eb eh.EventBus)
 		timer.Stop()
 		id := 0
 		var maxE int = defaultMaxEventsToQueue
+		storm := newStormGuard()
+		stormTimer := time.NewTimer(10 * time.Second)
+		stormTimer.Stop()
+		// queued sends eventQ if it is full, otherwise starts the timer to send it in a bit
+		queued := func() {
+			var QueueTime time.Duration = -1 * time.Millisecond
+
+			if maxEventsToQueue, ok := m.GetPropertyOk("max_events_to_queue"); ok {
+				if maxE, ok = maxEventsToQueue.(int); !ok {
+					maxE = defaultMaxEventsToQueue
+				}
+			}
+
+			if ms, ok := m.GetPropertyOk("max_milliseconds_to_queue"); ok {
+				var msInt int
+				if msInt, ok = ms.(int); !ok {
+					msInt = -1
+				}
+				QueueTime = time.Duration(msInt) * time.Millisecond
+			}
+
+			if QueueTime < 0 {
+				QueueTime = defaultQueueTimeMs
+			}
+
+			if len(eventQ) > maxE {
+				log.MustLogger("event_service").Info("Full queue: sending now.", "id", id)
+				// if queue has max number of events, send them now
+				sendEvents(ctx, id, eventQ, eb)
+				if !timer.Stop() {
+					//drain timer, if it ran and wasn't already drained
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				id = id + 1
+				eventQ = []*RedfishEventData{}
+
+			} else {
+				// otherwise, start up timer to send the events in a bit
+				timer.Reset(QueueTime)
+			}
+		}
+
 		for {
 			select {
 			case event := <-listener.Inbox():
@@ -84,45 +129,20 @@ func PublishRedfishEvents(ctx context.Context, m propertygetter, eb eh.EventBus)
 
 					if found {
 						continue
-					} else {
-						eventQ = append(eventQ, data)
 					}
 
-					var QueueTime time.Duration = -1 * time.Millisecond
-
-					if maxEventsToQueue, ok := m.GetPropertyOk("max_events_to_queue"); ok {
-						if maxE, ok = maxEventsToQueue.(int); !ok {
-							maxE = defaultMaxEventsToQueue
-						}
-					}
-
-					if ms, ok := m.GetPropertyOk("max_milliseconds_to_queue"); ok {
-						var msInt int
-						if msInt, ok = ms.(int); !ok {
-							msInt = -1
-						}
-						QueueTime = time.Duration(msInt) * time.Millisecond
+					data.fillFromRegistry()
+					if !storm.active {
+						storm.active = true
+						stormTimer.Reset(time.Duration(getInt(m, "storm_window_seconds", defaultStormWindowSeconds)) * time.Second)
 					}
-
-					if QueueTime < 0 {
-						QueueTime = defaultQueueTimeMs
+					if !storm.admit(data, getInt(m, "storm_max_events", defaultStormMaxEvents)) {
+						log.MustLogger("event_service").Debug("event storm: holding event", "MessageId", data.MessageId, "OriginOfCondition", data.OriginOfCondition)
+						continue
 					}
+					eventQ = append(eventQ, data)
 
-					if len(eventQ) > maxE {
-						log.MustLogger("event_service").Info("Full queue: sending now.", "id", id)
-						// if queue has max number of events, send them now
-						sendEvents(ctx, id, eventQ, eb)
-						if !timer.Stop() {
-							//drain timer
-							<-timer.C
-						}
-						id = id + 1
-						eventQ = []*RedfishEventData{}
-
-					} else {
-						// otherwise, start up timer to send the events in a bit
-						timer.Reset(QueueTime)
-					}
+					queued()
 
 				case *domain.RedfishResourceCreatedData:
 					eventData := &RedfishEventData{
@@ -148,6 +168,14 @@ func PublishRedfishEvents(ctx context.Context, m propertygetter, eb eh.EventBus)
 					log.MustLogger("event_service").Warn("Should never happen: got an invalid event in the event handler", "data", data, "deets", fmt.Sprintf("%T", data))
 				}
 
+			case <-stormTimer.C:
+				held := storm.flush(time.Now())
+				if len(held) > 0 {
+					log.MustLogger("event_service").Info("event storm: sending held events", "count", len(held))
+					eventQ = append(eventQ, held...)
+					queued()
+				}
+
 			case <-timer.C:
 				log.MustLogger("event_service").Info("times up: sending now.", "id", id)
 				sendEvents(ctx, id, eventQ, eb)
@@ -0,0 +1,299 @@
+package eventservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/superchalupa/sailfish/src/log"
+	"github.com/superchalupa/sailfish/src/ocp/model"
+)
+
+const defaultDeliveryQueueLen = 100
+const defaultDeliveryRetryAttempts = 3
+const defaultDeliveryRetryIntervalSeconds = 30
+
+// deliveryQueue holds the events waiting to be POSTed to one subscriber, so a
+// listener that is slow or gone only holds up its own events. When an event
+// can't be delivered after DeliveryRetryAttempts, the subscription is
+// suspended and keeps that event until someone resumes it.
+type deliveryQueue struct {
+	dest     string
+	events   chan eventBinary
+	resumed  chan struct{}
+	settings propertygetter
+
+	mu        sync.Mutex
+	headers   map[string]string
+	client    *http.Client
+	suspended bool
+	stats     deliveryStats
+	subModel  *model.Model
+	statModel *model.Model
+}
+
+type deliveryStats struct {
+	Delivered        int
+	Retries          int
+	Failed           int
+	Dropped          int
+	LastError        string
+	LastDeliveryTime string
+}
+
+// newDeliveryQueue makes the queue for a subscription. settings is the event
+// service model, which has the retry settings, and headers are added to every POST.
+func newDeliveryQueue(dest string, settings propertygetter, headers map[string]string) *deliveryQueue {
+	q := &deliveryQueue{
+		dest:     dest,
+		events:   make(chan eventBinary, defaultDeliveryQueueLen),
+		resumed:  make(chan struct{}, 1),
+		settings: settings,
+		headers:  headers,
+	}
+	q.useTLS(&tls.Config{InsecureSkipVerify: true})
+	return q
+}
+
+// useTLS sets how the destination certificate is checked, starting with the next POST
+func (q *deliveryQueue) useTLS(tlsConfig *tls.Config) {
+	client := &http.Client{
+		Timeout: time.Second * 5,
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.client = client
+}
+
+// setHeaders replaces the headers added to every POST, starting with the next one
+func (q *deliveryQueue) setHeaders(headers map[string]string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.headers = headers
+}
+
+// attach sets the models that State and the delivery stats are shown from.
+// State starts out Enabled from the config file.
+func (q *deliveryQueue) attach(subModel *model.Model, statModel *model.Model) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.subModel = subModel
+	q.statModel = statModel
+	q.updateStatsLocked()
+}
+
+// model returns the subscription model that attach set
+func (q *deliveryQueue) model() *model.Model {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.subModel
+}
+
+// enqueue adds events to the queue, dropping them if it is full
+func (q *deliveryQueue) enqueue(eventlist []eventBinary) {
+	for _, evt := range eventlist {
+		select {
+		case q.events <- evt:
+		default:
+			log.MustLogger("event_service").Crit("Subscription Event Queue Full, dropping", "Id", evt.id, "uri", q.dest)
+			logToEventFile(fmt.Sprintf("%s -- DROP Id=%s to uri=%s\n", time.Now().UTC().Format(time.UnixDate), evt.id, q.dest))
+			q.updateStats(func(s *deliveryStats) { s.Dropped++ })
+		}
+	}
+}
+
+// run delivers events in order until the context is cancelled
+func (q *deliveryQueue) run(ctx context.Context) {
+	for {
+		if !q.waitUntilEnabled(ctx) {
+			return
+		}
+		select {
+		case evt := <-q.events:
+			for !q.deliver(ctx, evt) {
+				q.suspend()
+				if !q.waitUntilEnabled(ctx) {
+					return
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver tries to POST one event, retrying as configured on the event service
+func (q *deliveryQueue) deliver(ctx context.Context, evt eventBinary) bool {
+	retries := getInt(q.settings, "delivery_retry_attempts", defaultDeliveryRetryAttempts)
+	interval := time.Duration(getInt(q.settings, "delivery_retry_interval_seconds", defaultDeliveryRetryIntervalSeconds)) * time.Second
+
+	logToEventFile(fmt.Sprintf("%s -- STARTING to send MessageId=%s to uri=%s\n", time.Now().UTC().Format(time.UnixDate), evt.id, q.dest))
+	for i := 0; i <= retries; i++ {
+		if i > 0 {
+			q.updateStats(func(s *deliveryStats) { s.Retries++ })
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		err := q.post(ctx, evt)
+		if err == nil {
+			logToEventFile(fmt.Sprintf("%s -- Success sent Id=%s to uri=%s attempt=%d\n", time.Now().UTC().Format(time.UnixDate), evt.id, q.dest, i+1))
+			q.updateStats(func(s *deliveryStats) {
+				s.Delivered++
+				s.LastDeliveryTime = time.Now().UTC().Format(time.RFC3339)
+			})
+			return true
+		}
+
+		log.MustLogger("event_service").Crit("ERROR POSTING", "Id", evt.id, "uri", q.dest, "attempt", i+1, "err", err)
+		logToEventFile(fmt.Sprintf("%s -- ERROR POSTING Id=%s to uri=%s attempt=%d err=%s\n", time.Now().UTC().Format(time.UnixDate), evt.id, q.dest, i+1, err))
+		q.updateStats(func(s *deliveryStats) { s.LastError = err.Error() })
+	}
+
+	logToEventFile(fmt.Sprintf("%s -- FAILURE to send Id=%s to uri=%s, suspending subscription\n", time.Now().UTC().Format(time.UnixDate), evt.id, q.dest))
+	q.updateStats(func(s *deliveryStats) { s.Failed++ })
+	return false
+}
+
+func (q *deliveryQueue) post(ctx context.Context, evt eventBinary) error {
+	req, err := http.NewRequest("POST", q.dest, bytes.NewBuffer(evt.data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	q.mu.Lock()
+	headers, client := q.headers, q.client
+	q.mu.Unlock()
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Add("OData-Version", "4.0")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent:
+		return nil
+	}
+	return fmt.Errorf("HTTP Status=%d", resp.StatusCode)
+}
+
+// waitUntilEnabled blocks while the subscription is suspended. It returns false if the context is done.
+func (q *deliveryQueue) waitUntilEnabled(ctx context.Context) bool {
+	for {
+		q.mu.Lock()
+		suspended := q.suspended
+		q.mu.Unlock()
+		if !suspended {
+			return ctx.Err() == nil
+		}
+
+		select {
+		case <-q.resumed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (q *deliveryQueue) suspend() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.suspended = true
+	q.updateStateLocked()
+}
+
+// resume starts delivery again on a suspended subscription, beginning with the event that failed
+func (q *deliveryQueue) resume() {
+	q.mu.Lock()
+	if q.suspended {
+		q.suspended = false
+		q.updateStateLocked()
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.resumed <- struct{}{}:
+	default:
+	}
+}
+
+func (q *deliveryQueue) updateStats(fn func(*deliveryStats)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	fn(&q.stats)
+	q.updateStatsLocked()
+}
+
+// updateStateLocked shows State on the subscription. That model sends
+// ResourceUpdated events, so only call this when State actually changes.
+func (q *deliveryQueue) updateStateLocked() {
+	if q.subModel != nil {
+		state := "Enabled"
+		if q.suspended {
+			state = "Suspended"
+		}
+		q.subModel.UpdateProperty("state", state)
+	}
+}
+
+func (q *deliveryQueue) updateStatsLocked() {
+	if q.statModel != nil {
+		q.statModel.UpdateProperty("delivered", q.stats.Delivered)
+		q.statModel.UpdateProperty("retries", q.stats.Retries)
+		q.statModel.UpdateProperty("failed", q.stats.Failed)
+		q.statModel.UpdateProperty("dropped", q.stats.Dropped)
+		q.statModel.UpdateProperty("last_error", q.stats.LastError)
+		q.statModel.UpdateProperty("last_delivery_time", q.stats.LastDeliveryTime)
+	}
+}
+
+// getInt reads a number from the model, which could have come from the config file as an int, float, or string
+func getInt(m propertygetter, name string, def int) int {
+	if m == nil {
+		return def
+	}
+	v, ok := m.GetPropertyOk(name)
+	if !ok {
+		return def
+	}
+	switch n := v.(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	case string:
+		if i, err := strconv.Atoi(n); err == nil {
+			return i
+		}
+	}
+	return def
+}
+
+// getString reads a string from the model
+func getString(m propertygetter, name string, def string) string {
+	if m == nil {
+		return def
+	}
+	if v, ok := m.GetPropertyOk(name); ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return def
+}
@@ -0,0 +1,74 @@
+package eventservice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/superchalupa/sailfish/src/log15adapter"
+	"github.com/superchalupa/sailfish/src/ocp/model"
+)
+
+func init() {
+	log15adapter.InitializeApplicationLogging("")
+}
+
+func TestDeliveryQueue(t *testing.T) {
+	var up int32
+	var received int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&up) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "Bearer sekrit", r.Header.Get("Authorization"))
+		atomic.AddInt32(&received, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	settings := model.New()
+	settings.UpdateProperty("delivery_retry_attempts", 2.0)
+	settings.UpdateProperty("delivery_retry_interval_seconds", "0")
+	subModel := model.New(model.UpdateProperty("state", "Enabled"))
+	statModel := model.New()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := newDeliveryQueue(srv.URL, settings, map[string]string{"Authorization": "Bearer sekrit"})
+	q.attach(subModel, statModel)
+	go q.run(ctx)
+
+	q.enqueue([]eventBinary{{"1", []byte("{}")}, {"2", []byte("{}")}})
+	waitFor(t, func() bool { return subModel.GetProperty("state") == "Suspended" })
+	assert.Equal(t, 1, statModel.GetProperty("failed"))
+	assert.Equal(t, 2, statModel.GetProperty("retries"))
+	assert.Equal(t, 0, statModel.GetProperty("delivered"))
+
+	// fill the queue up while suspended
+	for i := 0; i < defaultDeliveryQueueLen; i++ {
+		q.enqueue([]eventBinary{{"more", []byte("{}")}})
+	}
+	assert.Equal(t, 1, statModel.GetProperty("dropped"))
+
+	atomic.StoreInt32(&up, 1)
+	q.resume()
+	assert.Equal(t, "Enabled", subModel.GetProperty("state"))
+	waitFor(t, func() bool { return statModel.GetProperty("delivered") == defaultDeliveryQueueLen+1 })
+	assert.Equal(t, int32(defaultDeliveryQueueLen+1), atomic.LoadInt32(&received))
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal("timed out")
+}
@@ -0,0 +1,133 @@
+package eventservice
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"path"
+
+	"github.com/superchalupa/sailfish/src/log"
+	domain "github.com/superchalupa/sailfish/src/redfishresource"
+)
+
+// destinationTLSConfig returns the TLS settings for POSTing events to a
+// subscription. Without VerifyCertificate, anything goes, like it always has.
+// With it, the destination has to have a certificate that chains to the CA
+// bundle or to one of the subscription's Certificates. If it doesn't chain, a
+// certificate that exactly matches one in Certificates is accepted too, so
+// self signed listeners can be pinned.
+func (es *EventService) destinationTLSConfig(verify bool, dest string, certsURI string) *tls.Config {
+	if !verify {
+		return &tls.Config{InsecureSkipVerify: true}
+	}
+
+	host := ""
+	if u, err := url.Parse(dest); err == nil {
+		host = u.Hostname()
+	}
+
+	return &tls.Config{
+		// we do the verifying ourselves so that pinned certificates work
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyDestination(rawCerts, host, es.caBundle(), es.subscriptionCerts(certsURI))
+		},
+	}
+}
+
+func verifyDestination(rawCerts [][]byte, host string, roots *x509.CertPool, subCerts []*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("destination did not send a certificate")
+	}
+	certs := []*x509.Certificate{}
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+
+	opts := x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range subCerts {
+		opts.Roots.AddCert(cert)
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	_, verifyErr := certs[0].Verify(opts)
+	if verifyErr == nil {
+		return nil
+	}
+
+	for _, cert := range subCerts {
+		if cert.Equal(certs[0]) {
+			return nil
+		}
+	}
+	return verifyErr
+}
+
+// caBundle loads the CAs trusted for event destinations. It is read each time
+// so that replacing the bundle doesn't need a restart. Without a bundle
+// configured, the system roots are used.
+func (es *EventService) caBundle() *x509.CertPool {
+	es.cfgMu.RLock()
+	bundle := es.cfg.GetString("main.event_ca_bundle")
+	es.cfgMu.RUnlock()
+
+	if bundle != "" {
+		pemData, err := ioutil.ReadFile(bundle)
+		if err == nil {
+			pool := x509.NewCertPool()
+			if pool.AppendCertsFromPEM(pemData) {
+				return pool
+			}
+		}
+		log.MustLogger("event_service").Crit("Could not load the event destination CA bundle, using system roots", "file", bundle, "err", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		return x509.NewCertPool()
+	}
+	return pool
+}
+
+// subscriptionCerts returns the certificates in a subscription's Certificates collection
+func (es *EventService) subscriptionCerts(certsURI string) []*x509.Certificate {
+	certs := []*x509.Certificate{}
+	for _, uri := range es.d.FindMatchingURIs(func(uri string) bool { return path.Dir(uri) == certsURI }) {
+		props, err := es.d.ExpandURI(context.Background(), uri)
+		if err != nil {
+			continue
+		}
+		certMap, ok := domain.Flatten(props, false).(map[string]interface{})
+		if !ok {
+			continue
+		}
+		certString, _ := certMap["CertificateString"].(string)
+		if cert, err := parseCertificate(certString); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+	return certs
+}
+
+// parseCertificate reads the first certificate out of a PEM string
+func parseCertificate(certString string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certString))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("not a PEM certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
@@ -0,0 +1,23 @@
+package eventservice
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyDestination(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	cert := srv.Certificate()
+	raw := [][]byte{cert.Raw}
+
+	// self signed, so only a pinned certificate or a matching root works
+	assert.Error(t, verifyDestination(raw, "127.0.0.1", x509.NewCertPool(), nil))
+	assert.NoError(t, verifyDestination(raw, "127.0.0.1", x509.NewCertPool(), []*x509.Certificate{cert}))
+	assert.Error(t, verifyDestination(nil, "127.0.0.1", x509.NewCertPool(), []*x509.Certificate{cert}))
+}
@@ -1,12 +1,9 @@
 package eventservice
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
 	"path"
 	"sync"
@@ -46,20 +43,31 @@ type SubscriptionCtx struct {
 	firstEvent  bool
 	Destination string
 	Protocol    string
-	EventTypes  []string
 	Context     string
+	EventFilter
+	queue *deliveryQueue
 }
 
 type EventService struct {
+	ctx       context.Context
 	d         *domain.DomainObjects
 	ew        *eventwaiter.EventWaiter
 	cfg       *viper.Viper
 	cfgMu     *sync.RWMutex
-	jc        chan Job
+	settings  propertygetter
 	wrap      func(string, map[string]interface{}) (log.Logger, *view.View, error)
 	addparam  func(map[string]interface{}) map[string]interface{}
 	actionSvc actionService
 	uploadSvc uploadService
+
+	// open subscriptions, by uri
+	subsMu sync.Mutex
+	subs   map[string]openSubscription
+	// held while a POST checks for duplicates and creates the subscription
+	postMu sync.Mutex
+
+	// recent events for SSE clients that reconnect
+	replay *replayBuffer
 }
 
 var GlobalEventService *EventService
@@ -72,17 +80,22 @@ func New(ctx context.Context, cfg *viper.Viper, cfgMu *sync.RWMutex, d *domain.D
 	go EventWaiter.Run()
 
 	ret := &EventService{
+		ctx:       ctx,
 		d:         d,
 		ew:        EventWaiter,
 		cfg:       cfg,
 		cfgMu:     cfgMu,
-		jc:        CreateWorkers(100, 6),
 		actionSvc: actionSvc,
+		subs:      map[string]openSubscription{},
 		wrap: func(name string, params map[string]interface{}) (log.Logger, *view.View, error) {
 			return instantiateSvc.InstantiateFromCfg(ctx, cfg, cfgMu, name, params)
 		},
 	}
 
+	ret.replay = newReplayBuffer(func() int {
+		return getInt(ret.settings, "sse_replay_buffer_size", defaultReplayBufferSize)
+	})
+
 	GlobalEventService = ret
 	return ret
 }
@@ -102,8 +115,10 @@ func (es *EventService) StartEventService(ctx context.Context, logger log.Logger
 	}
 
 	_, esView, _ := instantiateSvc.InstantiateFromCfg(ctx, es.cfg, es.cfgMu, "eventservice", es.addparam(map[string]interface{}{
-		"submittestevent": view.Action(MakeSubmitTestEvent(es.d.EventBus)),
+		"submittestevent": view.Action(MakeSubmitTestEvent(es.d.EventBus, es.matchingSubscriptions)),
 	}))
+	es.settings = esView.GetModel("default")
+	es.runReplayBuffer(ctx)
 	params["eventsvc_id"] = esView.GetUUID()
 	params["eventsvc_uri"] = esView.GetURI()
 	instantiateSvc.InstantiateFromCfg(ctx, es.cfg, es.cfgMu, "subscriptioncollection", es.addparam(map[string]interface{}{
@@ -114,7 +129,14 @@ func (es *EventService) StartEventService(ctx context.Context, logger log.Logger
 	eh.RegisterCommand(func() eh.Command {
 		return &POST{es: es, d: es.d}
 	})
+	// and Plugin: "EventDestinationCertificates" on each subscription's Certificates
+	eh.RegisterCommand(func() eh.Command {
+		return &CertificatesPOST{d: es.d}
+	})
+	// and also how we get asked before a subscription is deleted
+	domain.RegisterPlugin(func() domain.Plugin { return es })
 	PublishRedfishEvents(ctx, esView.GetModel("default"), es.d.EventBus)
+	es.restoreSubscriptions(ctx, logger)
 
 	return esView
 }
@@ -122,16 +144,49 @@ func (es *EventService) StartEventService(ctx context.Context, logger log.Logger
 // CreateSubscription will create a model, view, and controller for the subscription
 //      If you want to save settings, hook up a mapper to the "default" view returned
 func (es *EventService) CreateSubscription(ctx context.Context, logger log.Logger, sub Subscription, cancel func()) *view.View {
+	return es.createSubscription(ctx, logger, sub, cancel, "")
+}
+
+// createSubscription does the work for CreateSubscription. id is the uuid, and
+// so the Id, for the subscription, or "" for a new one.
+func (es *EventService) createSubscription(ctx context.Context, logger log.Logger, sub Subscription, cancel func(), id eh.UUID) *view.View {
+	filter := sub.Filter()
+	originResources := []map[string]interface{}{}
+	for _, o := range filter.OriginResources {
+		originResources = append(originResources, map[string]interface{}{"@odata.id": o})
+	}
+	eventFormatType := filter.EventFormatType
+	if eventFormatType == "" {
+		eventFormatType = "Event"
+	}
+
+	queue := newDeliveryQueue(sub.Destination, es.settings, sub.Headers())
+
 	subLogger, subView, _ := es.wrap("subscription", es.addparam(map[string]interface{}{
-		"destination": sub.Destination,
-		"protocol":    sub.Protocol,
-		"context":     sub.Context,
-		"eventTypes":  sub.EventTypes,
+		"uuid":                 id,
+		"resumesubscription":   view.Action(makeResumeSubscription(queue)),
+		"destination":          sub.Destination,
+		"protocol":             sub.Protocol,
+		"context":              sub.Context,
+		"eventTypes":           filter.EventTypes,
+		"registryPrefixes":     filter.RegistryPrefixes,
+		"resourceTypes":        filter.ResourceTypes,
+		"originResources":      originResources,
+		"messageIds":           filter.MessageIds,
+		"subordinateResources": filter.SubordinateResources,
+		"eventFormatType":      eventFormatType,
+		"verifyCertificate":    sub.VerifyCertificate,
 	}))
 
 	// set up listener for the delete event
 	// INFO: this listener will only ever get domain.RedfishResourceRemoved ExternalMetricEvent or ExternalRedfishEvent
 	uri := subView.GetURI()
+	certsURI := uri + "/Certificates"
+	_, certsView, _ := es.wrap("subscriptioncertificates", es.addparam(map[string]interface{}{
+		"collection_uri": certsURI,
+	}))
+	queue.useTLS(es.destinationTLSConfig(sub.VerifyCertificate, sub.Destination, certsURI))
+
 	listener, err := es.ew.Listen(ctx,
 		func(event eh.Event) bool {
 			t := event.EventType()
@@ -165,16 +220,24 @@ func (es *EventService) CreateSubscription(ctx context.Context, logger log.Logge
 	ctex := esModel.GetProperty("context").(string)
 
 	subCtx := SubscriptionCtx{
-		true,
-		dest,
-		prot,
-		[]string{},
-		ctex,
+		firstEvent:  true,
+		Destination: dest,
+		Protocol:    prot,
+		Context:     ctex,
+		EventFilter: filter,
+		queue:       queue,
 	}
-	subCtx.EventTypes = append(subCtx.EventTypes, sub.EventTypes...)
+	queue.attach(esModel, subView.GetModel("stats"))
 
 	uuid := subView.GetUUID()
 
+	es.subsMu.Lock()
+	es.subs[uri] = openSubscription{id: subView.GetUUID(), queue: queue, certsURI: certsURI, Subscription: sub}
+	es.subsMu.Unlock()
+	if sub.Protocol != "SSE" {
+		es.saveSubscriptions()
+	}
+
 	logS := fmt.Sprintf("%s -- New Subscription created for uri=%s, prot=%s,eventT=%v?\n",
 		time.Now().UTC().Format(time.UnixDate),
 		dest,
@@ -182,12 +245,26 @@ func (es *EventService) CreateSubscription(ctx context.Context, logger log.Logge
 		subCtx.EventTypes)
 	logToEventFile(logS)
 
+	if prot == "Redfish" {
+		go queue.run(ctx)
+	}
+
 	go func() {
 		// close the view when we exit this goroutine
 		defer subView.Close()
 		// delete the aggregate
 		defer es.d.CommandHandler.HandleCommand(context.Background(), &domain.RemoveRedfishResource{ID: subView.GetUUID(), ResourceURI: subView.GetURI()})
 		defer listener.Close()
+		defer es.removeCertificates(certsView)
+		defer func() {
+			es.subsMu.Lock()
+			delete(es.subs, uri)
+			es.subsMu.Unlock()
+			// everything is closed on the way down, and that shouldn't forget the subscriptions
+			if sub.Protocol != "SSE" && es.ctx.Err() == nil {
+				es.saveSubscriptions()
+			}
+		}()
 
 		for {
 			select {
@@ -212,6 +289,98 @@ func (es *EventService) CreateSubscription(ctx context.Context, logger log.Logge
 	return subView
 }
 
+// removeCertificates cleans up the Certificates collection when a subscription goes away
+func (es *EventService) removeCertificates(certsView *view.View) {
+	certsURI := certsView.GetURI()
+	for _, uri := range es.d.FindMatchingURIs(func(uri string) bool { return path.Dir(uri) == certsURI }) {
+		es.d.CommandHandler.HandleCommand(context.Background(), &domain.RemoveRedfishResource{ID: es.d.GetAggregateID(uri), ResourceURI: uri})
+	}
+	es.d.CommandHandler.HandleCommand(context.Background(), &domain.RemoveRedfishResource{ID: certsView.GetUUID(), ResourceURI: certsURI})
+	certsView.Close()
+}
+
+// findDuplicate returns the uri of an open subscription that would get the
+// same events at the same place as sub. SSE streams are never duplicates.
+func (es *EventService) findDuplicate(sub Subscription) (string, bool) {
+	if sub.Protocol == "SSE" {
+		return "", false
+	}
+	es.subsMu.Lock()
+	defer es.subsMu.Unlock()
+	for uri, open := range es.subs {
+		if open.sameAs(sub) {
+			return uri, true
+		}
+	}
+	return "", false
+}
+
+// updateDuplicate gives the open subscription at uri the HttpHeaders and
+// VerifyCertificate from sub, a POST that findDuplicate matched to it
+func (es *EventService) updateDuplicate(uri string, sub Subscription) {
+	es.subsMu.Lock()
+	open, ok := es.subs[uri]
+	if ok {
+		open.HttpHeaders = sub.HttpHeaders
+		open.VerifyCertificate = sub.VerifyCertificate
+		es.subs[uri] = open
+	}
+	es.subsMu.Unlock()
+	if !ok {
+		return
+	}
+
+	open.queue.setHeaders(sub.Headers())
+	open.queue.useTLS(es.destinationTLSConfig(sub.VerifyCertificate, open.Destination, open.certsURI))
+	if m := open.queue.model(); m != nil {
+		m.UpdateProperty("verify_certificate", sub.VerifyCertificate)
+	}
+	es.saveSubscriptions()
+}
+
+// subscriptionCount is how many subscriptions are open, SSE streams included
+func (es *EventService) subscriptionCount() int {
+	es.subsMu.Lock()
+	defer es.subsMu.Unlock()
+	return len(es.subs)
+}
+
+// matchingSubscriptions is how many subscriptions would be sent evt. SSE
+// streams count too, but their $filter is up to each connection.
+func (es *EventService) matchingSubscriptions(ctx context.Context, evt *RedfishEventData) int {
+	es.subsMu.Lock()
+	subs := []Subscription{}
+	for _, open := range es.subs {
+		subs = append(subs, open.Subscription)
+	}
+	es.subsMu.Unlock()
+
+	count := 0
+	for _, sub := range subs {
+		if sub.Protocol == "Redfish" && sub.Destination == "" {
+			continue
+		}
+		f := sub.Filter()
+		if f.WantsEvents() && len(f.FilterEvents([]*RedfishEventData{evt}, es.resourceType(ctx))) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func (es *EventService) PluginType() domain.PluginType { return domain.PluginType("EventService") }
+
+// VetoDelete keeps subscriptions for SSE streams around until the client closes the stream
+func (es *EventService) VetoDelete(ctx context.Context, a *domain.RedfishResourceAggregate) *domain.ExtendedInfo {
+	es.subsMu.Lock()
+	defer es.subsMu.Unlock()
+	if sub, ok := es.subs[a.ResourceURI]; ok && sub.Protocol == "SSE" {
+		msg := domain.ResourceInUse()
+		return &msg
+	}
+	return nil
+}
+
 func (es *EventService) evaluateEvent(log log.Logger, subCtx SubscriptionCtx, event eh.Event, cancel func(), URI string, uuid eh.UUID, ctx context.Context) {
 	log.Debug("Got internal redfish event", "event", event)
 
@@ -234,6 +403,9 @@ func (es *EventService) evaluateEvent(log log.Logger, subCtx SubscriptionCtx, ev
 		cancel()
 		return
 	case ExternalRedfishEvent:
+		if !subCtx.WantsEvents() {
+			return
+		}
 		log.Info(" redfish event processing")
 		// NOTE: we don't actually check to ensure that this is an actual ExternalRedfishEventData specifically because Metric Reports don't currently go through like this.
 
@@ -257,13 +429,16 @@ func (es *EventService) evaluateEvent(log log.Logger, subCtx SubscriptionCtx, ev
 		}
 		totalEvents = append(totalEvents, eventPtr.Events...)
 
-		eventlist = makeExternalRedfishEvent(subCtx, totalEvents, uuid)
+		eventlist = makeExternalRedfishEvent(subCtx, subCtx.FilterEvents(totalEvents, es.resourceType(ctx)), uuid)
 		if len(eventlist) == 0 {
 			return
 		}
 		es.postExternalEvent(subCtx, event, eventlist)
 
 	case ExternalMetricEvent:
+		if !subCtx.WantsMetricReports() {
+			return
+		}
 		evt := event.Data()
 		evtPtr, ok := evt.(MetricReportData)
 		if !ok {
@@ -297,96 +472,44 @@ func (es *EventService) evaluateEvent(log log.Logger, subCtx SubscriptionCtx, ev
 
 // Externally POST ExternalRedfishEvent and ExternalMetricReportEvent
 func (es *EventService) postExternalEvent(subCtx SubscriptionCtx, event eh.Event, eventlist []eventBinary) {
-	//TODO put back when MSM is Redfish Event compliant
-	select {
-	case es.jc <- func() {
-		for _, eachEvent := range eventlist {
-
-			client := &http.Client{
-				Timeout: time.Second * 5,
-				Transport: &http.Transport{
-					TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-				},
-			}
-			//Try up to 5 times to send event
-			logToEventFile(fmt.Sprintf("%s -- STARTING to send MessageId=%s to uri=%s\n", time.Now().UTC().Format(time.UnixDate), eachEvent.id, subCtx.Destination))
-			logSent := false
-			for i := 0; i < 5 && !logSent; i++ {
-				//Increasing wait between retries, first time don't wait i==0
-				time.Sleep(time.Duration(i) * 2 * time.Second)
-				req, _ := http.NewRequest("POST", subCtx.Destination, bytes.NewBuffer(eachEvent.data))
-				req.Header.Add("OData-Version", "4.0")
-				req.Header.Set("Content-Type", "application/json")
-				resp, err := client.Do(req)
-				if err != nil {
-					log.MustLogger("event_service").Crit("ERROR POSTING", "Id", eachEvent.id, "err", err)
-					logToEventFile(fmt.Sprintf("%s -- ERROR POSTING Id=%s to uri=%s attempt=%d err=%s\n", time.Now().UTC().Format(time.UnixDate), eachEvent.id, subCtx.Destination, i+1, err))
-				} else if resp.StatusCode == http.StatusOK ||
-					resp.StatusCode == http.StatusCreated ||
-					resp.StatusCode == http.StatusAccepted ||
-					resp.StatusCode == http.StatusNoContent {
-					//Got a good response end loop
-					logToEventFile(fmt.Sprintf("%s -- Success sent Id=%s to uri=%s attempt=%d HTTP Status=%d\n", time.Now().UTC().Format(time.UnixDate), eachEvent.id, subCtx.Destination, i+1, resp.StatusCode))
-					logSent = true
-				} else {
-					//Error code return
-					log.MustLogger("event_service").Crit("ERROR POSTING", "Id", eachEvent.id, "StatusCode", resp.StatusCode, "uri", subCtx.Destination)
-					logToEventFile(fmt.Sprintf("%s -- ERROR POSTING Id=%s to uri=%s attempt=%d HTTP Status=%d\n", time.Now().UTC().Format(time.UnixDate), eachEvent.id, subCtx.Destination, i+1, resp.StatusCode))
-				}
-				if resp != nil {
-					resp.Body.Close()
-				}
-			}
-			if logSent == false {
-				logToEventFile(fmt.Sprintf("%s -- FAILURE to send Id=%s to uri=%s\n", time.Now().UTC().Format(time.UnixDate), eachEvent.id, subCtx.Destination))
-				log.MustLogger("event_service").Crit("ERROR POSTING, DROPPED", "Id", eachEvent.id, "uri", subCtx.Destination)
-			}
-		}
-	}:
-	default: // drop the POST if the queue is full
-		log.MustLogger("event_service").Crit("External Event Queue Full, dropping")
-		logToEventFile(fmt.Sprintf("%s -- DROP Messages to uri=%s\n", time.Now().UTC().Format(time.UnixDate), subCtx.Destination))
-	}
+	subCtx.queue.enqueue(eventlist)
 }
 
+// makeExternalRedfishEvent marshals the events, which have already been filtered for the subscription
 func makeExternalRedfishEvent(subCtx SubscriptionCtx, events []*RedfishEventData, uuid eh.UUID) []eventBinary {
 	log.MustLogger("event_service").Info("POST!", "dest", subCtx.Destination, "redfish event data", events)
 	eventlist := []eventBinary{}
 
-	if len(subCtx.EventTypes) == 0 {
-		subCtx.EventTypes = append(subCtx.EventTypes, "Alert")
-	}
-
-	//Keep only the events in the Event Array which match the subscription
 	for _, tmpEvent := range events {
-		for _, subvalid := range subCtx.EventTypes {
-			if tmpEvent.EventType == subvalid {
-				jsonBody, err := json.Marshal(&struct {
-					Context   interface{} `json:",omitempty"`
-					MemberId  eh.UUID     `json:"MemberId"`
-					ArgsCount int         `json:"MessageArgs@odata.count"`
-					*RedfishEventData
-				}{
-					Context:          subCtx.Context,
-					MemberId:         uuid,
-					ArgsCount:        len(tmpEvent.MessageArgs),
-					RedfishEventData: tmpEvent,
-				},
-				)
-				if err == nil {
-					id := tmpEvent.MessageId
-					eb := eventBinary{
-						id,
-						jsonBody}
-
-					eventlist = append(eventlist, eb)
-				}
-			}
+		jsonBody, err := json.Marshal(&struct {
+			Context   interface{} `json:",omitempty"`
+			MemberId  eh.UUID     `json:"MemberId"`
+			ArgsCount int         `json:"MessageArgs@odata.count"`
+			*RedfishEventData
+		}{
+			Context:          subCtx.Context,
+			MemberId:         uuid,
+			ArgsCount:        len(tmpEvent.MessageArgs),
+			RedfishEventData: tmpEvent,
+		},
+		)
+		if err == nil {
+			id := tmpEvent.MessageId
+			eb := eventBinary{
+				id,
+				jsonBody}
+
+			eventlist = append(eventlist, eb)
 		}
 	}
 	return eventlist
 }
 
+// resourceType returns the lookup subscriptions use to filter on ResourceTypes
+func (es *EventService) resourceType(ctx context.Context) func(string) string {
+	return func(uri string) string { return es.d.GetResourceType(ctx, uri) }
+}
+
 func (es *EventService) PublishResourceUpdatedEventsForModel(ctx context.Context, modelName string) view.Option {
 	return view.WatchModel(modelName, func(v *view.View, m *model.Model, updates []model.Update) {
 		go func() {
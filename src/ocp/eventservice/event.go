@@ -2,6 +2,8 @@ package eventservice
 
 import (
 	eh "github.com/looplab/eventhorizon"
+
+	domain "github.com/superchalupa/sailfish/src/redfishresource"
 )
 
 const (
@@ -32,6 +34,22 @@ type RedfishEventData struct {
 	Oem               map[string]interface{} `json:",omitempty"`
 } //TODO MSM BUG: OriginOfCondition for events has to be a string or will be rejected
 
+// fillFromRegistry fills in Message and Severity from the message registries
+// for events that only have a MessageId and MessageArgs
+func (evt *RedfishEventData) fillFromRegistry() bool {
+	msg, ok := domain.LookupMessage(evt.MessageId)
+	if !ok {
+		return false
+	}
+	if evt.Message == "" {
+		evt.Message = msg.Format(evt.MessageArgs)
+	}
+	if evt.Severity == "" {
+		evt.Severity = msg.Severity
+	}
+	return true
+}
+
 type ExternalRedfishEventData struct {
 	Id      int    `json:",string"`
 	Context string `json:"@odata.context"`
@@ -0,0 +1,258 @@
+package eventservice
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	domain "github.com/superchalupa/sailfish/src/redfishresource"
+)
+
+// EventTypesForSubscription are the event types clients can subscribe to
+var EventTypesForSubscription = []string{
+	"StatusChange",
+	"ResourceUpdated",
+	"ResourceAdded",
+	"ResourceRemoved",
+	"Alert",
+}
+
+// reservedHeaders are set by the event service and can't be changed with HttpHeaders
+var reservedHeaders = map[string]bool{
+	"Content-Type":      true,
+	"Content-Length":    true,
+	"Host":              true,
+	"Odata-Version":     true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+// OdataID is a link to a resource, the way OriginResources are written
+type OdataID struct {
+	ID string `json:"@odata.id"`
+}
+
+// EventFilter is the part of a subscription that decides which events it gets.
+// Empty lists don't filter anything, except EventTypes which defaults to Alert.
+type EventFilter struct {
+	EventTypes           []string
+	RegistryPrefixes     []string
+	ResourceTypes        []string
+	OriginResources      []string
+	MessageIds           []string
+	SubordinateResources bool
+	EventFormatType      string
+}
+
+// Filter returns the EventFilter for the subscription
+func (s Subscription) Filter() EventFilter {
+	f := EventFilter{
+		EventTypes:           append([]string{}, s.EventTypes...),
+		RegistryPrefixes:     append([]string{}, s.RegistryPrefixes...),
+		ResourceTypes:        append([]string{}, s.ResourceTypes...),
+		OriginResources:      []string{},
+		MessageIds:           append([]string{}, s.MessageIds...),
+		SubordinateResources: s.SubordinateResources,
+		EventFormatType:      s.EventFormatType,
+	}
+	for _, o := range s.OriginResources {
+		f.OriginResources = append(f.OriginResources, o.ID)
+	}
+	return f
+}
+
+// Validate checks the properties of a new subscription. originExists
+// is used to make sure each of the OriginResources is there.
+func (s Subscription) Validate(originExists func(string) bool) []domain.ExtendedInfo {
+	errs := []domain.ExtendedInfo{}
+
+	switch s.EventFormatType {
+	case "", "Event", "MetricReport":
+	default:
+		errs = append(errs, domain.PropertyValueNotInList(s.EventFormatType, "EventFormatType"))
+	}
+
+	for i, t := range s.EventTypes {
+		if !contains(EventTypesForSubscription, t) {
+			errs = append(errs, domain.PropertyValueNotInList(t, "EventTypes/"+strconv.Itoa(i)))
+		}
+	}
+
+	for i, p := range s.RegistryPrefixes {
+		if p == "" || strings.Contains(p, ".") {
+			errs = append(errs, domain.PropertyValueFormatError(p, "RegistryPrefixes/"+strconv.Itoa(i)))
+		}
+	}
+
+	for i, m := range s.MessageIds {
+		if m == "" || strings.HasPrefix(m, ".") || strings.HasSuffix(m, ".") {
+			errs = append(errs, domain.PropertyValueFormatError(m, "MessageIds/"+strconv.Itoa(i)))
+		}
+	}
+
+	for i, t := range s.ResourceTypes {
+		if t == "" || strings.ContainsAny(t, "#./ ") {
+			errs = append(errs, domain.PropertyValueFormatError(t, "ResourceTypes/"+strconv.Itoa(i)))
+		}
+	}
+
+	for i, o := range s.OriginResources {
+		property := "OriginResources/" + strconv.Itoa(i)
+		if !strings.HasPrefix(o.ID, "/redfish/v1") {
+			errs = append(errs, domain.PropertyValueFormatError(o.ID, property))
+		} else if !originExists(o.ID) {
+			errs = append(errs, domain.PropertyValueNotInList(o.ID, property))
+		}
+	}
+
+	for i, h := range s.HttpHeaders {
+		for name, value := range h {
+			property := "HttpHeaders/" + strconv.Itoa(i) + "/" + name
+			// header values are usually secrets, so don't echo them back
+			if name == "" || strings.ContainsAny(name, " \t\r\n:()<>@,;\\\"/[]?={}") || strings.ContainsAny(value, "\r\n") {
+				errs = append(errs, domain.PropertyValueFormatError(name, property))
+			} else if reservedHeaders[http.CanonicalHeaderKey(name)] {
+				errs = append(errs, domain.PropertyValueNotInList(name, property))
+			}
+		}
+	}
+
+	return errs
+}
+
+// sameAs is true if both subscriptions send the same events to the same
+// place. The order of the filter lists doesn't matter. HttpHeaders and
+// VerifyCertificate aren't compared, a duplicate POST updates them instead.
+func (s Subscription) sameAs(o Subscription) bool {
+	return s.Destination == o.Destination &&
+		s.Protocol == o.Protocol &&
+		s.Context == o.Context &&
+		reflect.DeepEqual(s.Filter().normalized(), o.Filter().normalized())
+}
+
+// normalized fills in the defaults and sorts the lists, so filters that do the
+// same thing compare equal. The lists are copied first, f's are left alone.
+func (f EventFilter) normalized() EventFilter {
+	if len(f.EventTypes) == 0 {
+		f.EventTypes = []string{"Alert"}
+	}
+	if f.EventFormatType == "" {
+		f.EventFormatType = "Event"
+	}
+	origins := []string{}
+	for _, o := range f.OriginResources {
+		origins = append(origins, strings.TrimRight(o, "/"))
+	}
+	f.OriginResources = origins
+	f.EventTypes = append([]string{}, f.EventTypes...)
+	f.RegistryPrefixes = append([]string{}, f.RegistryPrefixes...)
+	f.ResourceTypes = append([]string{}, f.ResourceTypes...)
+	f.MessageIds = append([]string{}, f.MessageIds...)
+	for _, l := range [][]string{f.EventTypes, f.RegistryPrefixes, f.ResourceTypes, f.OriginResources, f.MessageIds} {
+		sort.Strings(l)
+	}
+	return f
+}
+
+// WantsEvents is false for subscriptions that only want metric reports
+func (f EventFilter) WantsEvents() bool {
+	return f.EventFormatType != "MetricReport"
+}
+
+// WantsMetricReports is false for subscriptions that only want events
+func (f EventFilter) WantsMetricReports() bool {
+	return f.EventFormatType != "Event"
+}
+
+// FilterEvents returns the events the subscription wants, leaving the list it
+// was passed alone. resourceType looks up the schema name for the
+// OriginOfCondition, and is only called if the subscription has ResourceTypes.
+func (f EventFilter) FilterEvents(events []*RedfishEventData, resourceType func(string) string) []*RedfishEventData {
+	if len(f.EventTypes) == 0 {
+		f.EventTypes = []string{"Alert"}
+	}
+
+	ret := []*RedfishEventData{}
+	for _, evt := range events {
+		if f.matches(evt, resourceType) {
+			ret = append(ret, evt)
+		}
+	}
+	return ret
+}
+
+func (f EventFilter) matches(evt *RedfishEventData, resourceType func(string) string) bool {
+	if !contains(f.EventTypes, evt.EventType) {
+		return false
+	}
+
+	if len(f.RegistryPrefixes) > 0 && !contains(f.RegistryPrefixes, registryPrefix(evt.MessageId)) {
+		return false
+	}
+
+	if len(f.MessageIds) > 0 && !f.matchesMessageId(evt.MessageId) {
+		return false
+	}
+
+	if len(f.OriginResources) > 0 && !f.matchesOrigin(evt.OriginOfCondition) {
+		return false
+	}
+
+	if len(f.ResourceTypes) > 0 && !contains(f.ResourceTypes, resourceType(evt.OriginOfCondition)) {
+		return false
+	}
+
+	return true
+}
+
+// matchesMessageId compares "Registry.Key" or "Registry.Major.Minor.Key", so
+// subscriptions don't have to care about the registry version
+func (f EventFilter) matchesMessageId(id string) bool {
+	for _, want := range f.MessageIds {
+		if want == id {
+			return true
+		}
+		if registryPrefix(want) != "" && registryPrefix(want) == registryPrefix(id) && messageKey(want) == messageKey(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f EventFilter) matchesOrigin(origin string) bool {
+	origin = strings.TrimRight(origin, "/")
+	for _, o := range f.OriginResources {
+		o = strings.TrimRight(o, "/")
+		if origin == o {
+			return true
+		}
+		if f.SubordinateResources && strings.HasPrefix(origin, o+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// registryPrefix returns "Base" for "Base.1.0.Success", and "" for message ids without a registry
+func registryPrefix(id string) string {
+	if i := strings.Index(id, "."); i > 0 {
+		return id[:i]
+	}
+	return ""
+}
+
+// messageKey returns "Success" for "Base.1.0.Success"
+func messageKey(id string) string {
+	return id[strings.LastIndex(id, ".")+1:]
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
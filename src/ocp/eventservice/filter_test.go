@@ -0,0 +1,235 @@
+package eventservice
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/superchalupa/sailfish/src/ocp/model"
+)
+
+func TestEventFilter(t *testing.T) {
+	events := []*RedfishEventData{
+		{EventType: "Alert", MessageId: "Base.1.0.ResourceCreated", OriginOfCondition: "/redfish/v1/Chassis/System.Modular.1"},
+		{EventType: "Alert", MessageId: "iDRAC.2.0.CMC8550", OriginOfCondition: "/redfish/v1/Chassis/System.Modular.1/Power"},
+		{EventType: "ResourceUpdated", MessageId: "TST100", OriginOfCondition: "/redfish/v1/Managers/CMC.Integrated.1"},
+	}
+	resourceType := func(uri string) string {
+		return map[string]string{
+			"/redfish/v1/Chassis/System.Modular.1":       "Chassis",
+			"/redfish/v1/Chassis/System.Modular.1/Power": "Power",
+			"/redfish/v1/Managers/CMC.Integrated.1":      "Manager",
+		}[uri]
+	}
+
+	var tests = []struct {
+		testname string
+		sub      Subscription
+		expected []int
+	}{
+		{"defaults to Alert", Subscription{}, []int{0, 1}},
+		{"event types", Subscription{EventTypes: []string{"ResourceUpdated"}}, []int{2}},
+		{"registry prefix", Subscription{RegistryPrefixes: []string{"iDRAC"}}, []int{1}},
+		{"message id without version", Subscription{MessageIds: []string{"Base.ResourceCreated"}}, []int{0}},
+		{"message id without registry", Subscription{EventTypes: []string{"ResourceUpdated"}, MessageIds: []string{"TST100"}}, []int{2}},
+		{"resource type", Subscription{ResourceTypes: []string{"Power"}}, []int{1}},
+		{"origin", Subscription{OriginResources: []OdataID{{"/redfish/v1/Chassis/System.Modular.1"}}}, []int{0}},
+		{"subordinate", Subscription{OriginResources: []OdataID{{"/redfish/v1/Chassis/System.Modular.1"}}, SubordinateResources: true}, []int{0, 1}},
+		{"all must match", Subscription{RegistryPrefixes: []string{"Base"}, ResourceTypes: []string{"Power"}}, []int{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testname, func(t *testing.T) {
+			expected := []*RedfishEventData{}
+			for _, i := range tc.expected {
+				expected = append(expected, events[i])
+			}
+			assert.Equal(t, expected, tc.sub.Filter().FilterEvents(events, resourceType))
+		})
+	}
+
+	assert.False(t, Subscription{EventFormatType: "MetricReport"}.Filter().WantsEvents())
+	assert.False(t, Subscription{EventFormatType: "Event"}.Filter().WantsMetricReports())
+	assert.True(t, Subscription{}.Filter().WantsMetricReports())
+}
+
+func TestValidateSubscription(t *testing.T) {
+	exists := func(uri string) bool { return uri == "/redfish/v1/Chassis/System.Modular.1" }
+
+	assert.Empty(t, Subscription{
+		EventTypes:       []string{"Alert"},
+		RegistryPrefixes: []string{"Base"},
+		MessageIds:       []string{"Base.1.0.ResourceCreated"},
+		ResourceTypes:    []string{"Chassis"},
+		OriginResources:  []OdataID{{"/redfish/v1/Chassis/System.Modular.1"}},
+		EventFormatType:  "Event",
+	}.Validate(exists))
+
+	errs := Subscription{
+		EventTypes:       []string{"Bogus"},
+		RegistryPrefixes: []string{"Base.1.0"},
+		ResourceTypes:    []string{"#Chassis.v1_0_0.Chassis"},
+		OriginResources:  []OdataID{{"/redfish/v1/Chassis/Nope"}, {"Chassis"}},
+		EventFormatType:  "Syslog",
+	}.Validate(exists)
+	related := []string{}
+	for _, e := range errs {
+		related = append(related, e.RelatedProperties...)
+	}
+	assert.ElementsMatch(t, []string{"#/EventFormatType", "#/EventTypes/0", "#/RegistryPrefixes/0", "#/ResourceTypes/0", "#/OriginResources/0", "#/OriginResources/1"}, related)
+}
+
+func TestSSEFilter(t *testing.T) {
+	events := []*RedfishEventData{
+		{EventType: "Alert", MessageId: "Base.1.0.ResourceCreated", OriginOfCondition: "/redfish/v1/Chassis/System.Modular.1"},
+		{EventType: "Alert", MessageId: "iDRAC.2.0.CMC8550", OriginOfCondition: "/redfish/v1/Chassis/System.Modular.1/Power"},
+		{EventType: "ResourceUpdated", MessageId: "TST100", OriginOfCondition: "/redfish/v1/Managers/CMC.Integrated.1"},
+	}
+	resourceType := func(uri string) string {
+		return map[string]string{
+			"/redfish/v1/Chassis/System.Modular.1":       "Chassis",
+			"/redfish/v1/Chassis/System.Modular.1/Power": "Power",
+			"/redfish/v1/Managers/CMC.Integrated.1":      "Manager",
+		}[uri]
+	}
+	report := map[string]interface{}{
+		"Id":                     "PowerStatistics",
+		"MetricReportDefinition": map[string]interface{}{"@odata.id": "/redfish/v1/TelemetryService/MetricReportDefinitions/PowerStatistics"},
+	}
+
+	var tests = []struct {
+		testname string
+		filter   string
+		expected []int
+		report   bool
+	}{
+		{"no filter", "", []int{0, 1, 2}, true},
+		{"event type", "EventType eq 'ResourceUpdated'", []int{2}, false},
+		{"message id", "MessageId eq 'Base.ResourceCreated'", []int{0}, false},
+		{"registry prefix", "RegistryPrefix eq 'iDRAC'", []int{1}, false},
+		{"resource type", "ResourceType eq 'Power'", []int{1}, false},
+		{"origin", "OriginResource eq '/redfish/v1/Chassis/System.Modular.1'", []int{0}, false},
+		{"subordinate", "OriginResource eq '/redfish/v1/Chassis/System.Modular.1' and SubordinateResources eq true", []int{0, 1}, false},
+		{"report by id", "MetricReportDefinition eq 'PowerStatistics'", []int{}, true},
+		{"report by uri", "MetricReportDefinition eq '/redfish/v1/TelemetryService/MetricReportDefinitions/PowerStatistics'", []int{}, true},
+		{"or", "(EventType eq 'ResourceUpdated') or (MetricReportDefinition eq 'PowerStatistics')", []int{2}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testname, func(t *testing.T) {
+			f, err := ParseSSEFilter(tc.filter)
+			assert.Nil(t, err)
+			expected := []*RedfishEventData{}
+			for _, i := range tc.expected {
+				expected = append(expected, events[i])
+			}
+			if tc.filter == "" {
+				expected = events
+			}
+			assert.Equal(t, expected, f.FilterEvents(events, resourceType))
+			assert.Equal(t, tc.report, f.MatchMetricReport(report))
+		})
+	}
+
+	_, err := ParseSSEFilter("Severity eq 'Critical'")
+	assert.NotNil(t, err)
+	_, err = ParseSSEFilter("EventType eq")
+	assert.NotNil(t, err)
+}
+
+func TestDuplicateSubscription(t *testing.T) {
+	es := &EventService{subs: map[string]openSubscription{
+		"/redfish/v1/EventService/Subscriptions/1": {id: "1", Subscription: Subscription{
+			Destination:      "https://console.example.com/events",
+			Protocol:         "Redfish",
+			EventTypes:       []string{"Alert", "StatusChange"},
+			RegistryPrefixes: []string{"Base", "iDRAC"},
+			OriginResources:  []OdataID{{"/redfish/v1/Chassis/System.Modular.1/"}},
+		}},
+		"/redfish/v1/EventService/Subscriptions/2": {id: "2", Subscription: Subscription{Protocol: "SSE"}},
+	}}
+
+	var tests = []struct {
+		testname string
+		sub      Subscription
+		expected bool
+	}{
+		{"same, different order", Subscription{
+			Destination:      "https://console.example.com/events",
+			Protocol:         "Redfish",
+			EventTypes:       []string{"StatusChange", "Alert"},
+			RegistryPrefixes: []string{"iDRAC", "Base"},
+			OriginResources:  []OdataID{{"/redfish/v1/Chassis/System.Modular.1"}},
+			EventFormatType:  "Event",
+		}, true},
+		{"different filter", Subscription{
+			Destination: "https://console.example.com/events",
+			Protocol:    "Redfish",
+			EventTypes:  []string{"StatusChange", "Alert"},
+		}, false},
+		{"different context", Subscription{
+			Destination:      "https://console.example.com/events",
+			Protocol:         "Redfish",
+			Context:          "second",
+			EventTypes:       []string{"StatusChange", "Alert"},
+			RegistryPrefixes: []string{"iDRAC", "Base"},
+			OriginResources:  []OdataID{{"/redfish/v1/Chassis/System.Modular.1"}},
+		}, false},
+		{"other headers", Subscription{
+			Destination:      "https://console.example.com/events",
+			Protocol:         "Redfish",
+			EventTypes:       []string{"StatusChange", "Alert"},
+			RegistryPrefixes: []string{"iDRAC", "Base"},
+			OriginResources:  []OdataID{{"/redfish/v1/Chassis/System.Modular.1"}},
+			HttpHeaders:      []map[string]string{{"Authorization": "Bearer new"}},
+		}, true},
+		{"other certificate checking", Subscription{
+			Destination:       "https://console.example.com/events",
+			Protocol:          "Redfish",
+			EventTypes:        []string{"StatusChange", "Alert"},
+			RegistryPrefixes:  []string{"iDRAC", "Base"},
+			OriginResources:   []OdataID{{"/redfish/v1/Chassis/System.Modular.1"}},
+			VerifyCertificate: true,
+		}, true},
+		{"sse streams", Subscription{Protocol: "SSE"}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testname, func(t *testing.T) {
+			uri, found := es.findDuplicate(tc.sub)
+			assert.Equal(t, tc.expected, found)
+			if tc.expected {
+				assert.Equal(t, "/redfish/v1/EventService/Subscriptions/1", uri)
+			}
+		})
+	}
+	assert.Equal(t, 2, es.subscriptionCount())
+
+	f := EventFilter{EventTypes: []string{"StatusChange", "Alert"}, OriginResources: []string{"/redfish/v1/Chassis/System.Modular.1/"}}
+	n := f.normalized()
+	assert.Equal(t, []string{"Alert", "StatusChange"}, n.EventTypes)
+	assert.Equal(t, []string{"/redfish/v1/Chassis/System.Modular.1"}, n.OriginResources)
+	assert.Equal(t, []string{"StatusChange", "Alert"}, f.EventTypes, "the lists being compared are left alone")
+	assert.Equal(t, []string{"/redfish/v1/Chassis/System.Modular.1/"}, f.OriginResources)
+
+	// the console POSTs again with a new token
+	queue := newDeliveryQueue("https://console.example.com/events", nil, map[string]string{"Authorization": "Bearer old"})
+	subModel := model.New()
+	queue.attach(subModel, nil)
+	es.cfg = viper.New()
+	es.cfgMu = &sync.RWMutex{}
+	open := es.subs["/redfish/v1/EventService/Subscriptions/1"]
+	open.queue = queue
+	es.subs["/redfish/v1/EventService/Subscriptions/1"] = open
+	dup := open.Subscription
+	dup.HttpHeaders = []map[string]string{{"Authorization": "Bearer new"}}
+	dup.VerifyCertificate = true
+	es.updateDuplicate("/redfish/v1/EventService/Subscriptions/1", dup)
+
+	assert.Equal(t, map[string]string{"Authorization": "Bearer new"}, queue.headers)
+	assert.True(t, es.subs["/redfish/v1/EventService/Subscriptions/1"].VerifyCertificate)
+	assert.Equal(t, dup.HttpHeaders, es.subs["/redfish/v1/EventService/Subscriptions/1"].HttpHeaders)
+	assert.Equal(t, true, subModel.GetProperty("verify_certificate"))
+}
@@ -0,0 +1,141 @@
+package eventservice
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	eh "github.com/looplab/eventhorizon"
+
+	"github.com/superchalupa/sailfish/src/log"
+)
+
+// openSubscription is a subscription the event service is delivering to
+type openSubscription struct {
+	id       eh.UUID
+	queue    *deliveryQueue
+	certsURI string
+	Subscription
+}
+
+// savedSubscription is how a subscription is written to the subscription store
+type savedSubscription struct {
+	Id eh.UUID
+	Subscription
+}
+
+var saveMu sync.Mutex
+
+// defaultSubscriptionStore is where subscriptions are kept when main.subscription_store isn't set
+const defaultSubscriptionStore = "/var/lib/sailfish/subscriptions.json"
+
+// subscriptionStore is the file that subscriptions are kept in, or "" to not keep them
+func (es *EventService) subscriptionStore() string {
+	es.cfgMu.RLock()
+	defer es.cfgMu.RUnlock()
+	if !es.cfg.IsSet("main.subscription_store") {
+		return defaultSubscriptionStore
+	}
+	return es.cfg.GetString("main.subscription_store")
+}
+
+// saveSubscriptions writes out every subscription except the ones for SSE
+// streams, which go away with the stream anyway. The domain db is thrown away
+// at startup, so this is what brings the subscriptions back.
+func (es *EventService) saveSubscriptions() {
+	store := es.subscriptionStore()
+	if store == "" {
+		return
+	}
+
+	saved := []savedSubscription{}
+	es.subsMu.Lock()
+	for _, open := range es.subs {
+		if open.Protocol != "SSE" {
+			saved = append(saved, savedSubscription{Id: open.id, Subscription: open.Subscription})
+		}
+	}
+	es.subsMu.Unlock()
+	sort.Slice(saved, func(i, j int) bool { return saved[i].Id < saved[j].Id })
+
+	saveMu.Lock()
+	defer saveMu.Unlock()
+	if err := writeSubscriptions(store, saved); err != nil {
+		log.MustLogger("event_service").Crit("Could not save subscriptions", "file", store, "err", err)
+	}
+}
+
+// writeSubscriptions replaces the store in one go, so a crash can't leave half
+// a file. HttpHeaders are usually credentials, so they aren't written out. A
+// restored subscription goes without them until the client POSTs it again,
+// which updates the one that was restored.
+func writeSubscriptions(store string, saved []savedSubscription) error {
+	stripped := make([]savedSubscription, 0, len(saved))
+	for _, s := range saved {
+		s.HttpHeaders = nil
+		stripped = append(stripped, s)
+	}
+	data, err := json.MarshalIndent(stripped, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(store), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(store), filepath.Base(store)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), store)
+}
+
+func readSubscriptions(store string) ([]savedSubscription, error) {
+	saved := []savedSubscription{}
+	data, err := ioutil.ReadFile(store)
+	if os.IsNotExist(err) {
+		return saved, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &saved)
+	return saved, err
+}
+
+// restoreSubscriptions brings back the subscriptions from before a restart,
+// with the same Id. Creating them sends ResourceAdded like any new resource.
+func (es *EventService) restoreSubscriptions(ctx context.Context, logger log.Logger) {
+	store := es.subscriptionStore()
+	if store == "" {
+		return
+	}
+
+	saved, err := readSubscriptions(store)
+	if err != nil {
+		logger.Crit("Could not read saved subscriptions", "file", store, "err", err)
+		return
+	}
+
+	for _, s := range saved {
+		// a subscription made before a schema or validation change might not make it back
+		if errs := s.Validate(func(string) bool { return true }); len(errs) > 0 || s.Id == "" {
+			logger.Warn("Dropping saved subscription that is no longer valid", "id", s.Id, "destination", s.Destination)
+			continue
+		}
+		logger.Info("Restoring subscription", "id", s.Id, "destination", s.Destination)
+		subCtx, cancel := context.WithCancel(ctx)
+		es.createSubscription(subCtx, logger, s.Subscription, cancel, s.Id)
+	}
+}
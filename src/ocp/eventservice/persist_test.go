@@ -0,0 +1,56 @@
+package eventservice
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionStore(t *testing.T) {
+	es := &EventService{cfg: viper.New(), cfgMu: &sync.RWMutex{}}
+	assert.Equal(t, defaultSubscriptionStore, es.subscriptionStore())
+	es.cfg.Set("main.subscription_store", "")
+	assert.Equal(t, "", es.subscriptionStore(), "not saved at all")
+
+	dir, err := ioutil.TempDir("", "subscriptions")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	// the state directory is made if it isn't there
+	store := filepath.Join(dir, "state", "subscriptions.json")
+
+	saved, err := readSubscriptions(store)
+	assert.Nil(t, err)
+	assert.Empty(t, saved)
+
+	expected := []savedSubscription{{
+		Id: "4f8a7a3c-0000-4000-8000-000000000001",
+		Subscription: Subscription{
+			Destination:     "https://console.example.com/events",
+			Protocol:        "Redfish",
+			EventTypes:      []string{"Alert"},
+			OriginResources: []OdataID{{"/redfish/v1/Chassis/System.Modular.1"}},
+			HttpHeaders:     []map[string]string{{"Authorization": "Bearer sekrit"}},
+		},
+	}}
+	assert.Nil(t, writeSubscriptions(store, expected))
+
+	info, err := os.Stat(store)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	saved, err = readSubscriptions(store)
+	assert.Nil(t, err)
+	assert.Nil(t, saved[0].HttpHeaders, "credentials aren't written out")
+	assert.NotNil(t, expected[0].HttpHeaders, "or dropped from the open subscription")
+	expected[0].HttpHeaders = nil
+	assert.Equal(t, expected, saved)
+
+	// nothing left behind from the write
+	files, _ := ioutil.ReadDir(filepath.Dir(store))
+	assert.Len(t, files, 1)
+}
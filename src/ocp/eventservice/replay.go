@@ -0,0 +1,159 @@
+package eventservice
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+
+	"github.com/superchalupa/sailfish/src/log"
+)
+
+const defaultReplayBufferSize = 200
+
+// replayBuffer keeps the most recent ExternalRedfishEvent and metric report
+// data so that SSE clients that reconnect with Last-Event-ID can pick up where
+// they left off. Only events have ids, so each metric report remembers the id
+// of the event before it and is replayed along with the events after that.
+type replayBuffer struct {
+	mu      sync.Mutex
+	size    func() int
+	entries []replayEntry
+
+	// the newest id that has been seen, so ids that are too new can be caught
+	lastId int
+
+	// what has been pushed out of the buffer, to know when a replay has a gap
+	droppedEventId     int
+	droppedEvents      bool
+	droppedMetricAfter int
+	droppedMetrics     bool
+}
+
+type replayEntry struct {
+	id      int
+	isEvent bool
+	data    eh.EventData
+}
+
+func newReplayBuffer(size func() int) *replayBuffer {
+	return &replayBuffer{size: size, lastId: -1}
+}
+
+// add puts ExternalRedfishEventData or MetricReportData in the buffer
+func (b *replayBuffer) add(data eh.EventData) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch evt := data.(type) {
+	case *ExternalRedfishEventData:
+		b.entries = append(b.entries, replayEntry{id: evt.Id, isEvent: true, data: evt})
+		b.lastId = evt.Id
+	case MetricReportData:
+		b.entries = append(b.entries, replayEntry{id: b.lastId, data: evt})
+	case *MetricReportData:
+		b.entries = append(b.entries, replayEntry{id: b.lastId, data: *evt})
+	default:
+		return
+	}
+
+	size := b.size()
+	if size < 0 {
+		size = 0
+	}
+	for len(b.entries) > size {
+		old := b.entries[0]
+		if old.isEvent {
+			b.droppedEventId = old.id
+			b.droppedEvents = true
+		} else {
+			b.droppedMetricAfter = old.id
+			b.droppedMetrics = true
+		}
+		b.entries[0] = replayEntry{}
+		b.entries = b.entries[1:]
+	}
+}
+
+// since returns what came after the event with id lastEventId, oldest first.
+// gap is true if some of it was already pushed out of the buffer, or if the
+// id is newer than anything seen, which happens when the service restarts.
+// Then everything still in the buffer is returned.
+func (b *replayBuffer) since(lastEventId int) (ret []eh.EventData, gap bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	gap = (b.droppedEvents && b.droppedEventId > lastEventId) ||
+		(b.droppedMetrics && b.droppedMetricAfter >= lastEventId) ||
+		lastEventId > b.lastId
+
+	ret = []eh.EventData{}
+	for _, e := range b.entries {
+		if gap || (e.isEvent && e.id > lastEventId) || (!e.isEvent && e.id >= lastEventId) {
+			ret = append(ret, e.data)
+		}
+	}
+	return ret, gap
+}
+
+// runReplayBuffer fills the buffer from the event service until the context is done
+func (es *EventService) runReplayBuffer(ctx context.Context) {
+	listener, err := es.ew.Listen(ctx, func(event eh.Event) bool {
+		return event.EventType() == ExternalRedfishEvent || event.EventType() == ExternalMetricEvent
+	})
+	if err != nil {
+		log.MustLogger("event_service").Crit("Could not listen for events to replay", "err", err)
+		return
+	}
+	listener.Name = "sse replay buffer"
+
+	go func() {
+		defer listener.Close()
+		for {
+			event, err := listener.Wait(ctx)
+			if err != nil {
+				return
+			}
+			es.replay.add(event.Data())
+		}
+	}()
+}
+
+// Replay returns the external events and metric reports sent after
+// lastEventId, for SSE clients that reconnect with Last-Event-ID. If some
+// were lost, gap is set and the caller should let the client know.
+func (es *EventService) Replay(lastEventId int) ([]eh.EventData, bool) {
+	return es.replay.since(lastEventId)
+}
+
+// ReplayKey identifies external event data, so that an SSE stream can skip
+// live events that it already sent from the replay
+func ReplayKey(data eh.EventData) interface{} {
+	switch evt := data.(type) {
+	case *ExternalRedfishEventData:
+		return evt.Id
+	case MetricReportData:
+		// the same report is sent to everyone, so the map is the same
+		return reflect.ValueOf(evt.Data).Pointer()
+	}
+	return nil
+}
+
+// EventBufferExceeded is sent to an SSE client in place of the events it
+// missed when they are no longer around to replay
+func EventBufferExceeded() *ExternalRedfishEventData {
+	return &ExternalRedfishEventData{
+		Context: "/redfish/v1/$metadata#Event.Event",
+		Name:    "Event Array",
+		Type:    "#Event.v1_1_0.Event",
+		Events: []*RedfishEventData{{
+			EventType:      "Alert",
+			EventTimestamp: time.Now().UTC().Format(time.RFC3339),
+			Severity:       "Warning",
+			Message:        "The event buffer exceeded its maximum size, so one or more events were dropped.",
+			MessageId:      "Sailfish.1.0.EventBufferExceeded",
+		}},
+	}
+}
@@ -0,0 +1,53 @@
+package eventservice
+
+import (
+	"testing"
+
+	eh "github.com/looplab/eventhorizon"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayBuffer(t *testing.T) {
+	size := 4
+	b := newReplayBuffer(func() int { return size })
+
+	evts := []*ExternalRedfishEventData{{Id: 0}, {Id: 1}, {Id: 2}, {Id: 3}}
+	report := MetricReportData{Data: map[string]interface{}{"Id": "PowerStatistics"}}
+
+	b.add(evts[0])
+	b.add(evts[1])
+	b.add(report)
+	b.add(evts[2])
+
+	missed, gap := b.since(1)
+	assert.False(t, gap)
+	assert.Equal(t, []eh.EventData{report, evts[2]}, missed)
+
+	missed, gap = b.since(2)
+	assert.False(t, gap)
+	assert.Equal(t, []eh.EventData{}, missed)
+
+	// ids newer than anything sent mean the service restarted
+	missed, gap = b.since(7)
+	assert.True(t, gap)
+	assert.Len(t, missed, 4)
+
+	// push event 0 out
+	b.add(evts[3])
+	_, gap = b.since(0)
+	assert.False(t, gap)
+	missed, gap = b.since(-1)
+	assert.True(t, gap)
+	assert.Equal(t, []eh.EventData{evts[1], report, evts[2], evts[3]}, missed)
+
+	// push event 1 and the report out
+	b.add(&ExternalRedfishEventData{Id: 4})
+	b.add(&ExternalRedfishEventData{Id: 5})
+	_, gap = b.since(1)
+	assert.True(t, gap)
+	_, gap = b.since(2)
+	assert.False(t, gap)
+
+	assert.Equal(t, 3, ReplayKey(evts[3]))
+	assert.Equal(t, ReplayKey(report), ReplayKey(MetricReportData{Data: report.Data}))
+}
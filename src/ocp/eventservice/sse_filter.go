@@ -0,0 +1,124 @@
+package eventservice
+
+import (
+	"errors"
+	"path"
+	"strings"
+
+	domain "github.com/superchalupa/sailfish/src/redfishresource"
+)
+
+// sseFilterProperties are the properties DSP0266 allows in $filter on the SSE URI
+var sseFilterProperties = []string{
+	"EventType",
+	"MessageId",
+	"MetricReportDefinition",
+	"OriginResource",
+	"RegistryPrefix",
+	"ResourceType",
+	"SubordinateResources",
+}
+
+// SSEFilter is the $filter on an SSE stream. A nil SSEFilter matches everything.
+type SSEFilter struct {
+	filter       *domain.Filter
+	subordinate  bool
+	resourceType bool
+}
+
+// ParseSSEFilter parses the $filter query parameter from the SSE URI. It
+// returns nil if there isn't one.
+func ParseSSEFilter(filter string) (*SSEFilter, error) {
+	if filter == "" {
+		return nil, nil
+	}
+	f, err := domain.ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	sf := &SSEFilter{filter: f}
+	for _, p := range f.Properties() {
+		if !contains(sseFilterProperties, p) {
+			return nil, errors.New("can't filter the event stream on " + p)
+		}
+		if p == "ResourceType" {
+			sf.resourceType = true
+		}
+	}
+	// "OriginResource eq X and SubordinateResources eq true" also matches
+	// everything under X, so OriginResource has to know about it
+	sf.subordinate = f.Compares("SubordinateResources", true)
+	return sf, nil
+}
+
+// FilterEvents returns the events that match, leaving the list it was passed
+// alone. resourceType is only called if the filter uses ResourceType.
+func (f *SSEFilter) FilterEvents(events []*RedfishEventData, resourceType func(string) string) []*RedfishEventData {
+	if f == nil {
+		return events
+	}
+	ret := []*RedfishEventData{}
+	for _, evt := range events {
+		if f.MatchEvent(evt, resourceType) {
+			ret = append(ret, evt)
+		}
+	}
+	return ret
+}
+
+// MatchEvent checks one event against the filter
+func (f *SSEFilter) MatchEvent(evt *RedfishEventData, resourceType func(string) string) bool {
+	if f == nil {
+		return true
+	}
+
+	// MessageId matches with or without the registry version
+	messageIds := []interface{}{evt.MessageId}
+	if prefix := registryPrefix(evt.MessageId); prefix != "" {
+		messageIds = append(messageIds, prefix+"."+messageKey(evt.MessageId))
+	}
+
+	origins := []interface{}{strings.TrimRight(evt.OriginOfCondition, "/")}
+	if f.subordinate {
+		for o := path.Dir(origins[0].(string)); strings.HasPrefix(o, "/redfish/v1"); o = path.Dir(o) {
+			origins = append(origins, o)
+		}
+	}
+
+	m := map[string]interface{}{
+		"EventType":            evt.EventType,
+		"MessageId":            messageIds,
+		"RegistryPrefix":       registryPrefix(evt.MessageId),
+		"OriginResource":       origins,
+		"SubordinateResources": f.subordinate,
+	}
+	if f.resourceType {
+		m["ResourceType"] = resourceType(evt.OriginOfCondition)
+	}
+	return f.filter.Matches(m)
+}
+
+// MatchMetricReport checks a metric report against the filter. The definition
+// can be given as its URI or its Id.
+func (f *SSEFilter) MatchMetricReport(report map[string]interface{}) bool {
+	if f == nil {
+		return true
+	}
+
+	definitions := []interface{}{}
+	if mrd, ok := report["MetricReportDefinition"].(map[string]interface{}); ok {
+		if uri, ok := mrd["@odata.id"].(string); ok {
+			definitions = append(definitions, uri, path.Base(uri))
+		}
+	}
+	// reports are named after their definition
+	if id, ok := report["Id"].(string); ok {
+		definitions = append(definitions, id)
+	}
+
+	return f.filter.Matches(map[string]interface{}{
+		"MetricReportDefinition": definitions,
+		"SubordinateResources":   f.subordinate,
+	})
+}
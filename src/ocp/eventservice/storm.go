@@ -0,0 +1,98 @@
+package eventservice
+
+import (
+	"strconv"
+	"time"
+)
+
+const defaultStormMaxEvents = 10
+const defaultStormWindowSeconds = 10
+
+// stormGuard keeps floods of the same event, like an LCL flood of NIC100 for
+// one port, from going out to every subscriber. Each MessageId and
+// OriginOfCondition gets a number of events per window. The rest are held,
+// and when the window is over each of them goes out as one event that says how
+// many it stands for, along with a summary of what was held.
+//
+// It is only used from the event service publisher goroutine, so it doesn't lock.
+type stormGuard struct {
+	// set by the caller when it starts the window timer
+	active     bool
+	counts     map[string]int
+	suppressed map[string]*suppressedEvents
+	// the keys in the order they started being held, so what is sent is in order
+	order []string
+}
+
+type suppressedEvents struct {
+	last  *RedfishEventData
+	count int
+}
+
+func newStormGuard() *stormGuard {
+	return &stormGuard{counts: map[string]int{}, suppressed: map[string]*suppressedEvents{}}
+}
+
+// admit returns true if evt should be sent now. max is the number of events
+// per window for each MessageId and OriginOfCondition, max <= 0 lets everything through.
+func (g *stormGuard) admit(evt *RedfishEventData, max int) bool {
+	if max <= 0 {
+		return true
+	}
+
+	key := evt.MessageId + " " + evt.OriginOfCondition
+	g.counts[key]++
+	if g.counts[key] <= max {
+		return true
+	}
+
+	s, ok := g.suppressed[key]
+	if !ok {
+		s = &suppressedEvents{}
+		g.suppressed[key] = s
+		g.order = append(g.order, key)
+	}
+	s.last = evt
+	s.count++
+	return false
+}
+
+// flush ends the window. It returns one event for each MessageId and
+// OriginOfCondition that was held, with the number of events it stands for in
+// Oem/sailfish/RepeatCount, and a summary at the end.
+func (g *stormGuard) flush(now time.Time) []*RedfishEventData {
+	ret := []*RedfishEventData{}
+	total := 0
+	for _, key := range g.order {
+		s := g.suppressed[key]
+		coalesced := *s.last
+		coalesced.Oem = map[string]interface{}{}
+		for k, v := range s.last.Oem {
+			coalesced.Oem[k] = v
+		}
+		coalesced.Oem["sailfish"] = map[string]interface{}{"RepeatCount": s.count}
+		ret = append(ret, &coalesced)
+		total += s.count
+	}
+
+	if total > 0 {
+		summary := &RedfishEventData{
+			EventType:      "Alert",
+			EventTimestamp: now.UTC().Format(time.RFC3339),
+			Severity:       "Warning",
+			MessageId:      "Sailfish.1.0.EventStormSuppressed",
+			MessageArgs:    []string{strconv.Itoa(total), strconv.Itoa(len(g.order))},
+			Oem: map[string]interface{}{
+				"sailfish": map[string]interface{}{"SuppressedEvents": total},
+			},
+		}
+		summary.fillFromRegistry()
+		ret = append(ret, summary)
+	}
+
+	g.active = false
+	g.counts = map[string]int{}
+	g.suppressed = map[string]*suppressedEvents{}
+	g.order = nil
+	return ret
+}
@@ -0,0 +1,36 @@
+package eventservice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStormGuard(t *testing.T) {
+	g := newStormGuard()
+	nic := func(port string) *RedfishEventData {
+		return &RedfishEventData{EventType: "Alert", MessageId: "NIC100", MessageArgs: []string{port}, OriginOfCondition: "System.Chassis.1"}
+	}
+
+	// two per window for each source
+	assert.True(t, g.admit(nic("1"), 2))
+	assert.True(t, g.admit(nic("2"), 2))
+	assert.False(t, g.admit(nic("3"), 2))
+	assert.False(t, g.admit(nic("4"), 2))
+	assert.True(t, g.admit(&RedfishEventData{EventType: "Alert", MessageId: "NIC100", OriginOfCondition: "System.Chassis.2"}, 2))
+	assert.True(t, g.admit(nic("5"), 0), "0 turns it off")
+
+	held := g.flush(time.Date(2019, 1, 15, 6, 45, 40, 0, time.UTC))
+	assert.Len(t, held, 2)
+	assert.Equal(t, []string{"4"}, held[0].MessageArgs)
+	assert.Equal(t, map[string]interface{}{"sailfish": map[string]interface{}{"RepeatCount": 2}}, held[0].Oem)
+	assert.Equal(t, "Sailfish.1.0.EventStormSuppressed", held[1].MessageId)
+	assert.Equal(t, []string{"2", "1"}, held[1].MessageArgs)
+	assert.Equal(t, "2019-01-15T06:45:40Z", held[1].EventTimestamp)
+	assert.Equal(t, map[string]interface{}{"sailfish": map[string]interface{}{"SuppressedEvents": 2}}, held[1].Oem)
+
+	// a new window starts over
+	assert.True(t, g.admit(nic("6"), 2))
+	assert.Empty(t, g.flush(time.Now()))
+}
@@ -36,6 +36,17 @@ func RegisterAggregate(s *testaggregate.Service) {
 						"Description":    "Root Service",
 						"RedfishVersion": "1.1.0",
 						"@odata.etag":    `W/"abc123"`,
+						"ProtocolFeaturesSupported": map[string]interface{}{
+							"ExpandQuery": map[string]interface{}{
+								"ExpandAll": true,
+								"Levels":    true,
+								"Links":     true,
+								"NoLinks":   true,
+								"MaxLevels": domain.MaxExpandLevels,
+							},
+							"FilterQuery": true,
+							"SelectQuery": true,
+						},
 					}},
 			}, nil
 		})
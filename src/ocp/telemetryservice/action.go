@@ -64,6 +64,7 @@ func MakeSubmitTestMetricReport(eb eh.EventBus, d *domain.DomainObjects, ch eh.C
 				ResourceURI: "/redfish/v1/TelemetryService/MetricReports/" + n,
 				Type:        "#MetricReport.v1_0_0.MetricReport",
 				Context:     "/redfish/v1/$metadata#MetricReport.MetricReport",
+				Deletable:   true,
 				Privileges: map[string]interface{}{
 					"GET":    []string{"Login"},
 					"DELETE": []string{"ConfigureManager"},
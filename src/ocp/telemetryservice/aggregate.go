@@ -2,6 +2,7 @@ package telemetryservice
 
 import (
 	"context"
+	"errors"
 	"sync"
 
 	eh "github.com/looplab/eventhorizon"
@@ -35,6 +36,8 @@ func RegisterAggregate(s *testaggregate.Service) {
 						"Oem":                     map[string]interface{}{},
 						"MetricReportDefinitions": map[string]interface{}{"@odata.id": vw.GetURI() + "/MetricReportDefinitions"},
 						"MetricReports":           map[string]interface{}{"@odata.id": vw.GetURI() + "/MetricReports"},
+						"MetricDefinitions":       map[string]interface{}{"@odata.id": vw.GetURI() + "/MetricDefinitions"},
+						"Triggers":                map[string]interface{}{"@odata.id": vw.GetURI() + "/Triggers"},
 					}},
 
 				&domain.UpdateRedfishResourceProperties{
@@ -84,9 +87,52 @@ func RegisterAggregate(s *testaggregate.Service) {
 			}, nil
 		})
 
-	s.RegisterAggregateFunction("fanspeedmetricdef",
+	s.RegisterAggregateFunction("triggers",
 		func(ctx context.Context, subLogger log.Logger, cfgMgr *viper.Viper, cfgMgrMu *sync.RWMutex, vw *view.View, extra interface{}, params map[string]interface{}) ([]eh.Command, error) {
-			// TODO:  dynamically manage MetricProperties
+			return []eh.Command{
+				&domain.CreateRedfishResource{
+					ResourceURI: vw.GetURI(),
+					Type:        "#TriggersCollection.TriggersCollection",
+					Context:     "/redfish/v1/$metadata#TriggersCollection.TriggersCollection",
+					Plugin:      "Triggers",
+					Privileges: map[string]interface{}{
+						"GET":  []string{"Login"},
+						"POST": []string{"ConfigureManager"},
+					},
+					Properties: map[string]interface{}{
+						"Id":                       "Triggers",
+						"Name":                     "Triggers",
+						"Members@meta":             vw.Meta(view.GETProperty("members"), view.GETFormatter("formatOdataList"), view.GETModel("default")),
+						"Members@odata.count@meta": vw.Meta(view.GETProperty("members"), view.GETFormatter("count"), view.GETModel("default")),
+					}},
+			}, nil
+		})
+
+	s.RegisterAggregateFunction("metric_definitions",
+		func(ctx context.Context, subLogger log.Logger, cfgMgr *viper.Viper, cfgMgrMu *sync.RWMutex, vw *view.View, extra interface{}, params map[string]interface{}) ([]eh.Command, error) {
+			return []eh.Command{
+				&domain.CreateRedfishResource{
+					ResourceURI: vw.GetURI(),
+					Type:        "#MetricDefinitionCollection.MetricDefinitionCollection",
+					Context:     "/redfish/v1/$metadata#MetricDefinitionCollection.MetricDefinitionCollection",
+					Privileges: map[string]interface{}{
+						"GET": []string{"Login"},
+					},
+					Properties: map[string]interface{}{
+						"Id":                       "MetricDefinitions",
+						"Name":                     "Metric Definitions",
+						"Members@meta":             vw.Meta(view.GETProperty("members"), view.GETFormatter("formatOdataList"), view.GETModel("default")),
+						"Members@odata.count@meta": vw.Meta(view.GETProperty("members"), view.GETFormatter("count"), view.GETModel("default")),
+					}},
+			}, nil
+		})
+
+	s.RegisterAggregateFunction("metric_definition",
+		func(ctx context.Context, subLogger log.Logger, cfgMgr *viper.Viper, cfgMgrMu *sync.RWMutex, vw *view.View, extra interface{}, params map[string]interface{}) ([]eh.Command, error) {
+			md, ok := params["definition"].(MetricDefinition)
+			if !ok {
+				return nil, errors.New("metric_definition needs a 'definition' parameter")
+			}
 			return []eh.Command{
 				&domain.CreateRedfishResource{
 					ResourceURI: vw.GetURI(),
@@ -96,29 +142,24 @@ func RegisterAggregate(s *testaggregate.Service) {
 						"GET": []string{"Login"},
 					},
 					Properties: map[string]interface{}{
-						"Id":              "FanSpeed",
-						"Name":            "Fan Speed Metric Definition",
-						"MetricType":      "Numeric",
-						"Implementation":  "PhysicalSensor",
-						"PhysicalContext": "Fan",
-						"MetricDataType":  "Decimal",
-						"MetricProperties": []string{
-							"/redfish/v1/Chassis/System.Chassis.1/Sensors/Fans/Fan.Slot.{SlotNumber}#Oem/Reading",
-						},
-						"Units":             "RPM",
-						"Precision":         2,
-						"Accuracy":          1.0,
-						"Calibration":       2,
-						"MinReadingRange":   0.0,
-						"MaxReadingRange":   1000.0,
+						"Id":                md.Id,
+						"Name":              md.Name,
+						"MetricType":        "Numeric",
+						"Implementation":    "PhysicalSensor",
+						"PhysicalContext":   md.PhysicalContext,
+						"MetricDataType":    md.MetricDataType,
+						"MetricProperties":  md.MetricProperties,
+						"Units":             md.Units,
+						"Accuracy":          md.Accuracy,
+						"Calibration":       md.Calibration,
+						"MinReadingRange":   md.MinReadingRange,
+						"MaxReadingRange":   md.MaxReadingRange,
 						"SensingInterval":   "PT1S",
 						"TimestampAccuracy": "PT1S",
-						"Wildcards": []interface{}{
-							map[string]interface{}{
-								"Name":   "SlotNumber",
-								"Values": []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "*"},
-							}}}},
+						// the values of the wildcards are the sensors that are there when it is read
+						"Wildcards@meta": map[string]interface{}{
+							"GET": map[string]interface{}{"plugin": "TelemetryService", "metricdefinition": md.Id}},
+					}},
 			}, nil
-
 		})
 }
@@ -0,0 +1,373 @@
+package telemetryservice
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	domain "github.com/superchalupa/sailfish/src/redfishresource"
+)
+
+// an Interval window never holds more samples than this, however fast they come
+const maxWindowSamples = 4096
+
+// Metric is one entry of the MetricReportDefinition Metrics, a value
+// calculated from the samples of its MetricProperties
+type Metric struct {
+	MetricId         string `json:",omitempty"`
+	MetricProperties []string
+	// CalculationAlgorithm is what the MetricDefinition calls the CollectionFunction
+	CollectionFunction   string `json:",omitempty"`
+	CalculationAlgorithm string `json:",omitempty"`
+	CollectionDuration   string `json:",omitempty"`
+	CollectionTimeScope  string `json:",omitempty"`
+}
+
+type sample struct {
+	at    time.Time
+	value float64
+}
+
+// window holds the samples of one metric property. Interval windows keep
+// each sample, the others only what they need to compute the value.
+type window struct {
+	samples []sample
+	count   int
+	sum     float64
+	min     float64
+	max     float64
+}
+
+type metricCalc struct {
+	metricID string
+	function string
+	scope    string
+	duration time.Duration
+	// MetricProperties as they were given, with wildcards
+	patterns []string
+	// a window for each metric property the patterns expand to
+	windows map[string]*window
+}
+
+// newMetricCalcs checks the Metrics of a MetricReportDefinition
+func newMetricCalcs(metrics []Metric) ([]*metricCalc, []domain.ExtendedInfo) {
+	calcs := []*metricCalc{}
+	errs := []domain.ExtendedInfo{}
+	for i, m := range metrics {
+		prefix := fmt.Sprintf("Metrics/%d/", i)
+		c := &metricCalc{
+			metricID: m.MetricId,
+			function: m.CollectionFunction,
+			scope:    m.CollectionTimeScope,
+			patterns: append([]string{}, m.MetricProperties...),
+			windows:  map[string]*window{},
+		}
+		if len(m.MetricProperties) == 0 {
+			errs = append(errs, domain.PropertyMissing(prefix+"MetricProperties"))
+		}
+
+		if c.function == "" {
+			c.function = m.CalculationAlgorithm
+		}
+		switch c.function {
+		case "Average", "Minimum", "Maximum", "Summation":
+		case "":
+			errs = append(errs, domain.PropertyMissing(prefix+"CollectionFunction"))
+		default:
+			errs = append(errs, domain.PropertyValueNotInList(c.function, prefix+"CollectionFunction"))
+		}
+
+		if m.CollectionDuration != "" {
+			d, err := parseDuration(m.CollectionDuration)
+			if err != nil || d <= 0 {
+				errs = append(errs, domain.PropertyValueFormatError(m.CollectionDuration, prefix+"CollectionDuration"))
+			}
+			c.duration = d
+		}
+		if c.scope == "" {
+			c.scope = "Point"
+			if m.CollectionDuration != "" {
+				c.scope = "Interval"
+			}
+		}
+		switch c.scope {
+		case "Interval":
+			if m.CollectionDuration == "" {
+				errs = append(errs, domain.PropertyMissing(prefix+"CollectionDuration"))
+			}
+		case "Point", "StartupInterval":
+		default:
+			errs = append(errs, domain.PropertyValueNotInList(c.scope, prefix+"CollectionTimeScope"))
+		}
+		calcs = append(calcs, c)
+	}
+	return calcs, errs
+}
+
+// metricsProperty is how the Metrics show up in the MetricReportDefinition
+func metricsProperty(calcs []*metricCalc) []interface{} {
+	metrics := []interface{}{}
+	for _, c := range calcs {
+		m := map[string]interface{}{
+			"MetricProperties":    c.patterns,
+			"CollectionFunction":  c.function,
+			"CollectionTimeScope": c.scope,
+		}
+		if c.metricID != "" {
+			m["MetricId"] = c.metricID
+		}
+		if c.duration > 0 {
+			m["CollectionDuration"] = formatDuration(c.duration)
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// id is the MetricId the calculated values are reported with. Without one
+// given, it's the property name with the function on the end, like
+// "PowerConsumedWattsAverage".
+func (c *metricCalc) id(metricProperty string) string {
+	if c.metricID != "" {
+		return c.metricID
+	}
+	parts := strings.SplitN(metricProperty, "#", 2)
+	return path.Base(parts[len(parts)-1]) + c.function
+}
+
+// track sets the metric properties the patterns expand to. Windows of
+// properties that are still there keep their samples.
+func (c *metricCalc) track(props []string) {
+	windows := map[string]*window{}
+	for _, prop := range props {
+		if w, ok := c.windows[prop]; ok {
+			windows[prop] = w
+		} else {
+			windows[prop] = &window{}
+		}
+	}
+	c.windows = windows
+}
+
+// add puts a sample in the window
+func (c *metricCalc) add(w *window, at time.Time, value float64) {
+	switch c.scope {
+	case "Interval":
+		w.samples = append(w.samples, sample{at: at, value: value})
+		if len(w.samples) > maxWindowSamples {
+			w.samples = append([]sample{}, w.samples[len(w.samples)-maxWindowSamples:]...)
+		}
+	case "StartupInterval":
+		if w.count == 0 || value < w.min {
+			w.min = value
+		}
+		if w.count == 0 || value > w.max {
+			w.max = value
+		}
+		w.count++
+		w.sum += value
+	default:
+		w.samples = []sample{{at: at, value: value}}
+	}
+}
+
+// value computes the metric from the samples in the window as of now
+func (c *metricCalc) value(w *window, now time.Time) (float64, bool) {
+	if c.scope == "StartupInterval" {
+		if w.count == 0 {
+			return 0, false
+		}
+		switch c.function {
+		case "Minimum":
+			return w.min, true
+		case "Maximum":
+			return w.max, true
+		case "Summation":
+			return w.sum, true
+		}
+		return w.sum / float64(w.count), true
+	}
+
+	if c.scope == "Interval" {
+		// drop what has fallen out of the window
+		i := 0
+		for i < len(w.samples) && now.Sub(w.samples[i].at) > c.duration {
+			i++
+		}
+		w.samples = w.samples[i:]
+	}
+	values := []float64{}
+	for _, s := range w.samples {
+		values = append(values, s.value)
+	}
+	return calculate(c.function, values)
+}
+
+// calculate applies a collection function to the values
+func calculate(function string, values []float64) (float64, bool) {
+	if len(values) == 0 {
+		return 0, false
+	}
+	result := values[0]
+	for _, v := range values[1:] {
+		switch function {
+		case "Minimum":
+			if v < result {
+				result = v
+			}
+		case "Maximum":
+			if v > result {
+				result = v
+			}
+		default:
+			result += v
+		}
+	}
+	if function == "Average" {
+		result /= float64(len(values))
+	}
+	return result, true
+}
+
+// setMetricCalcs sets the Metrics of a report and expands their MetricProperties
+func (ts *TelemetryService) setMetricCalcs(ctx context.Context, mrdP *mrdConfig, calcs []*metricCalc) {
+	ts.Lock()
+	mrdP.calcs = calcs
+	ts.Unlock()
+	ts.expandMetricCalcs(ctx, mrdP)
+}
+
+// expandMetricCalcs expands the MetricProperties of the Metrics to what is there now
+func (ts *TelemetryService) expandMetricCalcs(ctx context.Context, mrdP *mrdConfig) {
+	ts.RLock()
+	calcs, wildcards := mrdP.calcs, mrdP.config.wildCard
+	ts.RUnlock()
+
+	for _, c := range calcs {
+		// the patterns of a metric don't change after it is made
+		props := ts.expandMetricProperties(ctx, c.patterns, wildcards)
+		ts.Lock()
+		c.track(props)
+		ts.Unlock()
+	}
+}
+
+// calcWildcards is whether any of the Metrics of a report have wildcards. Call it with ts locked.
+func (mrdP *mrdConfig) calcWildcards() bool {
+	for _, c := range mrdP.calcs {
+		if anyWildcards(c.patterns) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectSamples adds the values in a property update to the windows of the
+// Metrics that use them. OnChange reports get the newly calculated values.
+func (ts *TelemetryService) collectSamples(ctx context.Context, data *domain.RedfishResourcePropertiesUpdatedData2) {
+	type change struct {
+		mrdP  *mrdConfig
+		id    string
+		value float64
+		prop  string
+	}
+	changes := []change{}
+	now := time.Now()
+
+	ts.Lock()
+	for _, mrdP := range ts.mrdConfigL {
+		if !mrdP.config.mrdEnabled {
+			continue
+		}
+		for _, c := range mrdP.calcs {
+			for aPath, val := range data.PropertyNames {
+				prop := data.ResourceURI + "#/" + aPath
+				w, ok := c.windows[prop]
+				if !ok {
+					prop = data.ResourceURI + "#" + aPath
+					w, ok = c.windows[prop]
+				}
+				if !ok {
+					continue
+				}
+				reading, ok := toFloat(val)
+				if !ok {
+					continue
+				}
+				c.add(w, now, reading)
+				if mrdP.config.mrdType != "OnChange" {
+					continue
+				}
+				if v, ok := c.value(w, now); ok {
+					changes = append(changes, change{mrdP: mrdP, id: c.id(prop), value: v, prop: prop})
+				}
+			}
+		}
+	}
+	ts.Unlock()
+
+	for _, ch := range changes {
+		ts.sendMetricEvent(ctx, ch.mrdP.mrUUID, ch.id, formatValue(ch.value), ch.prop)
+	}
+}
+
+// calculatedMetricValues are the values of the Metrics of a report as of
+// now. A metric property without samples yet is read as it is now.
+func (ts *TelemetryService) calculatedMetricValues(ctx context.Context, mrdP *mrdConfig, timestamp string) []interface{} {
+	type empty struct {
+		c    *metricCalc
+		prop string
+	}
+	values := []interface{}{}
+	missing := []empty{}
+	now := time.Now()
+
+	ts.Lock()
+	for _, c := range mrdP.calcs {
+		props := []string{}
+		for prop := range c.windows {
+			props = append(props, prop)
+		}
+		sort.Strings(props)
+		for _, prop := range props {
+			if v, ok := c.value(c.windows[prop], now); ok {
+				values = append(values, calculatedValue(c.id(prop), v, timestamp, prop))
+			} else {
+				missing = append(missing, empty{c: c, prop: prop})
+			}
+		}
+	}
+	ts.Unlock()
+
+	for _, m := range missing {
+		value, ok := ts.sample(ctx, m.prop)
+		if !ok {
+			continue
+		}
+		reading, ok := toFloat(value)
+		if !ok {
+			continue
+		}
+		ts.Lock()
+		if w, ok := m.c.windows[m.prop]; ok {
+			m.c.add(w, now, reading)
+			if v, ok := m.c.value(w, now); ok {
+				values = append(values, calculatedValue(m.c.id(m.prop), v, timestamp, m.prop))
+			}
+		}
+		ts.Unlock()
+	}
+	return values
+}
+
+func calculatedValue(id string, value float64, timestamp string, prop string) map[string]interface{} {
+	return map[string]interface{}{
+		"MetricId":       id,
+		"MetricValue":    formatValue(value),
+		"Timestamp":      timestamp,
+		"MetricProperty": prop,
+	}
+}
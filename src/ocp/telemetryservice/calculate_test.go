@@ -0,0 +1,71 @@
+package telemetryservice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMetricCalcs(t *testing.T) {
+	calcs, errs := newMetricCalcs([]Metric{
+		{MetricProperties: []string{"/redfish/v1/Chassis/System.Chassis.1/Power/PowerControl#PowerConsumedWatts"}, CalculationAlgorithm: "Average", CollectionDuration: "PT1M"},
+		{MetricProperties: []string{"/redfish/v1/Chassis/System.Chassis.1/Sensors/Temperatures/Inlet#ReadingCelsius"}, CollectionFunction: "Maximum", CollectionTimeScope: "StartupInterval"},
+	})
+	assert.Empty(t, errs)
+	if assert.Len(t, calcs, 2) {
+		assert.Equal(t, "Interval", calcs[0].scope)
+		assert.Equal(t, time.Minute, calcs[0].duration)
+		assert.Equal(t, "PowerConsumedWattsAverage", calcs[0].id("/redfish/v1/Chassis/System.Chassis.1/Power/PowerControl#PowerConsumedWatts"))
+		assert.Equal(t, "ReadingCelsiusMaximum", calcs[1].id("/redfish/v1/Chassis/System.Chassis.1/Sensors/Temperatures/Inlet#ReadingCelsius"))
+	}
+
+	_, errs = newMetricCalcs([]Metric{
+		{CollectionFunction: "Median", CollectionTimeScope: "Interval"},
+		{MetricProperties: []string{"Fans/0/Reading"}, CollectionFunction: "Average", CollectionDuration: "1m"},
+	})
+	ids := []string{}
+	for _, e := range errs {
+		ids = append(ids, e.MessageId)
+	}
+	assert.Equal(t, []string{
+		"Base.1.0.PropertyMissing",
+		"Base.1.0.PropertyValueNotInList",
+		"Base.1.0.PropertyMissing",
+		"Base.1.0.PropertyValueFormatError",
+	}, ids)
+}
+
+func TestMetricCalcValue(t *testing.T) {
+	start := time.Now()
+	var tests = []struct {
+		testname string
+		function string
+		scope    string
+		expected float64
+	}{
+		// the first sample has fallen out of the minute by the end
+		{"interval average", "Average", "Interval", 30},
+		{"interval minimum", "Minimum", "Interval", 20},
+		{"interval maximum", "Maximum", "Interval", 40},
+		{"interval summation", "Summation", "Interval", 90},
+		{"startup average", "Average", "StartupInterval", 25},
+		{"startup minimum", "Minimum", "StartupInterval", 10},
+		{"point", "Maximum", "Point", 40},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testname, func(t *testing.T) {
+			c := &metricCalc{function: tc.function, scope: tc.scope, duration: time.Minute}
+			w := &window{}
+			_, ok := c.value(w, start)
+			assert.False(t, ok, "no samples yet")
+			for i, v := range []float64{10, 20, 30, 40} {
+				c.add(w, start.Add(time.Duration(i)*30*time.Second), v)
+			}
+			v, ok := c.value(w, start.Add(90*time.Second))
+			assert.True(t, ok)
+			assert.Equal(t, tc.expected, v)
+		})
+	}
+}
@@ -12,7 +12,8 @@ import (
 )
 
 const (
-	POSTCommand = eh.CommandType("TelemetryService:POST")
+	POSTCommand         = eh.CommandType("TelemetryService:POST")
+	TriggersPOSTCommand = eh.CommandType("Triggers:POST")
 )
 
 type MetricReportDefinition struct {
@@ -24,7 +25,53 @@ type MetricReportDefinition struct {
 	MetricReportHeartbeatInterval string
 	SuppressRepeatedMetricValue   bool
 	MetricProperties              []string
+	Metrics                       []Metric
+	Schedule                      Schedule
 	Wildcards                     []map[string]interface{}
+	ReportUpdates                 string
+	AppendLimit                   int
+}
+
+type Threshold struct {
+	Reading    *float64 `json:",omitempty"`
+	Activation string   `json:",omitempty"`
+	DwellTime  string   `json:",omitempty"`
+}
+
+type NumericThresholds struct {
+	UpperCritical *Threshold `json:",omitempty"`
+	UpperWarning  *Threshold `json:",omitempty"`
+	LowerWarning  *Threshold `json:",omitempty"`
+	LowerCritical *Threshold `json:",omitempty"`
+}
+
+type DiscreteTrigger struct {
+	Name      string `json:",omitempty"`
+	Value     string
+	DwellTime string `json:",omitempty"`
+	Severity  string `json:",omitempty"`
+}
+
+type ODataLink struct {
+	ID string `json:"@odata.id"`
+}
+
+type TriggerLinks struct {
+	MetricReportDefinitions []ODataLink
+}
+
+type Trigger struct {
+	Id                       string
+	Name                     string
+	Description              string
+	MetricType               string
+	TriggerActions           []string
+	NumericThresholds        NumericThresholds
+	DiscreteTriggerCondition string
+	DiscreteTriggers         []DiscreteTrigger
+	MetricProperties         []string
+	Wildcards                []map[string]interface{}
+	Links                    TriggerLinks
 }
 
 // HTTP POST Command
@@ -100,3 +147,67 @@ func (c *POST) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) e
 
 	return nil
 }
+
+// HTTP POST Command for the Triggers collection
+type TriggersPOST struct {
+	ts   *TelemetryService
+	d    *domain.DomainObjects
+	auth *domain.RedfishAuthorizationProperty
+
+	ID      eh.UUID           `json:"id"`
+	CmdID   eh.UUID           `json:"cmdid"`
+	Headers map[string]string `eh:"optional"`
+	Trigger Trigger           `eh:"optional"`
+}
+
+// Static type checking for commands to prevent runtime errors due to typos
+var _ = eh.Command(&TriggersPOST{})
+
+func (c *TriggersPOST) AggregateType() eh.AggregateType { return domain.AggregateType }
+func (c *TriggersPOST) AggregateID() eh.UUID            { return c.ID }
+func (c *TriggersPOST) CommandType() eh.CommandType     { return TriggersPOSTCommand }
+func (c *TriggersPOST) SetAggID(id eh.UUID)             { c.ID = id }
+func (c *TriggersPOST) SetCmdID(id eh.UUID)             { c.CmdID = id }
+func (c *TriggersPOST) SetUserDetails(a *domain.RedfishAuthorizationProperty) string {
+	c.auth = a
+	return "checkMaster"
+}
+func (c *TriggersPOST) ParseHTTPRequest(r *http.Request) error {
+	return json.NewDecoder(r.Body).Decode(&c.Trigger)
+}
+func (c *TriggersPOST) Handle(ctx context.Context, a *domain.RedfishResourceAggregate) error {
+	data := &domain.HTTPCmdProcessedData{
+		CommandID:  c.CmdID,
+		Results:    map[string]interface{}{"msg": "Error creating trigger"},
+		StatusCode: 500,
+		Headers:    map[string]string{}}
+
+	triggerUUID, ok := c.ts.CreateTrigger(ctx, c.Trigger, data)
+	if !ok {
+		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, data, time.Now()))
+		return errors.New("Could not create trigger")
+	}
+
+	agg, err := c.d.AggregateStore.Load(ctx, domain.AggregateType, triggerUUID)
+	if err != nil {
+		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, data, time.Now()))
+		return errors.New("Could not load trigger aggregate")
+	}
+	redfishResource, ok := agg.(*domain.RedfishResourceAggregate)
+	if !ok {
+		a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, data, time.Now()))
+		return errors.New("Wrong aggregate type returned")
+	}
+
+	domain.NewGet(ctx, redfishResource, &redfishResource.Properties, c.auth)
+	data.Results = domain.Flatten(&redfishResource.Properties, false)
+
+	for k, v := range a.Headers {
+		data.Headers[k] = v
+	}
+	data.Headers["Location"] = redfishResource.ResourceURI
+	data.StatusCode = 201
+	a.PublishEvent(eh.NewEvent(domain.HTTPCmdProcessed, data, time.Now()))
+
+	return nil
+}
@@ -3,16 +3,18 @@ package telemetryservice
 import (
 	"context"
 	"fmt"
+	"path"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	eh "github.com/looplab/eventhorizon"
 	eventpublisher "github.com/looplab/eventhorizon/publisher/local"
+	"github.com/spf13/viper"
 
 	"github.com/superchalupa/sailfish/src/log"
 	"github.com/superchalupa/sailfish/src/looplab/eventwaiter"
-	"github.com/superchalupa/sailfish/src/ocp/eventservice"
 	domain "github.com/superchalupa/sailfish/src/redfishresource"
 )
 
@@ -30,12 +32,18 @@ type ReportCtx struct {
 
 type TelemetryService struct {
 	sync.RWMutex
+	ctx           context.Context
+	cfg           *viper.Viper
+	cfgMu         *sync.RWMutex
 	d             *domain.DomainObjects
 	ew            waiter
 	ch            eh.CommandHandler
-	mrdConfigL    []mrdConfig
+	mrdConfigL    []*mrdConfig
 	metric2Report map[string][]*mrdConfig
+	triggers      []*triggerConfig
 	logger        log.Logger
+	// wildcards will be expanded again soon
+	expandPending bool
 }
 
 type mrdConfig struct {
@@ -45,18 +53,35 @@ type mrdConfig struct {
 	mrdURI  string
 	mrdUUID eh.UUID
 	config  mrdPatch
+
+	// MetricProperties as they were given, with wildcards
+	patterns         []string
+	metricProperties []string
+	// values calculated from samples of other properties
+	calcs []*metricCalc
+	// ReportSequence of the last report
+	sequence int
+	// the latest value of each metric property, for OnChange reports that Overwrite
+	latest map[string]interface{}
+	// the reports made for NewReport, oldest first
+	reports []reportInstance
+	// stops the timer for periodic reports
+	cancel func()
 }
 
 // uncomment when feature is implemented and patchable.
 type mrdPatch struct {
-	mrdType    string
-	mrdEnabled bool
+	mrdType       string
+	mrdEnabled    bool
+	recurrence    time.Duration
+	wildCard      map[string][]string
+	reportUpdates string
+	appendLimit   int
 	//	mrdHeart string
 	//	suppressRepeat bool
-	//	wildCard map[string][]string
 }
 
-func New(ctx context.Context, logger log.Logger, chdler eh.CommandHandler, d *domain.DomainObjects) *TelemetryService {
+func New(ctx context.Context, logger log.Logger, cfg *viper.Viper, cfgMu *sync.RWMutex, chdler eh.CommandHandler, d *domain.DomainObjects) *TelemetryService {
 	logger = logger.New("module", "telemetry")
 	EventPublisher := eventpublisher.NewEventPublisher()
 	d.EventBus.AddHandler(eh.MatchAnyEventOf(MetricValueEvent, domain.RedfishResourceRemoved, domain.RedfishResourcePropertiesUpdated2, domain.RedfishResourceCreated), EventPublisher)
@@ -65,10 +90,13 @@ func New(ctx context.Context, logger log.Logger, chdler eh.CommandHandler, d *do
 	go EventWaiter.Run()
 
 	ret := &TelemetryService{
+		ctx:           ctx,
+		cfg:           cfg,
+		cfgMu:         cfgMu,
 		d:             d,
 		ew:            EventWaiter,
 		ch:            chdler,
-		mrdConfigL:    []mrdConfig{},
+		mrdConfigL:    []*mrdConfig{},
 		metric2Report: map[string][]*mrdConfig{}, // MetricProperties : []&mrdConfig, ex: System.Chassis.1/Thermal/Fan.Slot.1#Reading: []&mrdConfig
 		logger:        logger,
 	}
@@ -77,41 +105,51 @@ func New(ctx context.Context, logger log.Logger, chdler eh.CommandHandler, d *do
 	return ret
 }
 
-func (ts *TelemetryService) setMRDConfig(Id string, mrUUID eh.UUID, mrdUUID eh.UUID, mrURI string, mrdURI string, mrdEnabled bool, mrdType string, PropL []string) {
+func (ts *TelemetryService) setMRDConfig(Id string, mrUUID eh.UUID, mrdUUID eh.UUID, mrURI string, mrdURI string, config mrdPatch, patterns []string, PropL []string) *mrdConfig {
+	ts.Lock()
+	defer ts.Unlock()
+
 	var mrdP *mrdConfig = nil
 	for i := 0; i < len(ts.mrdConfigL); i++ {
 		if ts.mrdConfigL[i].name == Id {
-			mrdP = &ts.mrdConfigL[i]
+			mrdP = ts.mrdConfigL[i]
 			break
 		}
 	}
 
-	ts.Lock()
-	defer ts.Unlock()
 	if mrdP == nil {
-		ts.mrdConfigL = append(ts.mrdConfigL, mrdConfig{
+		mrdP = &mrdConfig{
 			name:    Id,
 			mrUUID:  mrUUID,
 			mrdUUID: mrdUUID,
 			mrURI:   mrURI,
 			mrdURI:  mrdURI,
-			config: mrdPatch{
-				mrdEnabled: mrdEnabled,
-				mrdType:    mrdType,
-			}})
-		mrdP = &ts.mrdConfigL[len(ts.mrdConfigL)-1]
+			config:  config,
+			latest:  map[string]interface{}{},
+		}
+		ts.mrdConfigL = append(ts.mrdConfigL, mrdP)
 
 	} else {
-		ts.deleteMRDConfig(mrdP)
-
 		mrdP.name = Id
 		mrdP.mrdUUID = mrdUUID
 		mrdP.mrdURI = mrdURI
-		mrdP.config.mrdEnabled = mrdEnabled
-		mrdP.config.mrdType = mrdType
+		mrdP.config = config
 		mrdP.mrUUID = mrUUID
 		mrdP.mrURI = mrURI
+		mrdP.sequence = 0
+		mrdP.latest = map[string]interface{}{}
 	}
+	mrdP.patterns = append([]string{}, patterns...)
+	ts.trackMetricProperties(mrdP, PropL)
+
+	ts.schedule(mrdP)
+	return mrdP
+}
+
+// trackMetricProperties sets the MetricProperties a report is made from. Call it with ts locked.
+func (ts *TelemetryService) trackMetricProperties(mrdP *mrdConfig, PropL []string) {
+	ts.deleteMRDConfig(mrdP)
+	mrdP.metricProperties = append([]string{}, PropL...)
 
 	for i := 0; i < len(PropL); i++ {
 		pS := PropL[i]
@@ -122,7 +160,69 @@ func (ts *TelemetryService) setMRDConfig(Id string, mrUUID eh.UUID, mrdUUID eh.U
 			ts.metric2Report[pS] = []*mrdConfig{mrdP}
 		}
 	}
+}
+
+// findMetricReport returns the config for the MetricReport with aggregate id mrUUID. Call it with ts locked.
+func (ts *TelemetryService) findMetricReport(mrUUID eh.UUID) *mrdConfig {
+	for _, mrdP := range ts.mrdConfigL {
+		if mrdP.mrUUID == mrUUID {
+			return mrdP
+		}
+	}
+	return nil
+}
+
+// findMRDConfig returns the config for the MetricReportDefinition at mrdURI. Call it with ts locked.
+func (ts *TelemetryService) findMRDConfig(mrdURI string) (int, *mrdConfig) {
+	for i, mrdP := range ts.mrdConfigL {
+		if mrdP.mrdURI == mrdURI {
+			return i, mrdP
+		}
+	}
+	return -1, nil
+}
+
+// patchMRDConfig picks up PATCHes to a MetricReportDefinition
+func (ts *TelemetryService) patchMRDConfig(mrdURI string, properties map[string]interface{}) {
+	ts.Lock()
+	defer ts.Unlock()
+	_, mrdP := ts.findMRDConfig(mrdURI)
+	if mrdP == nil {
+		return
+	}
 
+	if enabled, ok := properties["MetricReportDefinitionEnabled"].(bool); ok {
+		mrdP.config.mrdEnabled = enabled
+	}
+	// the RecurrenceInterval is checked and picked up by PropertyPatch
+	ts.schedule(mrdP)
+}
+
+// removeMRDConfig stops a MetricReportDefinition that was deleted, removes its
+// MetricReport and takes it out of the Links of any triggers
+func (ts *TelemetryService) removeMRDConfig(ctx context.Context, mrdURI string) {
+	ts.Lock()
+	i, mrdP := ts.findMRDConfig(mrdURI)
+	if mrdP == nil {
+		ts.Unlock()
+		return
+	}
+	mrdP.config.mrdEnabled = false
+	ts.schedule(mrdP)
+	ts.deleteMRDConfig(mrdP)
+	ts.mrdConfigL = append(ts.mrdConfigL[:i], ts.mrdConfigL[i+1:]...)
+	reports := mrdP.reports
+	mrdP.reports = nil
+	unlinks := ts.unlinkMRD(mrdURI)
+	ts.Unlock()
+
+	ts.d.CommandHandler.HandleCommand(ctx, &domain.RemoveRedfishResource{ID: mrdP.mrUUID, ResourceURI: mrdP.mrURI})
+	for _, r := range reports {
+		ts.d.CommandHandler.HandleCommand(ctx, &domain.RemoveRedfishResource{ID: r.uuid, ResourceURI: r.uri})
+	}
+	for _, cmd := range unlinks {
+		ts.d.CommandHandler.HandleCommand(ctx, cmd)
+	}
 }
 
 func (ts *TelemetryService) deleteMRDConfig(mrdP *mrdConfig) {
@@ -151,7 +251,7 @@ func (ts *TelemetryService) deleteMRDConfig(mrdP *mrdConfig) {
 	}
 }
 
-func (ts *TelemetryService) sendMetricEvent(ctx context.Context, mrdUUID eh.UUID, metricID string, metricValue interface{}, metricProp string, metricType string) {
+func (ts *TelemetryService) sendMetricEvent(ctx context.Context, mrdUUID eh.UUID, metricID string, metricValue interface{}, metricProp string) {
 	valS := fmt.Sprintf("%+v", metricValue)
 
 	eventData := &MetricValueEventData{
@@ -161,8 +261,7 @@ func (ts *TelemetryService) sendMetricEvent(ctx context.Context, mrdUUID eh.UUID
 		MetricValue: valS,
 		Timestamp:   time.Now().UTC().Format("2006-01-02T15:04:05-07:00"),
 
-		MetricProperty:   metricProp,
-		reportUpdateType: metricType,
+		MetricProperty: metricProp,
 	}
 	ts.d.EventBus.PublishEvent(ctx, eh.NewEvent(MetricValueEvent, eventData, time.Now()))
 }
@@ -174,6 +273,11 @@ func (ts *TelemetryService) StartTelemetryService(ctx context.Context) error {
 	eh.RegisterCommand(func() eh.Command {
 		return &POST{ts: ts, d: ts.d}
 	})
+	eh.RegisterCommand(func() eh.Command {
+		return &TriggersPOST{ts: ts, d: ts.d}
+	})
+	// OnRequest reports are read through the TelemetryService plugin
+	domain.RegisterPlugin(func() domain.Plugin { return ts })
 	listener, err := ts.ew.Listen(ctx,
 		func(event eh.Event) bool {
 			switch typ := event.EventType(); typ {
@@ -181,6 +285,12 @@ func (ts *TelemetryService) StartTelemetryService(ctx context.Context) error {
 				// first match url.  Then match property.  then send metric event for each metricreportdefinition.
 				if data, ok := event.Data().(*domain.RedfishResourcePropertiesUpdatedData2); ok {
 					// update MR/MRD config here
+					if strings.HasPrefix(data.ResourceURI, "/redfish/v1/TelemetryService/MetricReportDefinitions/") {
+						ts.patchMRDConfig(data.ResourceURI, data.PropertyNames)
+						return false
+					}
+					ts.evaluateTriggers(ctx, data)
+					ts.collectSamples(ctx, data)
 
 					ts.RLock()
 					for mProp, MRDConfigL := range ts.metric2Report {
@@ -197,7 +307,7 @@ func (ts *TelemetryService) StartTelemetryService(ctx context.Context) error {
 						}
 						for aPath, val := range data.PropertyNames {
 
-							if aPath == mPropPath {
+							if aPath == strings.Trim(mPropPath, "/") {
 								for i := 0; i < len(MRDConfigL); i++ {
 									// send every metric change
 									if MRDConfigL[i].config.mrdType != "OnChange" {
@@ -205,7 +315,7 @@ func (ts *TelemetryService) StartTelemetryService(ctx context.Context) error {
 									}
 
 									if MRDConfigL[i].config.mrdEnabled {
-										ts.sendMetricEvent(ctx, MRDConfigL[i].mrUUID, MRDConfigL[i].name, val, mProp, MRDConfigL[i].config.mrdType)
+										ts.sendMetricEvent(ctx, MRDConfigL[i].mrUUID, path.Base(aPath), val, mProp)
 									}
 								}
 								break
@@ -216,27 +326,19 @@ func (ts *TelemetryService) StartTelemetryService(ctx context.Context) error {
 				}
 			case MetricValueEvent:
 				return true
+			case domain.RedfishResourceCreated:
+				ts.resourcesChanged(ctx)
 			case domain.RedfishResourceRemoved:
 				if data, ok := event.Data().(*domain.RedfishResourceRemovedData); ok {
 					if strings.Contains(data.ResourceURI, "/redfish/v1/TelemetryService/MetricReportDefinitions/") {
-						var duri string
-						var duuid eh.UUID
-						var mrdP *mrdConfig
-						for i := 0; i < len(ts.mrdConfigL); i++ {
-							if data.ResourceURI == ts.mrdConfigL[i].mrURI {
-
-								duri = ts.mrdConfigL[i].mrURI
-								duuid = ts.mrdConfigL[i].mrUUID
-								mrdP = &ts.mrdConfigL[i]
-								break
-							}
-						}
-						if duri != "" {
-							ts.deleteMRDConfig(mrdP)
-							ts.d.CommandHandler.HandleCommand(ctx, &domain.RemoveRedfishResource{ID: duuid, ResourceURI: duri})
-						}
+						// the MetricReport goes away with the definition
+						ts.removeMRDConfig(ctx, data.ResourceURI)
+					}
+					if strings.HasPrefix(data.ResourceURI, triggersURI) {
+						ts.removeTrigger(data.ResourceURI)
 					}
 				}
+				ts.resourcesChanged(ctx)
 
 			}
 
@@ -251,7 +353,6 @@ func (ts *TelemetryService) StartTelemetryService(ctx context.Context) error {
 	// current design train of thought.  Having the aggregate updated here and metric event sent here allows more freedom to
 	// handle scheduling, grouping changes then updating/sending
 	go func() {
-		APPENDLIMIT := 150
 		// delete the aggregate
 		defer listener.Close()
 
@@ -270,31 +371,19 @@ func (ts *TelemetryService) StartTelemetryService(ctx context.Context) error {
 						continue
 					}
 					valItem := map[string]interface{}{
-						"MetricId":       "",
+						"MetricId":       data.MetricId,
 						"MetricValue":    data.MetricValue,
 						"Timestamp":      data.Timestamp,
 						"MetricProperty": data.MetricProperty,
 					}
-					valL := []interface{}{valItem}
-					for i := 0; i < len(ts.metric2Report[data.MetricProperty]); i++ {
-
-						// can batch MetricValueEvent saves..
-						ts.d.CommandHandler.HandleCommand(ctx,
-							&domain.UpdateMetricRedfishResource{
-								ID:               data.UUID,
-								AppendLimit:      APPENDLIMIT,
-								ReportUpdateType: data.reportUpdateType,
-								Properties: map[string]interface{}{
-									"MetricValues": valL,
-								},
-							})
+					ts.RLock()
+					mrdP := ts.findMetricReport(data.UUID)
+					ts.RUnlock()
+					if mrdP == nil {
+						continue
 					}
-					agg, _ := ts.d.AggregateStore.Load(ctx, domain.AggregateType, data.UUID)
-					redfishResource, ok := agg.(*domain.RedfishResourceAggregate)
-					results := domain.Flatten(&redfishResource.Properties, false)
-					resultD := results.(map[string]interface{})
-					eventData := eventservice.MetricReportData{Data: resultD}
-					ts.d.EventBus.PublishEvent(ctx, eh.NewEvent(eventservice.ExternalMetricEvent, eventData, time.Now()))
+					// can batch MetricValueEvent saves..
+					ts.updateReport(ctx, mrdP, []interface{}{valItem})
 
 				}
 			case <-ctx.Done():
@@ -315,7 +404,7 @@ func (ts *TelemetryService) CreateMetricReportDefinition(ctx context.Context, mr
 		errmmsg += fmt.Sprintf(errmFmt, "Id")
 	}
 
-	if len(mrd.MetricProperties) == 0 {
+	if len(mrd.MetricProperties) == 0 && len(mrd.Metrics) == 0 {
 		errmmsg += fmt.Sprintf(errmFmt, "MetricProperties")
 	}
 
@@ -328,50 +417,122 @@ func (ts *TelemetryService) CreateMetricReportDefinition(ctx context.Context, mr
 		return false, "", ""
 	}
 
+	wildCard, errs := parseWildcards(mrd.Wildcards)
+	calcs, calcErrs := newMetricCalcs(mrd.Metrics)
+	errs = append(errs, calcErrs...)
+	if len(errs) > 0 {
+		data.Results = domain.ErrorResponse(errs...)
+		data.StatusCode = 400
+		return false, "", ""
+	}
+
+	var recurrence time.Duration
+	switch mrd.MetricReportDefinitionType {
+	case "Periodic":
+		var err error
+		if recurrence, err = recurrenceInterval(mrd.Schedule.RecurrenceInterval); err != nil {
+			data.Results = domain.ErrorResponse(domain.PropertyValueFormatError(mrd.Schedule.RecurrenceInterval, "Schedule/RecurrenceInterval"))
+			data.StatusCode = 400
+			return false, "", ""
+		}
+	case "OnChange", "OnRequest":
+	default:
+		data.Results = domain.ErrorResponse(domain.PropertyValueNotInList(mrd.MetricReportDefinitionType, "MetricReportDefinitionType"))
+		data.StatusCode = 400
+		return false, "", ""
+	}
+
+	reportUpdates := mrd.ReportUpdates
+	switch reportUpdates {
+	case "":
+		reportUpdates = "AppendWrapsWhenFull"
+	case "Overwrite", "AppendWrapsWhenFull", "AppendStopsWhenFull", "NewReport":
+	default:
+		data.Results = domain.ErrorResponse(domain.PropertyValueNotInList(mrd.ReportUpdates, "ReportUpdates"))
+		data.StatusCode = 400
+		return false, "", ""
+	}
+	appendLimit := mrd.AppendLimit
+	if appendLimit == 0 {
+		appendLimit = defaultAppendLimit
+	} else if appendLimit < 0 {
+		data.Results = domain.ErrorResponse(domain.PropertyValueFormatError(strconv.Itoa(mrd.AppendLimit), "AppendLimit"))
+		data.StatusCode = 400
+		return false, "", ""
+	}
+
 	mruuid := eh.NewUUID()
 	mrdURL := "/redfish/v1/TelemetryService/MetricReportDefinitions/" + mrd.Id
 	mrURL := "/redfish/v1/TelemetryService/MetricReports/" + mrd.Id
 
 	mrduuid := eh.NewUUID()
-	ts.ch.HandleCommand(
-		context.Background(),
-		&domain.CreateRedfishResource{
-			ID:          mrduuid,
-			ResourceURI: mrdURL,
-			Type:        "#MetricReportDefinition.v1_1_2.MetricReportDefinition",
-			Context:     "/redfish/v1/$metadata#MetricReportDefinition.MetricReportDefinition",
-			Privileges: map[string]interface{}{
-				"GET":    []string{"Login"},
-				"PATCH":  []string{"ConfigureManager"},
-				"DELETE": []string{"ConfigureManager"},
-			},
-			Properties: map[string]interface{}{
-				"Id":                         mrd.Id,
-				"Description":                mrd.Description,
-				"Name":                       mrd.Name,
-				"MetricReportDefinitionType": mrd.MetricReportDefinitionType,
-				// todo "Schedule": mrdSchedule,
-				"MetricReportDefinitionEnabled@meta": map[string]interface{}{
-					"DEFAULT": mrd.MetricReportDefinitionEnabled,
-					"PATCH": map[string]interface{}{
-						"plugin": "GenericBool"}},
-				"SuppressRepeatedMetricValue@meta": map[string]interface{}{
-					"DEFAULT": mrd.SuppressRepeatedMetricValue,
-					"PATCH": map[string]interface{}{
-						"plugin": "GenericBool"}},
-				"MetricReportHeartbeatInterval": mrd.MetricReportHeartbeatInterval,
-				"Wildcards":                     mrd.Wildcards,
-				"MetricProperties":              mrd.MetricProperties,
-				"ReportUpdates":                 "AppendWrapsWhenFull",
-				"ReportActions": []string{
-					"RedfishEvent", "LogToMetricReportsCollection"},
-				"MetricReport": map[string]interface{}{
-					"@odata.id": mrURL,
-				},
+	mrdCmd := &domain.CreateRedfishResource{
+		ID:          mrduuid,
+		ResourceURI: mrdURL,
+		Type:        "#MetricReportDefinition.v1_1_2.MetricReportDefinition",
+		Context:     "/redfish/v1/$metadata#MetricReportDefinition.MetricReportDefinition",
+		Deletable:   true,
+		Privileges: map[string]interface{}{
+			"GET":    []string{"Login"},
+			"PUT":    []string{"ConfigureManager"},
+			"PATCH":  []string{"ConfigureManager"},
+			"DELETE": []string{"ConfigureManager"},
+		},
+		Properties: map[string]interface{}{
+			"Id":                         mrd.Id,
+			"Description":                mrd.Description,
+			"Name":                       mrd.Name,
+			"MetricReportDefinitionType": mrd.MetricReportDefinitionType,
+			"MetricReportDefinitionEnabled@meta": map[string]interface{}{
+				"DEFAULT": mrd.MetricReportDefinitionEnabled,
+				"PATCH": map[string]interface{}{
+					"plugin": "GenericBool"}},
+			"SuppressRepeatedMetricValue@meta": map[string]interface{}{
+				"DEFAULT": mrd.SuppressRepeatedMetricValue,
+				"PATCH": map[string]interface{}{
+					"plugin": "GenericBool"}},
+			"MetricReportHeartbeatInterval": mrd.MetricReportHeartbeatInterval,
+			"Wildcards":                     mrd.Wildcards,
+			"MetricProperties":              mrd.MetricProperties,
+			"Metrics":                       metricsProperty(calcs),
+			"ReportUpdates":                 reportUpdates,
+			"AppendLimit":                   appendLimit,
+			"ReportActions": []string{
+				"RedfishEvent", "LogToMetricReportsCollection"},
+			"MetricReport": map[string]interface{}{
+				"@odata.id": mrURL,
 			},
-		})
-
-	ts.setMRDConfig(mrd.Id, mruuid, mrduuid, mrURL, mrdURL, mrd.MetricReportDefinitionEnabled, mrd.MetricReportDefinitionType, mrd.MetricProperties)
+		},
+	}
+	if mrd.MetricReportDefinitionType == "Periodic" {
+		mrdCmd.Properties["Schedule"] = map[string]interface{}{
+			"RecurrenceInterval@meta": map[string]interface{}{
+				"DEFAULT": mrd.Schedule.RecurrenceInterval,
+				"PATCH": map[string]interface{}{
+					"plugin": "TelemetryService"}},
+		}
+	}
+	ts.ch.HandleCommand(context.Background(), mrdCmd)
+
+	// wildcards are expanded to what is there now, and again as resources come and go
+	metricProperties := ts.expandMetricProperties(ctx, mrd.MetricProperties, wildCard)
+	mrdP := ts.setMRDConfig(mrd.Id, mruuid, mrduuid, mrURL, mrdURL, mrdPatch{
+		mrdType:       mrd.MetricReportDefinitionType,
+		mrdEnabled:    mrd.MetricReportDefinitionEnabled,
+		recurrence:    recurrence,
+		wildCard:      wildCard,
+		reportUpdates: reportUpdates,
+		appendLimit:   appendLimit,
+	}, mrd.MetricProperties, metricProperties)
+	ts.setMetricCalcs(ctx, mrdP, calcs)
+
+	mrProperties := metricReportProperties(mrd.Id, mrd.Description, mrdURL, []interface{}{}, "", "")
+	if mrd.MetricReportDefinitionType == "OnRequest" {
+		// the values are read when the report is, and set the count as well
+		delete(mrProperties, "MetricValues")
+		mrProperties["MetricValues@meta"] = map[string]interface{}{
+			"GET": map[string]interface{}{"plugin": "TelemetryService", "mrd": mrdURL}}
+	}
 
 	// Metric Report URL is provided with MRD, therefore creating Metric Report URL first
 	ts.ch.HandleCommand(
@@ -384,16 +545,7 @@ func (ts *TelemetryService) CreateMetricReportDefinition(ctx context.Context, mr
 			Privileges: map[string]interface{}{
 				"GET": []string{"Login"},
 			},
-			Properties: map[string]interface{}{
-				"Id":             mrd.Id,
-				"Description":    mrd.Description,
-				"Name":           "",
-				"ReportSequence": "",
-				"MetricReportDefinition": map[string]interface{}{
-					"@odata.id": mrdURL,
-				},
-				"MetricValues": []map[string]interface{}{},
-			},
+			Properties: mrProperties,
 		})
 
 	default_msg := domain.ExtendedInfo{}
@@ -14,11 +14,10 @@ func init() {
 
 // Properties should be in the format pathtoprop : prop_value
 type MetricValueEventData struct {
-	UUID             eh.UUID
-	Properties       map[string]interface{}
-	MetricId         string
-	MetricValue      string
-	Timestamp        string
-	MetricProperty   string
-	reportUpdateType string
+	UUID           eh.UUID
+	Properties     map[string]interface{}
+	MetricId       string
+	MetricValue    string
+	Timestamp      string
+	MetricProperty string
 }
@@ -0,0 +1,81 @@
+package telemetryservice
+
+// Wildcard is a {Name} in MetricProperties and the values it can have. A
+// value of "*" matches anything.
+type Wildcard struct {
+	Name   string
+	Values []string
+}
+
+// MetricDefinition describes the metrics read from one kind of sensor
+type MetricDefinition struct {
+	Id               string
+	Name             string
+	PhysicalContext  string
+	Units            string
+	MetricDataType   string
+	Accuracy         float64
+	Calibration      float64
+	MinReadingRange  float64
+	MaxReadingRange  float64
+	MetricProperties []string
+	Wildcards        []Wildcard
+}
+
+// SensorMetricDefinitions are the metrics read from the sensor resources made
+// by the thermal, fan, and power views. The wildcards in MetricProperties
+// match every chassis and sensor those views make.
+var SensorMetricDefinitions = []MetricDefinition{
+	{
+		Id:               "FanSpeed",
+		Name:             "Fan Speed Metric Definition",
+		PhysicalContext:  "Fan",
+		Units:            "{rev}/min",
+		MetricDataType:   "Decimal",
+		Accuracy:         1,
+		Calibration:      0,
+		MinReadingRange:  0,
+		MaxReadingRange:  30000,
+		MetricProperties: []string{"/redfish/v1/Chassis/{ChassisId}/Sensors/Fans/{FanId}#Reading"},
+		Wildcards:        []Wildcard{{Name: "ChassisId", Values: []string{"*"}}, {Name: "FanId", Values: []string{"*"}}},
+	},
+	{
+		Id:               "Temperature",
+		Name:             "Temperature Metric Definition",
+		PhysicalContext:  "SystemBoard",
+		Units:            "Cel",
+		MetricDataType:   "Decimal",
+		Accuracy:         1,
+		Calibration:      0,
+		MinReadingRange:  -128,
+		MaxReadingRange:  127,
+		MetricProperties: []string{"/redfish/v1/Chassis/System.Chassis.1/Sensors/Temperatures/{SensorId}#ReadingCelsius"},
+		Wildcards:        []Wildcard{{Name: "SensorId", Values: []string{"*"}}},
+	},
+	{
+		Id:               "PowerConsumption",
+		Name:             "Power Consumption Metric Definition",
+		PhysicalContext:  "PowerSupply",
+		Units:            "W",
+		MetricDataType:   "Decimal",
+		Accuracy:         1,
+		Calibration:      0,
+		MinReadingRange:  0,
+		MaxReadingRange:  32767,
+		MetricProperties: []string{"/redfish/v1/Chassis/{ChassisId}/Power/PowerControl#PowerConsumedWatts"},
+		Wildcards:        []Wildcard{{Name: "ChassisId", Values: []string{"*"}}},
+	},
+	{
+		Id:               "PowerSupplyInputVoltage",
+		Name:             "Power Supply Input Voltage Metric Definition",
+		PhysicalContext:  "PowerSupply",
+		Units:            "V",
+		MetricDataType:   "Decimal",
+		Accuracy:         1,
+		Calibration:      0,
+		MinReadingRange:  0,
+		MaxReadingRange:  300,
+		MetricProperties: []string{"/redfish/v1/Chassis/{ChassisId}/Power/PowerSupplies/{PsuId}#LineInputVoltage"},
+		Wildcards:        []Wildcard{{Name: "ChassisId", Values: []string{"*"}}, {Name: "PsuId", Values: []string{"*"}}},
+	},
+}
@@ -0,0 +1,147 @@
+package telemetryservice
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+
+	domain "github.com/superchalupa/sailfish/src/redfishresource"
+)
+
+// AppendLimit when the MetricReportDefinition doesn't give one
+const defaultAppendLimit = 150
+
+// how many timestamped reports a NewReport definition keeps around when
+// main.new_report_retention isn't set
+const defaultReportRetention = 10
+
+const reportTimeFormat = "2006-01-02T15:04:05-07:00"
+
+// reportInstance is a timestamped report made for a NewReport definition
+type reportInstance struct {
+	uri  string
+	uuid eh.UUID
+}
+
+// metricReportProperties are the properties of a MetricReport
+func metricReportProperties(id, description, mrdURI string, values []interface{}, timestamp, sequence string) map[string]interface{} {
+	return map[string]interface{}{
+		"Id":             id,
+		"Description":    description,
+		"Name":           "",
+		"ReportSequence": sequence,
+		"MetricReportDefinition": map[string]interface{}{
+			"@odata.id": mrdURI,
+		},
+		"Timestamp":                timestamp,
+		"MetricValues":             values,
+		"MetricValues@odata.count": len(values),
+	}
+}
+
+// updateReport puts new metric values into the MetricReport the way the
+// definition's ReportUpdates says to, and sends the report out
+func (ts *TelemetryService) updateReport(ctx context.Context, mrdP *mrdConfig, values []interface{}) {
+	ts.Lock()
+	mrdP.sequence++
+	sequence := strconv.Itoa(mrdP.sequence)
+	config := mrdP.config
+	name, mrUUID, mrdURI := mrdP.name, mrdP.mrUUID, mrdP.mrdURI
+	if config.mrdType == "OnChange" && config.reportUpdates != "AppendWrapsWhenFull" && config.reportUpdates != "AppendStopsWhenFull" {
+		// a single change only has the one value, the report has the latest of each
+		for _, v := range values {
+			if m, ok := v.(map[string]interface{}); ok {
+				prop, _ := m["MetricProperty"].(string)
+				id, _ := m["MetricId"].(string)
+				mrdP.latest[prop+" "+id] = v
+			}
+		}
+		keys := []string{}
+		for key := range mrdP.latest {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		values = []interface{}{}
+		for _, key := range keys {
+			values = append(values, mrdP.latest[key])
+		}
+	}
+	ts.Unlock()
+
+	timestamp := time.Now().UTC().Format(reportTimeFormat)
+	updateType := config.reportUpdates
+	if updateType == "NewReport" {
+		// the MetricReport always has the latest, earlier ones are kept as their own reports
+		updateType = "Overwrite"
+	}
+	ts.d.CommandHandler.HandleCommand(ctx,
+		&domain.UpdateMetricRedfishResource{
+			ID:               mrUUID,
+			AppendLimit:      config.appendLimit,
+			ReportUpdateType: updateType,
+			Properties: map[string]interface{}{
+				"MetricValues":   values,
+				"Timestamp":      timestamp,
+				"ReportSequence": sequence,
+			},
+		})
+	if config.reportUpdates == "NewReport" {
+		ts.newReport(ctx, mrdP, name, mrdURI, values, timestamp, sequence)
+	}
+	ts.publishReport(ctx, mrUUID)
+}
+
+// newReport makes a timestamped copy of a report and drops the oldest ones
+// past the retention limit
+func (ts *TelemetryService) newReport(ctx context.Context, mrdP *mrdConfig, name, mrdURI string, values []interface{}, timestamp, sequence string) {
+	id := name + "-" + time.Now().UTC().Format("20060102T150405Z")
+	retention := ts.reportRetention()
+	ts.Lock()
+	for _, r := range mrdP.reports {
+		if path.Base(r.uri) == id {
+			// more than one a second
+			id += "-" + sequence
+			break
+		}
+	}
+	report := reportInstance{uri: "/redfish/v1/TelemetryService/MetricReports/" + id, uuid: eh.NewUUID()}
+	mrdP.reports = append(mrdP.reports, report)
+	expired := []reportInstance{}
+	if len(mrdP.reports) > retention {
+		expired = append(expired, mrdP.reports[:len(mrdP.reports)-retention]...)
+		mrdP.reports = append([]reportInstance{}, mrdP.reports[len(mrdP.reports)-retention:]...)
+	}
+	ts.Unlock()
+
+	ts.d.CommandHandler.HandleCommand(ctx,
+		&domain.CreateRedfishResource{
+			ID:          report.uuid,
+			ResourceURI: report.uri,
+			Type:        "#MetricReport.v1_0_1.MetricReport",
+			Context:     "/redfish/v1/$metadata#MetricReport.MetricReport",
+			Privileges: map[string]interface{}{
+				"GET": []string{"Login"},
+			},
+			Properties: metricReportProperties(id, "", mrdURI, values, timestamp, sequence),
+		})
+	for _, r := range expired {
+		ts.d.CommandHandler.HandleCommand(ctx, &domain.RemoveRedfishResource{ID: r.uuid, ResourceURI: r.uri})
+	}
+}
+
+// reportRetention is how many timestamped reports a NewReport definition keeps
+func (ts *TelemetryService) reportRetention() int {
+	if ts.cfg == nil {
+		return defaultReportRetention
+	}
+	ts.cfgMu.RLock()
+	defer ts.cfgMu.RUnlock()
+	if n := ts.cfg.GetInt("main.new_report_retention"); n > 0 {
+		return n
+	}
+	return defaultReportRetention
+}
@@ -0,0 +1,88 @@
+package telemetryservice
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	eh "github.com/looplab/eventhorizon"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/superchalupa/sailfish/src/looplab/aggregatestore"
+	domain "github.com/superchalupa/sailfish/src/redfishresource"
+)
+
+// emptyRepo has no aggregates, so there's nothing in the reports to publish
+type emptyRepo struct{}
+
+func (emptyRepo) Parent() eh.ReadRepo { return nil }
+func (emptyRepo) Find(context.Context, eh.UUID) (eh.Entity, error) {
+	return nil, eh.RepoError{Err: eh.ErrEntityNotFound}
+}
+func (emptyRepo) FindAll(context.Context) ([]eh.Entity, error) { return nil, nil }
+func (emptyRepo) Save(context.Context, eh.Entity) error        { return nil }
+func (emptyRepo) Remove(context.Context, eh.UUID) error        { return nil }
+
+func TestNewReportRotation(t *testing.T) {
+	ctx := context.Background()
+	ch := &recordingHandler{}
+	store, _ := aggregatestore.NewAggregateStore(emptyRepo{})
+	cfg := viper.New()
+	cfg.Set("main.new_report_retention", 2)
+	ts := &TelemetryService{ctx: ctx, cfg: cfg, cfgMu: &sync.RWMutex{}, d: &domain.DomainObjects{CommandHandler: ch, AggregateStore: store}}
+	mrdP := &mrdConfig{
+		name:   "Power",
+		mrUUID: eh.NewUUID(),
+		mrdURI: "/redfish/v1/TelemetryService/MetricReportDefinitions/Power",
+		config: mrdPatch{mrdType: "Periodic", reportUpdates: "NewReport"},
+		latest: map[string]interface{}{},
+	}
+
+	kept := [][]reportInstance{}
+	for i := 0; i < 3; i++ {
+		ts.updateReport(ctx, mrdP, []interface{}{map[string]interface{}{"MetricId": "Power", "MetricValue": i}})
+		kept = append(kept, mrdP.reports)
+	}
+	assert.Len(t, kept[0], 1)
+	assert.Len(t, kept[1], 2)
+	assert.Len(t, kept[2], 2, "only main.new_report_retention reports are kept")
+	assert.Equal(t, kept[1][1], kept[2][0])
+	assert.Equal(t, 3, mrdP.sequence)
+
+	overwrites, created, removed := 0, []string{}, []string{}
+	for _, cmd := range ch.cmds {
+		switch c := cmd.(type) {
+		case *domain.UpdateMetricRedfishResource:
+			assert.Equal(t, mrdP.mrUUID, c.ID)
+			assert.Equal(t, "Overwrite", c.ReportUpdateType, "the MetricReport only has the latest")
+			overwrites++
+		case *domain.CreateRedfishResource:
+			assert.True(t, strings.HasPrefix(c.ResourceURI, "/redfish/v1/TelemetryService/MetricReports/Power-"))
+			created = append(created, c.ResourceURI)
+		case *domain.RemoveRedfishResource:
+			removed = append(removed, c.ResourceURI)
+		}
+	}
+	assert.Equal(t, 3, overwrites)
+	assert.Len(t, created, 3)
+	assert.Equal(t, []string{kept[0][0].uri}, removed, "the oldest report is removed")
+	assert.NotEqual(t, kept[0][0].uri, kept[1][1].uri, "reports made in the same second get their own uri")
+
+	ts.cfg = viper.New()
+	assert.Equal(t, defaultReportRetention, ts.reportRetention())
+}
+
+func TestOnRequestCount(t *testing.T) {
+	ts := &TelemetryService{}
+	agg := &domain.RedfishResourceAggregate{}
+	agg.Properties.Parse(map[string]interface{}{"MetricValues@odata.count": 5})
+	rrp := &domain.RedfishResourceProperty{}
+
+	err := ts.PropertyGet(context.Background(), agg, &domain.RedfishAuthorizationProperty{}, rrp, map[string]interface{}{"mrd": "/redfish/v1/TelemetryService/MetricReportDefinitions/Gone"})
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{}, rrp.Value)
+	report := domain.Flatten(&agg.Properties, false).(map[string]interface{})
+	assert.Equal(t, 0, report["MetricValues@odata.count"], "the count is from the same sample as the values")
+}
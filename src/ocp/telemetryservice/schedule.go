@@ -0,0 +1,255 @@
+package telemetryservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+
+	"github.com/superchalupa/sailfish/src/ocp/eventservice"
+	domain "github.com/superchalupa/sailfish/src/redfishresource"
+)
+
+// periodic reports can't come faster than this
+const minRecurrenceInterval = time.Second
+
+// Schedule is the part of the Redfish Schedule that periodic metric reports use
+type Schedule struct {
+	RecurrenceInterval string `json:",omitempty"`
+}
+
+// redfish durations are the ISO 8601 subset "PnDTnHnMn.nS"
+var durationRegexp = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseDuration parses a redfish Duration like "PT1M30S" or "P1DT12H"
+func parseDuration(s string) (time.Duration, error) {
+	m := durationRegexp.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("%s is not an ISO 8601 duration", s)
+	}
+
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(m[i+1], 64)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(n * float64(unit))
+	}
+	return d, nil
+}
+
+// recurrenceInterval checks the RecurrenceInterval for a periodic report
+func recurrenceInterval(s string) (time.Duration, error) {
+	d, err := parseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < minRecurrenceInterval {
+		return 0, errors.New("RecurrenceInterval is too short")
+	}
+	return d, nil
+}
+
+// schedule starts, stops, or restarts the timer for a periodic report to
+// match its config. Call it with ts locked.
+func (ts *TelemetryService) schedule(mrdP *mrdConfig) {
+	if mrdP.cancel != nil {
+		mrdP.cancel()
+		mrdP.cancel = nil
+	}
+	if !mrdP.config.mrdEnabled || mrdP.config.mrdType != "Periodic" || mrdP.config.recurrence <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ts.ctx)
+	mrdP.cancel = cancel
+	go func(interval time.Duration) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ts.sendReport(ctx, mrdP)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(mrdP.config.recurrence)
+}
+
+// sendReport builds the metric report from the current values of the
+// MetricProperties and sends it out
+func (ts *TelemetryService) sendReport(ctx context.Context, mrdP *mrdConfig) {
+	ts.RLock()
+	props := append([]string{}, mrdP.metricProperties...)
+	ts.RUnlock()
+
+	timestamp := time.Now().UTC().Format(reportTimeFormat)
+	values := ts.sampleMetricValues(ctx, props, timestamp)
+	values = append(values, ts.calculatedMetricValues(ctx, mrdP, timestamp)...)
+	ts.updateReport(ctx, mrdP, values)
+}
+
+// sampleMetricValues reads the current value of each of the MetricProperties.
+// Properties that aren't there right now are left out.
+func (ts *TelemetryService) sampleMetricValues(ctx context.Context, props []string, timestamp string) []interface{} {
+	values := []interface{}{}
+	for _, prop := range props {
+		value, ok := ts.sample(ctx, prop)
+		if !ok {
+			continue
+		}
+		values = append(values, map[string]interface{}{
+			"MetricId":       path.Base(strings.SplitN(prop, "#", 2)[1]),
+			"MetricValue":    fmt.Sprintf("%v", value),
+			"Timestamp":      timestamp,
+			"MetricProperty": prop,
+		})
+	}
+	return values
+}
+
+// sample reads a MetricProperties entry, "uri#path/to/property", from the resource tree
+func (ts *TelemetryService) sample(ctx context.Context, metricProperty string) (interface{}, bool) {
+	parts := strings.SplitN(metricProperty, "#", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+	aggID, ok := ts.d.GetAggregateIDOK(parts[0])
+	if !ok {
+		return nil, false
+	}
+	agg, err := ts.d.AggregateStore.Load(ctx, domain.AggregateType, aggID)
+	if err != nil {
+		return nil, false
+	}
+	redfishResource, ok := agg.(*domain.RedfishResourceAggregate)
+	if !ok {
+		return nil, false
+	}
+
+	// run the GET plugins so values that come from models are current
+	domain.NewGet(ctx, redfishResource, &redfishResource.Properties, &domain.RedfishAuthorizationProperty{})
+	value := domain.Flatten(&redfishResource.Properties, false)
+	for _, p := range strings.Split(strings.Trim(parts[1], "/"), "/") {
+		if p == "" {
+			// the whole resource
+			continue
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value, ok = v[p]
+		case []interface{}:
+			i, err := strconv.Atoi(p)
+			ok = err == nil && i >= 0 && i < len(v)
+			if ok {
+				value = v[i]
+			}
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// publishReport sends the metric report out to subscribers
+func (ts *TelemetryService) publishReport(ctx context.Context, mrUUID eh.UUID) {
+	agg, err := ts.d.AggregateStore.Load(ctx, domain.AggregateType, mrUUID)
+	if err != nil {
+		return
+	}
+	redfishResource, ok := agg.(*domain.RedfishResourceAggregate)
+	if !ok {
+		return
+	}
+	resultD, ok := domain.Flatten(&redfishResource.Properties, false).(map[string]interface{})
+	if !ok {
+		return
+	}
+	eventData := eventservice.MetricReportData{Data: resultD}
+	ts.d.EventBus.PublishEvent(ctx, eh.NewEvent(eventservice.ExternalMetricEvent, eventData, time.Now()))
+}
+
+func (ts *TelemetryService) PluginType() domain.PluginType {
+	return domain.PluginType("TelemetryService")
+}
+
+// PropertyGet builds the MetricValues of an OnRequest report, and the
+// Wildcards of a MetricDefinition, when they are read. The report's
+// MetricValues@odata.count is set from the same sample.
+func (ts *TelemetryService) PropertyGet(ctx context.Context, agg *domain.RedfishResourceAggregate, auth *domain.RedfishAuthorizationProperty, rrp *domain.RedfishResourceProperty, meta map[string]interface{}) error {
+	if id, ok := meta["metricdefinition"].(string); ok {
+		rrp.Value = []interface{}{}
+		for _, md := range SensorMetricDefinitions {
+			if md.Id == id {
+				rrp.Value = ts.wildcardValues(ctx, md.MetricProperties, md.Wildcards)
+			}
+		}
+		return nil
+	}
+
+	mrdURI, _ := meta["mrd"].(string)
+	ts.RLock()
+	_, mrdP := ts.findMRDConfig(mrdURI)
+	props := []string{}
+	if mrdP != nil && mrdP.config.mrdEnabled {
+		props = append(props, mrdP.metricProperties...)
+	}
+	ts.RUnlock()
+
+	timestamp := time.Now().UTC().Format(reportTimeFormat)
+	samples := ts.sampleMetricValues(ctx, props, timestamp)
+	if mrdP != nil && mrdP.config.mrdEnabled {
+		samples = append(samples, ts.calculatedMetricValues(ctx, mrdP, timestamp)...)
+	}
+	values := []interface{}{}
+	for _, v := range samples {
+		values = append(values, &domain.RedfishResourceProperty{Value: v})
+	}
+	rrp.Value = values
+
+	// the GET has the report locked
+	if props, ok := agg.Properties.Value.(map[string]interface{}); ok {
+		if count, ok := props["MetricValues@odata.count"].(*domain.RedfishResourceProperty); ok {
+			count.Lock()
+			count.Value = len(values)
+			count.Unlock()
+		}
+	}
+	return nil
+}
+
+// PropertyPatch checks a new Schedule/RecurrenceInterval for a periodic
+// report and restarts its timer with it
+func (ts *TelemetryService) PropertyPatch(ctx context.Context, agg *domain.RedfishResourceAggregate, auth *domain.RedfishAuthorizationProperty, rrp *domain.RedfishResourceProperty, encopts *domain.NuEncOpts, meta map[string]interface{}) error {
+	interval, ok := encopts.Parse.(string)
+	recurrence, err := recurrenceInterval(interval)
+	if !ok || err != nil {
+		msg, _ := json.Marshal(domain.PropertyValueFormatError(fmt.Sprintf("%v", encopts.Parse), "Schedule/RecurrenceInterval"))
+		return domain.AddEEMIMessage(encopts.HttpResponse, agg, "PATCHERROR", &domain.HTTP_code{Err_message: []string{string(msg)}})
+	}
+
+	ts.Lock()
+	if _, mrdP := ts.findMRDConfig(agg.ResourceURI); mrdP != nil {
+		mrdP.config.recurrence = recurrence
+		ts.schedule(mrdP)
+	}
+	ts.Unlock()
+
+	rrp.Value = interval
+	return domain.AddEEMIMessage(encopts.HttpResponse, agg, "SUCCESS", nil)
+}
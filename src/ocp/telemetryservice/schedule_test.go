@@ -0,0 +1,114 @@
+package telemetryservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+	"github.com/stretchr/testify/assert"
+
+	domain "github.com/superchalupa/sailfish/src/redfishresource"
+)
+
+type recordingHandler struct {
+	cmds []eh.Command
+}
+
+func (h *recordingHandler) HandleCommand(ctx context.Context, cmd eh.Command) error {
+	h.cmds = append(h.cmds, cmd)
+	return nil
+}
+
+func TestParseDuration(t *testing.T) {
+	var tests = []struct {
+		testname string
+		input    string
+		expected time.Duration
+		valid    bool
+	}{
+		{"seconds", "PT10S", 10 * time.Second, true},
+		{"fraction", "PT0.5S", 500 * time.Millisecond, true},
+		{"minutes and seconds", "PT1M30S", 90 * time.Second, true},
+		{"days and hours", "P1DT12H", 36 * time.Hour, true},
+		{"days", "P2D", 48 * time.Hour, true},
+		{"empty", "", 0, false},
+		{"no units", "P", 0, false},
+		{"no time units", "PT", 0, false},
+		{"go duration", "10s", 0, false},
+		{"out of order", "PT10S1M", 0, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testname, func(t *testing.T) {
+			d, err := parseDuration(tc.input)
+			assert.Equal(t, tc.valid, err == nil)
+			assert.Equal(t, tc.expected, d)
+		})
+	}
+
+	_, err := recurrenceInterval("PT0.5S")
+	assert.NotNil(t, err, "too short")
+}
+
+func TestSchedule(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := &recordingHandler{}
+	ts := &TelemetryService{ctx: ctx, d: &domain.DomainObjects{CommandHandler: ch}, metric2Report: map[string][]*mrdConfig{}}
+	mrdURI := "/redfish/v1/TelemetryService/MetricReportDefinitions/Power"
+
+	mrdP := ts.setMRDConfig("Power", eh.NewUUID(), eh.NewUUID(), "/redfish/v1/TelemetryService/MetricReports/Power", mrdURI,
+		mrdPatch{mrdType: "Periodic", mrdEnabled: true, recurrence: time.Hour}, nil, nil)
+	assert.NotNil(t, mrdP.cancel, "enabled periodic reports start the timer")
+
+	ts.patchMRDConfig(mrdURI, map[string]interface{}{"MetricReportDefinitionEnabled": false})
+	assert.Nil(t, mrdP.cancel, "disabling stops it")
+	ts.patchMRDConfig(mrdURI, map[string]interface{}{"MetricReportDefinitionEnabled": true})
+	assert.NotNil(t, mrdP.cancel)
+
+	var response map[string]interface{}
+	patch := func(value interface{}) (*domain.RedfishResourceAggregate, *domain.RedfishResourceProperty) {
+		agg := &domain.RedfishResourceAggregate{ResourceURI: mrdURI}
+		rrp := &domain.RedfishResourceProperty{Value: "PT1H"}
+		response = map[string]interface{}{}
+		err := ts.PropertyPatch(ctx, agg, &domain.RedfishAuthorizationProperty{}, rrp, &domain.NuEncOpts{Parse: value, HttpResponse: response}, nil)
+		assert.Nil(t, err)
+		return agg, rrp
+	}
+
+	stopped := false
+	oldCancel := mrdP.cancel
+	mrdP.cancel = func() { stopped = true; oldCancel() }
+	agg, rrp := patch("PT2H")
+	assert.Equal(t, 200, agg.StatusCode)
+	assert.Equal(t, "PT2H", rrp.Value)
+	assert.True(t, stopped, "a new RecurrenceInterval restarts the timer")
+	assert.NotNil(t, mrdP.cancel)
+	assert.Equal(t, 2*time.Hour, mrdP.config.recurrence)
+
+	for _, bad := range []interface{}{"10s", "PT0.1S", 10} {
+		agg, rrp = patch(bad)
+		assert.Equal(t, 400, agg.StatusCode)
+		errObj, _ := response["error"].(map[string]interface{})
+		assert.Equal(t, "Base.1.0.PropertyValueFormatError", errObj["code"])
+		assert.Equal(t, "PT1H", rrp.Value)
+		assert.Equal(t, 2*time.Hour, mrdP.config.recurrence, "bad intervals are not kept")
+	}
+
+	other := "/redfish/v1/TelemetryService/MetricReportDefinitions/Thermal"
+	tc := &triggerConfig{uuid: eh.NewUUID(), mrdURIs: []string{mrdURI, other}}
+	ts.triggers = []*triggerConfig{tc, {uuid: eh.NewUUID(), mrdURIs: []string{other}}}
+
+	ts.removeMRDConfig(ctx, mrdURI)
+	assert.Nil(t, mrdP.cancel, "deleting the definition stops it")
+	assert.Empty(t, ts.mrdConfigL)
+	assert.Equal(t, []string{other}, tc.mrdURIs, "triggers don't link to the deleted definition")
+	if assert.Len(t, ch.cmds, 2, "only the trigger that linked to it is updated") {
+		update, ok := ch.cmds[1].(*domain.UpdateRedfishResourceProperties)
+		if assert.True(t, ok) {
+			assert.Equal(t, tc.uuid, update.ID)
+			assert.Equal(t, 1, update.Properties["Links"].(map[string]interface{})["MetricReportDefinitions@odata.count"])
+		}
+	}
+}
@@ -0,0 +1,533 @@
+package telemetryservice
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+
+	"github.com/superchalupa/sailfish/src/ocp/eventservice"
+	domain "github.com/superchalupa/sailfish/src/redfishresource"
+)
+
+const triggersURI = "/redfish/v1/TelemetryService/Triggers/"
+
+// the messages triggers send are in the Telemetry registry
+const triggerMessagePrefix = "Telemetry.1.0."
+
+// threshold is one of the NumericThresholds of a trigger
+type threshold struct {
+	name       string
+	reading    float64
+	activation string
+	dwell      time.Duration
+	upper      bool
+}
+
+type discreteTrigger struct {
+	value    string
+	severity string
+	dwell    time.Duration
+}
+
+type triggerConfig struct {
+	uri     string
+	uuid    eh.UUID
+	trigger Trigger
+
+	// the most severe first, so the zone a reading is in is the first one it is past
+	thresholds []threshold
+	discrete   []discreteTrigger
+	mrdURIs    []string
+
+	// MetricProperties as they were given, with wildcards
+	patterns         []string
+	wildCard         map[string][]string
+	metricProperties []string
+
+	// the last value of each metric property
+	last map[string]interface{}
+	// firings waiting out their DwellTime, for each metric property
+	pending map[string]*time.Timer
+}
+
+// firing is what a trigger sends when a metric property activates it
+type firing struct {
+	messageId string
+	severity  string
+	args      []string
+	dwell     time.Duration
+	// true if the value still activates the trigger after the DwellTime
+	holds func(value interface{}) bool
+}
+
+// newTriggerConfig checks a Trigger that was POSTed and gets it ready to be evaluated
+func newTriggerConfig(t Trigger) (*triggerConfig, []domain.ExtendedInfo) {
+	errs := []domain.ExtendedInfo{}
+	tc := &triggerConfig{
+		uri:      triggersURI + t.Id,
+		trigger:  t,
+		patterns: append([]string{}, t.MetricProperties...),
+		last:     map[string]interface{}{},
+		pending:  map[string]*time.Timer{},
+	}
+
+	if t.Id == "" {
+		errs = append(errs, domain.PropertyMissing("Id"))
+	} else if strings.ContainsAny(t.Id, "/#?") {
+		errs = append(errs, domain.PropertyValueFormatError(t.Id, "Id"))
+	}
+	if len(t.MetricProperties) == 0 {
+		errs = append(errs, domain.PropertyMissing("MetricProperties"))
+	}
+	for _, prop := range t.MetricProperties {
+		if !strings.HasPrefix(prop, "/redfish/v1/") || !strings.Contains(prop, "#") {
+			errs = append(errs, domain.PropertyValueFormatError(prop, "MetricProperties"))
+		}
+	}
+
+	if len(t.TriggerActions) == 0 {
+		errs = append(errs, domain.PropertyMissing("TriggerActions"))
+	}
+	for _, action := range t.TriggerActions {
+		switch action {
+		case "RedfishEvent":
+		case "RedfishMetricReport":
+			if len(t.Links.MetricReportDefinitions) == 0 {
+				errs = append(errs, domain.PropertyMissing("Links/MetricReportDefinitions"))
+			}
+		default:
+			errs = append(errs, domain.PropertyValueNotInList(action, "TriggerActions"))
+		}
+	}
+	for _, link := range t.Links.MetricReportDefinitions {
+		tc.mrdURIs = append(tc.mrdURIs, link.ID)
+	}
+
+	var wcErrs []domain.ExtendedInfo
+	tc.wildCard, wcErrs = parseWildcards(t.Wildcards)
+	errs = append(errs, wcErrs...)
+
+	switch t.MetricType {
+	case "Numeric":
+		for _, th := range []struct {
+			name  string
+			t     *Threshold
+			upper bool
+		}{
+			{"UpperCritical", t.NumericThresholds.UpperCritical, true},
+			{"LowerCritical", t.NumericThresholds.LowerCritical, false},
+			{"UpperWarning", t.NumericThresholds.UpperWarning, true},
+			{"LowerWarning", t.NumericThresholds.LowerWarning, false},
+		} {
+			if th.t == nil {
+				continue
+			}
+			if th.t.Reading == nil {
+				errs = append(errs, domain.PropertyMissing("NumericThresholds/"+th.name+"/Reading"))
+				continue
+			}
+			activation := th.t.Activation
+			switch activation {
+			case "":
+				activation = "Either"
+			case "Increasing", "Decreasing", "Either":
+			default:
+				errs = append(errs, domain.PropertyValueNotInList(activation, "NumericThresholds/"+th.name+"/Activation"))
+			}
+			dwell, err := dwellTime(th.t.DwellTime)
+			if err != nil {
+				errs = append(errs, domain.PropertyValueFormatError(th.t.DwellTime, "NumericThresholds/"+th.name+"/DwellTime"))
+			}
+			tc.thresholds = append(tc.thresholds, threshold{name: th.name, reading: *th.t.Reading, activation: activation, dwell: dwell, upper: th.upper})
+		}
+		if len(tc.thresholds) == 0 && len(errs) == 0 {
+			errs = append(errs, domain.PropertyMissing("NumericThresholds"))
+		}
+
+	case "Discrete":
+		switch t.DiscreteTriggerCondition {
+		case "Specified":
+			if len(t.DiscreteTriggers) == 0 {
+				errs = append(errs, domain.PropertyMissing("DiscreteTriggers"))
+			}
+		case "Changed":
+		default:
+			errs = append(errs, domain.PropertyValueNotInList(t.DiscreteTriggerCondition, "DiscreteTriggerCondition"))
+		}
+		for i, dt := range t.DiscreteTriggers {
+			dwell, err := dwellTime(dt.DwellTime)
+			if err != nil {
+				errs = append(errs, domain.PropertyValueFormatError(dt.DwellTime, "DiscreteTriggers/"+strconv.Itoa(i)+"/DwellTime"))
+			}
+			severity := dt.Severity
+			switch severity {
+			case "":
+				severity = "Warning"
+			case "OK", "Warning", "Critical":
+			default:
+				errs = append(errs, domain.PropertyValueNotInList(severity, "DiscreteTriggers/"+strconv.Itoa(i)+"/Severity"))
+			}
+			tc.discrete = append(tc.discrete, discreteTrigger{value: dt.Value, severity: severity, dwell: dwell})
+		}
+
+	default:
+		errs = append(errs, domain.PropertyValueNotInList(t.MetricType, "MetricType"))
+	}
+
+	return tc, errs
+}
+
+// dwellTime parses a DwellTime, which can be left out
+func dwellTime(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return parseDuration(s)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func formatValue(value interface{}) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// zone returns the threshold a reading is past, or nil if it is normal
+func (tc *triggerConfig) zone(reading float64) *threshold {
+	for i, th := range tc.thresholds {
+		if (th.upper && reading > th.reading) || (!th.upper && reading < th.reading) {
+			return &tc.thresholds[i]
+		}
+	}
+	return nil
+}
+
+// evaluate checks a new value of a metric property and returns what it
+// activates, or nil. Call it with ts locked.
+func (tc *triggerConfig) evaluate(metricProperty string, value interface{}) *firing {
+	prev, havePrev := tc.last[metricProperty]
+	tc.last[metricProperty] = value
+
+	if tc.trigger.MetricType == "Discrete" {
+		return tc.evaluateDiscrete(metricProperty, prev, havePrev, value)
+	}
+
+	reading, ok := toFloat(value)
+	if !ok {
+		return nil
+	}
+	prevReading, havePrev := toFloat(prev)
+
+	var activated *threshold
+	for i, th := range tc.thresholds {
+		up := reading > th.reading && ((havePrev && prevReading <= th.reading) || (!havePrev && th.upper))
+		down := reading < th.reading && ((havePrev && prevReading >= th.reading) || (!havePrev && !th.upper))
+		if (th.activation == "Increasing" && up) || (th.activation == "Decreasing" && down) || (th.activation == "Either" && (up || down)) {
+			activated = &tc.thresholds[i]
+			break
+		}
+	}
+	if activated == nil {
+		return nil
+	}
+
+	// the message is for where the reading is now
+	z := tc.zone(reading)
+	f := &firing{
+		messageId: triggerMessagePrefix + "TriggerNumericReadingNormal",
+		severity:  "OK",
+		args:      []string{metricProperty, formatValue(value)},
+		dwell:     activated.dwell,
+		holds: func(value interface{}) bool {
+			r, ok := toFloat(value)
+			return ok && tc.zone(r) == z
+		},
+	}
+	if z != nil {
+		f.args = append(f.args, formatValue(z.reading))
+		switch z.name {
+		case "UpperCritical":
+			f.messageId, f.severity = triggerMessagePrefix+"TriggerNumericAboveUpperCritical", "Critical"
+		case "UpperWarning":
+			f.messageId, f.severity = triggerMessagePrefix+"TriggerNumericAboveUpperWarning", "Warning"
+		case "LowerCritical":
+			f.messageId, f.severity = triggerMessagePrefix+"TriggerNumericBelowLowerCritical", "Critical"
+		case "LowerWarning":
+			f.messageId, f.severity = triggerMessagePrefix+"TriggerNumericBelowLowerWarning", "Warning"
+		}
+	}
+	return f
+}
+
+func (tc *triggerConfig) evaluateDiscrete(metricProperty string, prev interface{}, havePrev bool, value interface{}) *firing {
+	s := formatValue(value)
+	changed := !havePrev || formatValue(prev) != s
+
+	f := &firing{
+		messageId: triggerMessagePrefix + "TriggerDiscreteConditionMet",
+		args:      []string{metricProperty, s},
+		holds:     func(value interface{}) bool { return formatValue(value) == s },
+	}
+	if tc.trigger.DiscreteTriggerCondition == "Changed" {
+		if !havePrev || !changed {
+			return nil
+		}
+		f.severity = "Warning"
+		return f
+	}
+
+	if !changed {
+		return nil
+	}
+	for _, dt := range tc.discrete {
+		if dt.value == s {
+			f.severity = dt.severity
+			f.dwell = dt.dwell
+			return f
+		}
+	}
+	return nil
+}
+
+// CreateTrigger makes a Trigger resource from a POST to the Triggers collection and starts evaluating it
+func (ts *TelemetryService) CreateTrigger(ctx context.Context, t Trigger, data *domain.HTTPCmdProcessedData) (eh.UUID, bool) {
+	tc, errs := newTriggerConfig(t)
+	if len(errs) == 0 && ts.d.HasAggregateID(tc.uri) {
+		errs = append(errs, domain.ResourceAlreadyExists("Triggers", "Id", t.Id))
+	}
+	ts.RLock()
+	for _, mrdURI := range tc.mrdURIs {
+		if _, mrdP := ts.findMRDConfig(mrdURI); mrdP == nil {
+			errs = append(errs, domain.ResourceMissingAtURI(mrdURI))
+		}
+	}
+	ts.RUnlock()
+	if len(errs) > 0 {
+		data.Results = domain.ErrorResponse(errs...)
+		data.StatusCode = 400
+		return "", false
+	}
+
+	tc.uuid = eh.NewUUID()
+	properties := map[string]interface{}{
+		"Id":               t.Id,
+		"Name":             t.Name,
+		"Description":      t.Description,
+		"MetricType":       t.MetricType,
+		"TriggerActions":   t.TriggerActions,
+		"MetricProperties": t.MetricProperties,
+	}
+	if len(t.Wildcards) > 0 {
+		wildcards := []interface{}{}
+		for _, wc := range t.Wildcards {
+			wildcards = append(wildcards, &domain.RedfishResourceProperty{Value: wc})
+		}
+		properties["Wildcards"] = wildcards
+	}
+	if t.MetricType == "Numeric" {
+		thresholds := map[string]interface{}{}
+		for _, th := range tc.thresholds {
+			thresholds[th.name] = map[string]interface{}{
+				"Reading":    th.reading,
+				"Activation": th.activation,
+				"DwellTime":  formatDuration(th.dwell),
+			}
+		}
+		properties["NumericThresholds"] = thresholds
+	} else {
+		discrete := []interface{}{}
+		for i, dt := range tc.discrete {
+			discrete = append(discrete, &domain.RedfishResourceProperty{Value: map[string]interface{}{
+				"Name":      t.DiscreteTriggers[i].Name,
+				"Value":     dt.value,
+				"Severity":  dt.severity,
+				"DwellTime": formatDuration(dt.dwell),
+			}})
+		}
+		properties["DiscreteTriggerCondition"] = t.DiscreteTriggerCondition
+		properties["DiscreteTriggers"] = discrete
+	}
+	properties["Links"] = tc.links()
+
+	ts.ch.HandleCommand(ctx,
+		&domain.CreateRedfishResource{
+			ID:          tc.uuid,
+			ResourceURI: tc.uri,
+			Type:        "#Triggers.v1_0_0.Triggers",
+			Context:     "/redfish/v1/$metadata#Triggers.Triggers",
+			Deletable:   true,
+			Privileges: map[string]interface{}{
+				"GET":    []string{"Login"},
+				"DELETE": []string{"ConfigureManager"},
+			},
+			Properties: properties,
+		})
+
+	tc.metricProperties = ts.expandMetricProperties(ctx, tc.patterns, tc.wildCard)
+	ts.Lock()
+	ts.triggers = append(ts.triggers, tc)
+	ts.Unlock()
+
+	data.StatusCode = 201
+	return tc.uuid, true
+}
+
+// links are the Links of the Trigger resource
+func (tc *triggerConfig) links() map[string]interface{} {
+	links := []interface{}{}
+	for _, mrdURI := range tc.mrdURIs {
+		links = append(links, &domain.RedfishResourceProperty{Value: map[string]interface{}{"@odata.id": mrdURI}})
+	}
+	return map[string]interface{}{
+		"MetricReportDefinitions":             links,
+		"MetricReportDefinitions@odata.count": len(links),
+	}
+}
+
+// unlinkMRD drops a deleted MetricReportDefinition from the triggers that
+// link to it and returns the commands that update their Links. Call it with ts locked.
+func (ts *TelemetryService) unlinkMRD(mrdURI string) []eh.Command {
+	cmds := []eh.Command{}
+	for _, tc := range ts.triggers {
+		mrdURIs := []string{}
+		for _, uri := range tc.mrdURIs {
+			if uri != mrdURI {
+				mrdURIs = append(mrdURIs, uri)
+			}
+		}
+		if len(mrdURIs) == len(tc.mrdURIs) {
+			continue
+		}
+		tc.mrdURIs = mrdURIs
+		cmds = append(cmds, &domain.UpdateRedfishResourceProperties{
+			ID:         tc.uuid,
+			Properties: map[string]interface{}{"Links": tc.links()},
+		})
+	}
+	return cmds
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("PT%gS", d.Seconds())
+}
+
+// removeTrigger stops evaluating a Trigger that was deleted
+func (ts *TelemetryService) removeTrigger(uri string) {
+	ts.Lock()
+	defer ts.Unlock()
+	for i, tc := range ts.triggers {
+		if tc.uri != uri {
+			continue
+		}
+		for _, t := range tc.pending {
+			t.Stop()
+		}
+		tc.pending = map[string]*time.Timer{}
+		ts.triggers = append(ts.triggers[:i], ts.triggers[i+1:]...)
+		return
+	}
+}
+
+// evaluateTriggers checks the triggers on the properties that changed
+func (ts *TelemetryService) evaluateTriggers(ctx context.Context, data *domain.RedfishResourcePropertiesUpdatedData2) {
+	fire := []func(){}
+
+	ts.Lock()
+	for _, tc := range ts.triggers {
+		for _, mp := range tc.metricProperties {
+			parts := strings.SplitN(mp, "#", 2)
+			if len(parts) != 2 || parts[0] != data.ResourceURI {
+				continue
+			}
+			value, ok := data.PropertyNames[strings.Trim(parts[1], "/")]
+			if !ok {
+				continue
+			}
+			f := tc.evaluate(mp, value)
+			if f == nil {
+				continue
+			}
+
+			if t, ok := tc.pending[mp]; ok {
+				t.Stop()
+				delete(tc.pending, mp)
+			}
+			tc, mp := tc, mp
+			if f.dwell <= 0 {
+				fire = append(fire, func() { ts.fireTrigger(ctx, tc, mp, f) })
+				continue
+			}
+
+			var timer *time.Timer
+			timer = time.AfterFunc(f.dwell, func() {
+				ts.Lock()
+				still := tc.pending[mp] == timer && f.holds(tc.last[mp])
+				if tc.pending[mp] == timer {
+					delete(tc.pending, mp)
+				}
+				ts.Unlock()
+				if still {
+					ts.fireTrigger(ctx, tc, mp, f)
+				}
+			})
+			tc.pending[mp] = timer
+		}
+	}
+	ts.Unlock()
+
+	for _, fn := range fire {
+		fn()
+	}
+}
+
+// fireTrigger does the TriggerActions of a trigger
+func (ts *TelemetryService) fireTrigger(ctx context.Context, tc *triggerConfig, metricProperty string, f *firing) {
+	for _, action := range tc.trigger.TriggerActions {
+		switch action {
+		case "RedfishEvent":
+			ts.d.EventBus.PublishEvent(ctx, eh.NewEvent(eventservice.RedfishEvent, &eventservice.RedfishEventData{
+				EventType:         "Alert",
+				EventTimestamp:    time.Now().UTC().Format(time.RFC3339),
+				Severity:          f.severity,
+				MessageId:         f.messageId,
+				MessageArgs:       f.args,
+				OriginOfCondition: strings.SplitN(metricProperty, "#", 2)[0],
+			}, time.Now()))
+
+		case "RedfishMetricReport":
+			ts.RLock()
+			mrdURIs := tc.mrdURIs
+			ts.RUnlock()
+			for _, mrdURI := range mrdURIs {
+				ts.RLock()
+				_, mrdP := ts.findMRDConfig(mrdURI)
+				enabled := mrdP != nil && mrdP.config.mrdEnabled
+				ts.RUnlock()
+				if enabled {
+					ts.sendReport(ctx, mrdP)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package telemetryservice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func reading(f float64) *float64 { return &f }
+
+func TestNewTriggerConfig(t *testing.T) {
+	var tests = []struct {
+		testname string
+		trigger  Trigger
+		errors   []string
+	}{
+		{"numeric", Trigger{
+			Id: "FanHigh", MetricType: "Numeric", TriggerActions: []string{"RedfishEvent"},
+			MetricProperties:  []string{"/redfish/v1/Chassis/{ChassisId}/Sensors/Fans/{FanId}#Reading"},
+			NumericThresholds: NumericThresholds{UpperWarning: &Threshold{Reading: reading(9000), DwellTime: "PT30S"}},
+		}, []string{}},
+		{"discrete", Trigger{
+			Id: "PsuFailed", MetricType: "Discrete", TriggerActions: []string{"RedfishMetricReport"},
+			MetricProperties:         []string{"/redfish/v1/Chassis/System.Chassis.1/Power/PowerSupplies/PSU.Slot.1#Status/Health"},
+			DiscreteTriggerCondition: "Specified",
+			DiscreteTriggers:         []DiscreteTrigger{{Value: "Critical", Severity: "Critical"}},
+			Links:                    TriggerLinks{MetricReportDefinitions: []ODataLink{{ID: "/redfish/v1/TelemetryService/MetricReportDefinitions/Power"}}},
+		}, []string{}},
+		{"missing", Trigger{MetricType: "Numeric"}, []string{
+			"Base.1.0.PropertyMissing", "Base.1.0.PropertyMissing", "Base.1.0.PropertyMissing",
+		}},
+		{"bad values", Trigger{
+			Id: "Bad", MetricType: "Numeric", TriggerActions: []string{"LogToLogService", "RedfishMetricReport"},
+			MetricProperties: []string{"Fans/0/Reading"},
+			NumericThresholds: NumericThresholds{
+				UpperCritical: &Threshold{Reading: reading(100), Activation: "Sideways"},
+				LowerCritical: &Threshold{Reading: reading(0), DwellTime: "30s"},
+				LowerWarning:  &Threshold{},
+			},
+		}, []string{
+			"Base.1.0.PropertyValueFormatError",
+			"Base.1.0.PropertyValueNotInList",
+			"Base.1.0.PropertyMissing",
+			"Base.1.0.PropertyValueNotInList",
+			"Base.1.0.PropertyValueFormatError",
+			"Base.1.0.PropertyMissing",
+		}},
+		{"no condition", Trigger{
+			Id: "Health", MetricType: "Discrete", TriggerActions: []string{"RedfishEvent"},
+			MetricProperties: []string{"/redfish/v1/Chassis/System.Chassis.1#Status/Health"},
+		}, []string{"Base.1.0.PropertyValueNotInList"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testname, func(t *testing.T) {
+			_, errs := newTriggerConfig(tc.trigger)
+			ids := []string{}
+			for _, e := range errs {
+				ids = append(ids, e.MessageId)
+			}
+			assert.Equal(t, tc.errors, ids)
+		})
+	}
+}
+
+func TestEvaluateNumericTrigger(t *testing.T) {
+	tc, errs := newTriggerConfig(Trigger{
+		Id: "Temp", MetricType: "Numeric", TriggerActions: []string{"RedfishEvent"},
+		MetricProperties: []string{"/redfish/v1/Chassis/System.Chassis.1/Sensors/Temperatures/Inlet#ReadingCelsius"},
+		NumericThresholds: NumericThresholds{
+			UpperCritical: &Threshold{Reading: reading(45), Activation: "Increasing", DwellTime: "PT10S"},
+			UpperWarning:  &Threshold{Reading: reading(40)},
+			LowerWarning:  &Threshold{Reading: reading(5), Activation: "Decreasing"},
+		},
+	})
+	assert.Empty(t, errs)
+
+	prop := "/redfish/v1/Chassis/System.Chassis.1/Sensors/Temperatures/Inlet#ReadingCelsius"
+	var steps = []struct {
+		value     interface{}
+		messageId string
+		args      []string
+	}{
+		{30.0, "", nil},
+		{41.0, "Telemetry.1.0.TriggerNumericAboveUpperWarning", []string{prop, "41", "40"}},
+		{42.0, "", nil},
+		{50.0, "Telemetry.1.0.TriggerNumericAboveUpperCritical", []string{prop, "50", "45"}},
+		// UpperCritical only goes off Increasing, but UpperWarning is still there
+		{43.0, "", nil},
+		{35.0, "Telemetry.1.0.TriggerNumericReadingNormal", []string{prop, "35"}},
+		{4.0, "Telemetry.1.0.TriggerNumericBelowLowerWarning", []string{prop, "4", "5"}},
+		// LowerWarning only goes off Decreasing
+		{6.0, "", nil},
+		{"not a number", "", nil},
+	}
+	for _, step := range steps {
+		f := tc.evaluate(prop, step.value)
+		if step.messageId == "" {
+			assert.Nil(t, f, "%v", step.value)
+			continue
+		}
+		if assert.NotNil(t, f, "%v", step.value) {
+			assert.Equal(t, step.messageId, f.messageId)
+			assert.Equal(t, step.args, f.args)
+		}
+	}
+
+	// the dwell time comes from the threshold that was crossed, and the reading has to stay put
+	f := tc.evaluate(prop, 46)
+	if assert.NotNil(t, f) {
+		assert.Equal(t, 10*time.Second, f.dwell)
+		assert.Equal(t, "Critical", f.severity)
+		assert.True(t, f.holds(47.5))
+		assert.False(t, f.holds(44))
+	}
+}
+
+func TestEvaluateDiscreteTrigger(t *testing.T) {
+	prop := "/redfish/v1/Chassis/System.Chassis.1#Status/Health"
+	specified, _ := newTriggerConfig(Trigger{
+		Id: "Health", MetricType: "Discrete", TriggerActions: []string{"RedfishEvent"},
+		MetricProperties:         []string{prop},
+		DiscreteTriggerCondition: "Specified",
+		DiscreteTriggers:         []DiscreteTrigger{{Value: "Critical", Severity: "Critical"}, {Value: "Warning"}},
+	})
+	assert.Nil(t, specified.evaluate(prop, "OK"))
+	f := specified.evaluate(prop, "Warning")
+	if assert.NotNil(t, f) {
+		assert.Equal(t, "Telemetry.1.0.TriggerDiscreteConditionMet", f.messageId)
+		assert.Equal(t, "Warning", f.severity)
+	}
+	assert.Nil(t, specified.evaluate(prop, "Warning"), "no change")
+	f = specified.evaluate(prop, "Critical")
+	if assert.NotNil(t, f) {
+		assert.Equal(t, "Critical", f.severity)
+		assert.Equal(t, []string{prop, "Critical"}, f.args)
+	}
+
+	changed, _ := newTriggerConfig(Trigger{
+		Id: "Health", MetricType: "Discrete", TriggerActions: []string{"RedfishEvent"},
+		MetricProperties:         []string{prop},
+		DiscreteTriggerCondition: "Changed",
+	})
+	assert.Nil(t, changed.evaluate(prop, "OK"), "first value")
+	assert.Nil(t, changed.evaluate(prop, "OK"))
+	assert.NotNil(t, changed.evaluate(prop, "Warning"))
+}
@@ -0,0 +1,344 @@
+package telemetryservice
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	domain "github.com/superchalupa/sailfish/src/redfishresource"
+)
+
+// resources come and go in bursts, so wildcards are expanded again once things settle
+const wildcardSettleTime = time.Second
+
+// wildcardRegexp finds the {Name} wildcards in a MetricProperties entry
+var wildcardRegexp = regexp.MustCompile(`\{([^{}/#]+)\}`)
+
+func hasWildcards(prop string) bool {
+	return wildcardRegexp.MatchString(prop)
+}
+
+// parseWildcards turns the Wildcards of a MetricReportDefinition into the
+// values for each name. Both the "Values" and the newer "Keys" are accepted.
+func parseWildcards(wildcards []map[string]interface{}) (map[string][]string, []domain.ExtendedInfo) {
+	ret := map[string][]string{}
+	errs := []domain.ExtendedInfo{}
+	for i, wc := range wildcards {
+		name, ok := wc["Name"].(string)
+		if !ok || name == "" {
+			errs = append(errs, domain.PropertyMissing("Wildcards/"+strconv.Itoa(i)+"/Name"))
+			continue
+		}
+		values := []string{}
+		for _, key := range []string{"Values", "Keys"} {
+			l, ok := wc[key].([]interface{})
+			if !ok {
+				continue
+			}
+			for _, v := range l {
+				s, ok := v.(string)
+				if !ok {
+					errs = append(errs, domain.PropertyValueTypeError(toString(v), "Wildcards/"+strconv.Itoa(i)+"/"+key))
+					continue
+				}
+				values = append(values, s)
+			}
+		}
+		ret[name] = values
+	}
+	return ret, errs
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(s)
+	}
+	return "null"
+}
+
+// wildcardPattern builds a regexp for s with a group for each wildcard in it,
+// and returns the names of the wildcards in the order of the groups. Values
+// of "*", or no values at all, match anything up to the next "/".
+func wildcardPattern(s string, wildcards map[string][]string) (*regexp.Regexp, []string) {
+	names := []string{}
+	re := "^"
+	last := 0
+	for _, loc := range wildcardRegexp.FindAllStringSubmatchIndex(s, -1) {
+		name := s[loc[2]:loc[3]]
+		re += regexp.QuoteMeta(s[last:loc[0]]) + "(" + valuesPattern(wildcards[name]) + ")"
+		names = append(names, name)
+		last = loc[1]
+	}
+	re += regexp.QuoteMeta(s[last:]) + "$"
+	return regexp.MustCompile(re), names
+}
+
+func valuesPattern(values []string) string {
+	alts := []string{}
+	for _, v := range values {
+		if v == "*" {
+			return "[^/]+"
+		}
+		alts = append(alts, regexp.QuoteMeta(v))
+	}
+	if len(alts) == 0 {
+		return "[^/]+"
+	}
+	return strings.Join(alts, "|")
+}
+
+// wildcardMatch is one MetricProperty that a MetricProperties entry stands
+// for, and the value of each wildcard in it
+type wildcardMatch struct {
+	prop   string
+	values map[string]string
+}
+
+// bind adds the values matched for names to values. It returns false if a
+// name that was already matched got a different value this time.
+func bind(values map[string]string, names []string, matched []string) (map[string]string, bool) {
+	ret := map[string]string{}
+	for k, v := range values {
+		ret[k] = v
+	}
+	for i, name := range names {
+		if v, ok := ret[name]; ok && v != matched[i] {
+			return nil, false
+		}
+		ret[name] = matched[i]
+	}
+	return ret, true
+}
+
+// matchMetricProperty expands the wildcards in prop. The ones in the URI are
+// matched against uris, the ones in the property path against keys(uri, path),
+// which lists what is under path in the resource at uri.
+func matchMetricProperty(prop string, wildcards map[string][]string, uris []string, keys func(uri, path string) []string) []wildcardMatch {
+	if !hasWildcards(prop) {
+		return []wildcardMatch{{prop: prop, values: map[string]string{}}}
+	}
+
+	parts := strings.SplitN(prop, "#", 2)
+	uriRe, names := wildcardPattern(parts[0], wildcards)
+	sorted := append([]string{}, uris...)
+	sort.Strings(sorted)
+
+	ret := []wildcardMatch{}
+	for _, uri := range sorted {
+		m := uriRe.FindStringSubmatch(uri)
+		if m == nil {
+			continue
+		}
+		values, ok := bind(nil, names, m[1:])
+		if !ok {
+			continue
+		}
+		if len(parts) == 1 {
+			ret = append(ret, wildcardMatch{prop: uri, values: values})
+			continue
+		}
+		for _, pm := range matchPath(uri, nil, strings.Split(parts[1], "/"), wildcards, values, keys) {
+			pm.prop = uri + "#" + pm.prop
+			ret = append(ret, pm)
+		}
+	}
+	return ret
+}
+
+// matchPath expands the wildcards in the rest of a property path, one segment
+// at a time. done is the part of the path that has been expanded so far.
+func matchPath(uri string, done []string, segments []string, wildcards map[string][]string, values map[string]string, keys func(uri, path string) []string) []wildcardMatch {
+	if len(segments) == 0 {
+		return []wildcardMatch{{prop: strings.Join(done, "/"), values: values}}
+	}
+
+	seg := segments[0]
+	if !hasWildcards(seg) {
+		return matchPath(uri, append(done, seg), segments[1:], wildcards, values, keys)
+	}
+
+	re, names := wildcardPattern(seg, wildcards)
+	ret := []wildcardMatch{}
+	for _, key := range keys(uri, strings.Trim(strings.Join(done, "/"), "/")) {
+		m := re.FindStringSubmatch(key)
+		if m == nil {
+			continue
+		}
+		bound, ok := bind(values, names, m[1:])
+		if !ok {
+			continue
+		}
+		ret = append(ret, matchPath(uri, append(append([]string{}, done...), key), segments[1:], wildcards, bound, keys)...)
+	}
+	return ret
+}
+
+// expandMetricProperties returns the MetricProperties that props stand for right now
+func (ts *TelemetryService) expandMetricProperties(ctx context.Context, props []string, wildcards map[string][]string) []string {
+	ret := []string{}
+	for _, m := range ts.matchMetricProperties(ctx, props, wildcards) {
+		ret = append(ret, m.prop)
+	}
+	return ret
+}
+
+func (ts *TelemetryService) matchMetricProperties(ctx context.Context, props []string, wildcards map[string][]string) []wildcardMatch {
+	ret := []wildcardMatch{}
+	seen := map[string]bool{}
+	for _, prop := range props {
+		var uris []string
+		if hasWildcards(prop) {
+			uriRe, _ := wildcardPattern(strings.SplitN(prop, "#", 2)[0], wildcards)
+			uris = ts.d.FindMatchingURIs(uriRe.MatchString)
+		}
+		for _, m := range matchMetricProperty(prop, wildcards, uris, func(uri, p string) []string { return ts.keys(ctx, uri, p) }) {
+			if !seen[m.prop] {
+				seen[m.prop] = true
+				ret = append(ret, m)
+			}
+		}
+	}
+	return ret
+}
+
+// keys lists what is under path in the resource at uri: the property names
+// of an object, or the indexes of an array
+func (ts *TelemetryService) keys(ctx context.Context, uri string, path string) []string {
+	value, ok := ts.sample(ctx, uri+"#"+path)
+	if !ok {
+		return nil
+	}
+	ret := []string{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k := range v {
+			ret = append(ret, k)
+		}
+		sort.Strings(ret)
+	case []interface{}:
+		for i := range v {
+			ret = append(ret, strconv.Itoa(i))
+		}
+	}
+	return ret
+}
+
+// resourcesChanged is called when resources are created or removed. Reports
+// with wildcards are expanded again a little later, so they track sleds, fans,
+// and sensors that come and go.
+func (ts *TelemetryService) resourcesChanged(ctx context.Context) {
+	ts.Lock()
+	defer ts.Unlock()
+	if ts.expandPending || !ts.haveWildcards() {
+		return
+	}
+	ts.expandPending = true
+	time.AfterFunc(wildcardSettleTime, func() { ts.reexpandWildcards(ctx) })
+}
+
+// haveWildcards is true if any report, the Metrics of one, or trigger has
+// wildcards that reexpandWildcards would expand. Call it with ts locked.
+func (ts *TelemetryService) haveWildcards() bool {
+	for _, mrdP := range ts.mrdConfigL {
+		if anyWildcards(mrdP.patterns) || mrdP.calcWildcards() {
+			return true
+		}
+	}
+	for _, tc := range ts.triggers {
+		if anyWildcards(tc.patterns) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyWildcards(props []string) bool {
+	for _, prop := range props {
+		if hasWildcards(prop) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ts *TelemetryService) reexpandWildcards(ctx context.Context) {
+	ts.Lock()
+	ts.expandPending = false
+	todo := []*mrdConfig{}
+	for _, mrdP := range ts.mrdConfigL {
+		if anyWildcards(mrdP.patterns) || mrdP.calcWildcards() {
+			todo = append(todo, mrdP)
+		}
+	}
+	triggers := []*triggerConfig{}
+	for _, tc := range ts.triggers {
+		if anyWildcards(tc.patterns) {
+			triggers = append(triggers, tc)
+		}
+	}
+	ts.Unlock()
+
+	for _, mrdP := range todo {
+		ts.RLock()
+		patterns, wildcards := mrdP.patterns, mrdP.config.wildCard
+		ts.RUnlock()
+
+		props := ts.expandMetricProperties(ctx, patterns, wildcards)
+
+		ts.Lock()
+		// skip reports that were deleted while this was going on
+		if _, p := ts.findMRDConfig(mrdP.mrdURI); p == mrdP {
+			ts.trackMetricProperties(mrdP, props)
+		}
+		ts.Unlock()
+		ts.expandMetricCalcs(ctx, mrdP)
+	}
+
+	// the patterns and wildcards of a trigger don't change after it is made
+	for _, tc := range triggers {
+		props := ts.expandMetricProperties(ctx, tc.patterns, tc.wildCard)
+		ts.Lock()
+		tc.metricProperties = props
+		ts.Unlock()
+	}
+}
+
+// wildcardValues lists the values each wildcard in a MetricDefinition has right now
+func (ts *TelemetryService) wildcardValues(ctx context.Context, props []string, wildcards []Wildcard) []interface{} {
+	wc := map[string][]string{}
+	for _, w := range wildcards {
+		wc[w.Name] = w.Values
+	}
+
+	found := map[string]map[string]bool{}
+	for _, m := range ts.matchMetricProperties(ctx, props, wc) {
+		for name, value := range m.values {
+			if found[name] == nil {
+				found[name] = map[string]bool{}
+			}
+			found[name][value] = true
+		}
+	}
+
+	ret := []interface{}{}
+	for _, w := range wildcards {
+		values := []string{}
+		for v := range found[w.Name] {
+			values = append(values, v)
+		}
+		sort.Strings(values)
+		ret = append(ret, &domain.RedfishResourceProperty{Value: map[string]interface{}{
+			"Name":   w.Name,
+			"Values": values,
+		}})
+	}
+	return ret
+}
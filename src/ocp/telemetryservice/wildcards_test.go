@@ -0,0 +1,101 @@
+package telemetryservice
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchMetricProperty(t *testing.T) {
+	uris := []string{
+		"/redfish/v1/Chassis/System.Modular.2/Thermal",
+		"/redfish/v1/Chassis/System.Modular.1/Thermal",
+		"/redfish/v1/Chassis/System.Modular.1",
+		"/redfish/v1/Chassis/System.Chassis.1/Sensors/Fans/Fan.Slot.1",
+		"/redfish/v1/Chassis/System.Chassis.1/Sensors/Fans/Fan.Slot.2",
+	}
+	keys := func(uri, path string) []string {
+		if path == "Fans" && uri == "/redfish/v1/Chassis/System.Modular.1/Thermal" {
+			return []string{"0", "1"}
+		}
+		if path == "Fans" {
+			return []string{"0"}
+		}
+		return nil
+	}
+
+	var tests = []struct {
+		testname  string
+		prop      string
+		wildcards map[string][]string
+		expected  []string
+	}{
+		{"no wildcards", "/redfish/v1/Chassis/System.Modular.3/Thermal#Fans/0/Reading", nil,
+			[]string{"/redfish/v1/Chassis/System.Modular.3/Thermal#Fans/0/Reading"}},
+		{"uri and path", "/redfish/v1/Chassis/{SledId}/Thermal#/Fans/{FanId}/Reading",
+			map[string][]string{"SledId": {"*"}, "FanId": {"*"}},
+			[]string{
+				"/redfish/v1/Chassis/System.Modular.1/Thermal#/Fans/0/Reading",
+				"/redfish/v1/Chassis/System.Modular.1/Thermal#/Fans/1/Reading",
+				"/redfish/v1/Chassis/System.Modular.2/Thermal#/Fans/0/Reading",
+			}},
+		{"values", "/redfish/v1/Chassis/{SledId}/Thermal#Fans/{FanId}/Reading",
+			map[string][]string{"SledId": {"System.Modular.1"}, "FanId": {"1", "2"}},
+			[]string{"/redfish/v1/Chassis/System.Modular.1/Thermal#Fans/1/Reading"}},
+		{"part of a segment", "/redfish/v1/Chassis/System.Chassis.1/Sensors/Fans/Fan.Slot.{N}#Reading",
+			map[string][]string{"N": {"2"}},
+			[]string{"/redfish/v1/Chassis/System.Chassis.1/Sensors/Fans/Fan.Slot.2#Reading"}},
+		{"not defined matches anything", "/redfish/v1/Chassis/System.Chassis.1/Sensors/Fans/{FanId}#Reading", nil,
+			[]string{
+				"/redfish/v1/Chassis/System.Chassis.1/Sensors/Fans/Fan.Slot.1#Reading",
+				"/redfish/v1/Chassis/System.Chassis.1/Sensors/Fans/Fan.Slot.2#Reading",
+			}},
+		{"same name twice", "/redfish/v1/Chassis/System.Chassis.1/Sensors/Fans/Fan.Slot.{N}#Fans/{N}", nil, []string{}},
+		{"nothing there yet", "/redfish/v1/Chassis/{SledId}/Power#PowerConsumedWatts", nil, []string{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testname, func(t *testing.T) {
+			props := []string{}
+			for _, m := range matchMetricProperty(tc.prop, tc.wildcards, uris, keys) {
+				props = append(props, m.prop)
+			}
+			assert.Equal(t, tc.expected, props)
+		})
+	}
+
+	m := matchMetricProperty("/redfish/v1/Chassis/{SledId}/Thermal#Fans/{FanId}/Reading", nil, uris, keys)
+	assert.Equal(t, map[string]string{"SledId": "System.Modular.1", "FanId": "1"}, m[1].values)
+}
+
+func TestParseWildcards(t *testing.T) {
+	wc, errs := parseWildcards([]map[string]interface{}{
+		{"Name": "SledId", "Values": []interface{}{"System.Modular.1", "System.Modular.2"}},
+		{"Name": "FanId", "Keys": []interface{}{"*"}},
+	})
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string][]string{"SledId": {"System.Modular.1", "System.Modular.2"}, "FanId": {"*"}}, wc)
+
+	_, errs = parseWildcards([]map[string]interface{}{
+		{"Values": []interface{}{"1"}},
+		{"Name": "FanId", "Values": []interface{}{1.0}},
+	})
+	if assert.Len(t, errs, 2) {
+		assert.Equal(t, "Base.1.0.PropertyMissing", errs[0].MessageId)
+		assert.Equal(t, "Base.1.0.PropertyValueTypeError", errs[1].MessageId)
+	}
+}
+
+func TestHaveWildcards(t *testing.T) {
+	fixed := "/redfish/v1/Chassis/System.Chassis.1/Power#PowerControl/0/PowerConsumedWatts"
+	wild := "/redfish/v1/Chassis/{SledId}/Power#PowerControl/0/PowerConsumedWatts"
+	ts := &TelemetryService{mrdConfigL: []*mrdConfig{{patterns: []string{fixed}, calcs: []*metricCalc{{patterns: []string{fixed}}}}}}
+	assert.False(t, ts.haveWildcards())
+
+	ts.mrdConfigL[0].calcs = append(ts.mrdConfigL[0].calcs, &metricCalc{patterns: []string{wild}})
+	assert.True(t, ts.haveWildcards(), "the Metrics of a report are expanded again too")
+
+	ts.mrdConfigL = nil
+	ts.triggers = []*triggerConfig{{patterns: []string{wild}}}
+	assert.True(t, ts.haveWildcards())
+}
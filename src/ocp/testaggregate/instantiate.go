@@ -89,12 +89,14 @@ func (s *Service) GetAggregateFunction(name string) aggregateFunc {
 }
 
 type config struct {
-	Logger      []interface{}
-	Models      map[string]map[string]interface{}
-	View        []map[string]interface{}
-	Controllers []map[string]interface{}
-	Aggregate   string
-	ExecPost    []string
+	Logger         []interface{}
+	Models         map[string]map[string]interface{}
+	View           []map[string]interface{}
+	Controllers    []map[string]interface{}
+	Aggregate      string
+	Deletable      bool
+	RequireIfMatch bool
+	ExecPost       []string
 }
 
 // InstantiateFromCfg will set up logger, model, view, controllers, aggregates from the config file
@@ -150,8 +152,12 @@ func (s *Service) InstantiateFromCfg(ctx context.Context, cfgMgr *viper.Viper, c
 	s.RUnlock()
 	subLogger.Debug("Instantiated new logger")
 
-	// Instantiate view
-	vw := view.New(view.WithDeferRegister())
+	// Instantiate view, keeping the uuid if the caller has one
+	viewOptions := []view.Option{view.WithDeferRegister()}
+	if id, ok := parameters["uuid"].(eh.UUID); ok && id != "" {
+		viewOptions = append(viewOptions, view.WithUUID(id))
+	}
+	vw := view.New(viewOptions...)
 	newParams["uuid"] = vw.GetUUID()
 	newParams["view"] = vw
 
@@ -273,6 +279,13 @@ func (s *Service) InstantiateFromCfg(ctx context.Context, cfgMgr *viper.Viper, c
 			// if it's a resource create command, use the view ID for that
 			if c, ok := cmd.(*domain.CreateRedfishResource); ok {
 				c.ID = vw.GetUUID()
+				// the config can make a resource deletable without the aggregate function knowing about it
+				if config.Deletable {
+					c.Deletable = true
+				}
+				if config.RequireIfMatch {
+					c.RequireIfMatch = true
+				}
 			}
 			s.ch.HandleCommand(ctx, cmd)
 		}
@@ -3,6 +3,8 @@ package view
 import (
 	"strconv"
 
+	eh "github.com/looplab/eventhorizon"
+
 	"github.com/superchalupa/sailfish/src/ocp/model"
 	domain "github.com/superchalupa/sailfish/src/redfishresource"
 )
@@ -14,6 +16,14 @@ func WithDeferRegister() Option {
 	}
 }
 
+// WithUUID gives the view a known UUID, for resources that are recreated after a restart
+func WithUUID(id eh.UUID) Option {
+	return func(s *View) error {
+		s.uuid = id
+		return nil
+	}
+}
+
 func WithURI(name string) Option {
 	return func(s *View) error {
 		s.pluginType = domain.PluginType(name)
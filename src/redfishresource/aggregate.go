@@ -36,10 +36,18 @@ type RedfishResourceAggregate struct {
 	ID          eh.UUID
 	ResourceURI string
 	Plugin      string
+	Type        string // @odata.type, kept here so we can find the schemas in use without running meta
+	Context     string
 
 	Properties    RedfishResourceProperty
 	StatusCode    int // http status code for the current state of this object since the last time we've run the meta functions
 	DefaultFilter string
+	Deletable     bool // DELETE is only allowed if set, the privileges just say who can do it
+
+	// ETag is the version of the properties, bumped every time they are
+	// updated. RequireIfMatch makes PATCH/PUT/DELETE send If-Match.
+	ETag           int
+	RequireIfMatch bool
 
 	// TODO: need accessor functions for all of these just like property stuff
 	// above so that everything can be properly locked
@@ -187,6 +195,7 @@ func (h *CommandHandler) HandleCommand(ctx context.Context, cmd eh.Command) erro
 	if cmdType == CreateRedfishResourceCommand ||
 		cmdType == UpdateRedfishResourcePropertiesCommand ||
 		cmdType == UpdateRedfishResourcePropertiesCommand2 ||
+		cmdType == PATCHCommand || cmdType == PUTCommand ||
 		cmdType == UpdateMetricRedfishResourcePropertiesCommand ||
 		cmdType == RemoveRedfishResourcePropertyCommand {
 
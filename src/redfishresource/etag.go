@@ -0,0 +1,149 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+// formatETag makes the ETag for a resource from the version on its
+// aggregate. Resources that have their own @odata.etag (usually from a model
+// that tracks backend changes) keep it, qualified by the version.
+func formatETag(own string, version int) string {
+	own = strings.Trim(strings.TrimPrefix(own, "W/"), `"`)
+	if own == "" {
+		return fmt.Sprintf(`W/"%d"`, version)
+	}
+	return fmt.Sprintf(`W/"%s-%d"`, own, version)
+}
+
+// setETag adds the ETag header to a response, and makes @odata.etag in the body agree with it
+func setETag(a *RedfishResourceAggregate, data *HTTPCmdProcessedData) {
+	res, _ := data.Results.(map[string]interface{})
+	own, hasOwn := res["@odata.etag"]
+	ownStr, _ := own.(string)
+
+	etag := formatETag(ownStr, a.ETag)
+	data.Headers["ETag"] = etag
+	if hasOwn {
+		res["@odata.etag"] = etag
+	}
+}
+
+// etagMatches does the weak comparison of an If-Match or If-None-Match list against the ETag
+func etagMatches(header string, etag string) bool {
+	want := strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionalRequest wraps an HTTP command that has If-Match or If-None-Match
+// to check, so that the check runs in the command handler against the same
+// state the command sees.
+type conditionalRequest struct {
+	eh.Command `eh:"optional"`
+
+	method      string
+	ifMatch     string
+	ifNoneMatch string
+	cmdID       eh.UUID
+	auth        *RedfishAuthorizationProperty
+}
+
+// newConditionalRequest wraps cmd if the request has conditions to check, or
+// the resource could require them
+func newConditionalRequest(cmd eh.Command, r *http.Request, cmdID eh.UUID, auth *RedfishAuthorizationProperty) eh.Command {
+	c := &conditionalRequest{
+		Command:     cmd,
+		method:      r.Method,
+		ifMatch:     r.Header.Get("If-Match"),
+		ifNoneMatch: r.Header.Get("If-None-Match"),
+		cmdID:       cmdID,
+		auth:        auth,
+	}
+	switch r.Method {
+	case "GET", "HEAD":
+		if c.ifNoneMatch == "" {
+			return cmd
+		}
+	case "PATCH", "PUT", "DELETE":
+	default:
+		return cmd
+	}
+	return c
+}
+
+func (c *conditionalRequest) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
+	if err := eh.CheckCommand(c.Command); err != nil {
+		return err
+	}
+	if data := c.checkPreconditions(ctx, a); data != nil {
+		a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, data, time.Now()))
+		return nil
+	}
+	if h, ok := c.Command.(RRCmdHandler); ok {
+		return h.Handle(ctx, a)
+	}
+	return nil
+}
+
+// checkPreconditions handles If-Match and If-None-Match, and returns the
+// response if the request shouldn't go ahead.
+func (c *conditionalRequest) checkPreconditions(ctx context.Context, a *RedfishResourceAggregate) *HTTPCmdProcessedData {
+	requestLogger := ContextLogger(ctx, "etag")
+	fail := func(status int, etag string, msg *ExtendedInfo) *HTTPCmdProcessedData {
+		data := &HTTPCmdProcessedData{CommandID: c.cmdID, StatusCode: status, Headers: map[string]string{}}
+		if etag != "" {
+			data.Headers["ETag"] = etag
+		}
+		if msg != nil {
+			data.Results = ErrorResponse(*msg)
+		}
+		return data
+	}
+
+	switch c.method {
+	case "GET", "HEAD":
+		etag := c.currentETag(ctx, a)
+		if etagMatches(c.ifNoneMatch, etag) {
+			return fail(http.StatusNotModified, etag, nil)
+		}
+
+	case "PATCH", "PUT", "DELETE":
+		if c.ifMatch == "" {
+			if a.RequireIfMatch {
+				requestLogger.Info("If-Match required", "url", a.ResourceURI, "method", c.method)
+				msg := PreconditionRequired()
+				return fail(http.StatusPreconditionRequired, "", &msg)
+			}
+			return nil
+		}
+
+		etag := c.currentETag(ctx, a)
+		if !etagMatches(c.ifMatch, etag) {
+			requestLogger.Info("If-Match does not match", "url", a.ResourceURI, "method", c.method, "If-Match", c.ifMatch, "ETag", etag)
+			msg := PreconditionFailed()
+			return fail(http.StatusPreconditionFailed, etag, &msg)
+		}
+	}
+	return nil
+}
+
+// currentETag is the ETag a GET of the resource would return now. Only the
+// @odata.etag property is run, not the whole resource.
+func (c *conditionalRequest) currentETag(ctx context.Context, a *RedfishResourceAggregate) string {
+	auth := &RedfishAuthorizationProperty{}
+	if c.auth != nil {
+		auth = &RedfishAuthorizationProperty{UserName: c.auth.UserName, Privileges: c.auth.Privileges, Licenses: c.auth.Licenses}
+	}
+	return formatETag(getResourceEtag(ctx, a, auth), a.ETag)
+}
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	eh "github.com/looplab/eventhorizon"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/superchalupa/sailfish/src/log15adapter"
+)
+
+func init() {
+	log15adapter.InitializeApplicationLogging("")
+}
+
+func TestETag(t *testing.T) {
+	assert.Equal(t, `W/"3"`, formatETag("", 3))
+	assert.Equal(t, `W/"genid-2-3"`, formatETag(`W/"genid-2"`, 3))
+
+	a := &RedfishResourceAggregate{ID: "1", ResourceURI: "/redfish/v1/foo", ETag: 1}
+	a.Properties.Parse(map[string]interface{}{"Name": "foo"})
+	assert.Nil(t, (&UpdateRedfishResourceProperties2{ID: "1", Properties: map[string]interface{}{"Name": "bar"}}).Handle(context.Background(), a))
+	assert.Equal(t, 2, a.ETag, "updates bump the version")
+	assert.Nil(t, (&UpdateRedfishResourceProperties2{ID: "1", Properties: map[string]interface{}{"Bogus/Name": "bar"}}).Handle(context.Background(), a))
+	assert.Equal(t, 2, a.ETag, "nothing changed")
+
+	var tests = []struct {
+		testname string
+		header   string
+		etag     string
+		expected bool
+	}{
+		{"exact", `W/"3"`, `W/"3"`, true},
+		{"weak comparison", `"3"`, `W/"3"`, true},
+		{"stale", `W/"2"`, `W/"3"`, false},
+		{"list", `W/"1", W/"3"`, `W/"3"`, true},
+		{"list stale", `W/"1",W/"2"`, `W/"3"`, false},
+		{"star", `*`, `W/"3"`, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testname, func(t *testing.T) {
+			assert.Equal(t, tc.expected, etagMatches(tc.header, tc.etag))
+		})
+	}
+}
+
+func TestConditionalRequest(t *testing.T) {
+	newAgg := func(version int) *RedfishResourceAggregate {
+		a := &RedfishResourceAggregate{ID: "1", ResourceURI: "/redfish/v1/foo", RequireIfMatch: true, ETag: version}
+		a.Properties.Parse(map[string]interface{}{"Name": "foo"})
+		return a
+	}
+	etag := getResponse(context.Background(), newAgg(1), "", &RedfishAuthorizationProperty{}).Headers["ETag"]
+	assert.Equal(t, `W/"1"`, etag)
+
+	var tests = []struct {
+		testname string
+		method   string
+		header   string
+		value    string
+		version  int
+		expected int
+	}{
+		{"not modified", "GET", "If-None-Match", etag, 1, http.StatusNotModified},
+		{"modified", "GET", "If-None-Match", etag, 2, 0},
+		{"matches", "DELETE", "If-Match", etag, 1, 0},
+		{"stale", "DELETE", "If-Match", etag, 2, http.StatusPreconditionFailed},
+		{"required", "DELETE", "", "", 1, http.StatusPreconditionRequired},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testname, func(t *testing.T) {
+			r, _ := http.NewRequest(tc.method, "/redfish/v1/foo", nil)
+			if tc.header != "" {
+				r.Header.Set(tc.header, tc.value)
+			}
+			c, ok := newConditionalRequest(&OPTIONS{ID: "1", CmdID: "2"}, r, "2", nil).(*conditionalRequest)
+			if !assert.True(t, ok) {
+				return
+			}
+
+			data := c.checkPreconditions(context.Background(), newAgg(tc.version))
+			if tc.expected == 0 {
+				assert.Nil(t, data)
+				return
+			}
+			if assert.NotNil(t, data) {
+				assert.Equal(t, tc.expected, data.StatusCode)
+			}
+		})
+	}
+
+	// nothing to check, nothing to wrap
+	r, _ := http.NewRequest("GET", "/redfish/v1/foo", nil)
+	cmd := &OPTIONS{ID: "1", CmdID: "2"}
+	assert.Equal(t, eh.Command(cmd), newConditionalRequest(cmd, r, "2", nil))
+}
@@ -48,6 +48,7 @@ func init() {
 type RedfishResourceCreatedData struct {
 	ID          eh.UUID `json:"id"     bson:"id"`
 	ResourceURI string
+	Type        string
 }
 
 // RedfishResourceRemovedData is the event data for the RedfishResourceRemoved event.
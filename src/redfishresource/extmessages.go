@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
 )
 
 //
@@ -32,7 +34,9 @@ func (e *ExtendedInfo) GetDefaultExtendedInfo() map[string]interface{} {
 	return e.GetExtendedInfo()
 }
 
+// GetExtendedInfo returns the message for a response. Whatever text is missing is filled in from the message registries.
 func (e *ExtendedInfo) GetExtendedInfo() map[string]interface{} {
+	FillMessage(e.MessageId, e.MessageArgs, &e.Message, &e.Severity, &e.Resolution)
 	info := map[string]interface{}{
 		"Message":                       e.Message,
 		"MessageArgs":                   e.MessageArgs,
@@ -46,6 +50,239 @@ func (e *ExtendedInfo) GetExtendedInfo() map[string]interface{} {
 	return info
 }
 
+// newBaseMessage fills in the boilerplate for a Base registry message
+func newBaseMessage(id string, severity string, message string, resolution string, args ...string) ExtendedInfo {
+	if args == nil {
+		args = []string{}
+	}
+	return ExtendedInfo{
+		Message:             message,
+		MessageArgs:         args,
+		MessageArgsCt:       len(args),
+		MessageId:           "Base.1.0." + id,
+		RelatedProperties:   []string{},
+		RelatedPropertiesCt: 0,
+		Resolution:          resolution,
+		Severity:            severity,
+	}
+}
+
+// QueryParameterValueFormatError is returned when a query option (like $filter) can't be parsed
+func QueryParameterValueFormatError(value string, parameter string) ExtendedInfo {
+	return newBaseMessage("QueryParameterValueFormatError", "Warning",
+		fmt.Sprintf("The value %s for the parameter %s is of a different format than the parameter can accept.", value, parameter),
+		"Correct the value for the query parameter in the request and resubmit the request if the operation failed.",
+		value, parameter)
+}
+
+// ResourceMissingAtURI is returned when there is nothing at the requested URI
+func ResourceMissingAtURI(uri string) ExtendedInfo {
+	return newBaseMessage("ResourceMissingAtURI", "Critical",
+		fmt.Sprintf("The resource at the URI %s was not found.", uri),
+		"Place a valid resource at the URI or correct the URI and resubmit the request.",
+		uri)
+}
+
+// ActionNotSupported is returned when the resource doesn't support the requested method or action
+func ActionNotSupported(action string) ExtendedInfo {
+	return newBaseMessage("ActionNotSupported", "Critical",
+		fmt.Sprintf("The action %s is not supported by the resource.", action),
+		"The action supplied cannot be resubmitted to the implementation.  Perhaps the action was invalid, the wrong resource was the target or the implementation documentation may be of assistance.",
+		action)
+}
+
+// InsufficientPrivilege is returned when the user doesn't have the privileges the resource requires
+func InsufficientPrivilege() ExtendedInfo {
+	return newBaseMessage("InsufficientPrivilege", "Critical",
+		"There are insufficient privileges for the account or credentials associated with the current session to perform the requested operation.",
+		"Either abandon the operation or change the associated access rights and resubmit the request if the operation failed.")
+}
+
+// MalformedJSON is returned when the request body can't be decoded
+func MalformedJSON() ExtendedInfo {
+	return newBaseMessage("MalformedJSON", "Critical",
+		"The request body submitted was malformed JSON and could not be parsed by the receiving service.",
+		"Ensure that the request body is valid JSON and resubmit the request.")
+}
+
+// UnrecognizedRequestBody is returned when the request body decodes but can't be interpreted
+func UnrecognizedRequestBody() ExtendedInfo {
+	return newBaseMessage("UnrecognizedRequestBody", "Warning",
+		"The service detected a malformed request body that it was unable to interpret.",
+		"Correct the request body and resubmit the request if it failed.")
+}
+
+// InternalError is returned when the service fails in a way that isn't the client's fault
+func InternalError() ExtendedInfo {
+	return newBaseMessage("InternalError", "Critical",
+		"The request failed due to an internal service error.  The service is still operational.",
+		"Resubmit the request.  If the problem persists, consider resetting the service.")
+}
+
+// GeneralError is returned when nothing more specific applies
+func GeneralError() ExtendedInfo {
+	return newBaseMessage("GeneralError", "Critical",
+		"A general error has occurred. See ExtendedInfo for more information.",
+		"See ExtendedInfo for more information.")
+}
+
+// ResourceInUse is returned when a change is refused because something is still using the resource
+func ResourceInUse() ExtendedInfo {
+	return newBaseMessage("ResourceInUse", "Warning",
+		"The change to the requested resource failed because the resource is in use or in transition.",
+		"Remove the condition and resubmit the request if the operation failed.")
+}
+
+// PreconditionFailed is returned when the If-Match header doesn't match the current ETag
+func PreconditionFailed() ExtendedInfo {
+	e := newBaseMessage("PreconditionFailed", "Critical",
+		"The ETag supplied did not match the ETag required to change this resource.",
+		"Try the operation again using the appropriate ETag.")
+	// added in the 1.4 registry
+	e.MessageId = "Base.1.4.PreconditionFailed"
+	return e
+}
+
+// PreconditionRequired is returned when a resource that requires If-Match is changed without it
+func PreconditionRequired() ExtendedInfo {
+	e := newBaseMessage("PreconditionRequired", "Critical",
+		"A precondition header or annotation is required to change this resource.",
+		"Try the operation again using an If-Match or If-None-Match header and appropriate ETag.")
+	// added in the 1.4 registry
+	e.MessageId = "Base.1.4.PreconditionRequired"
+	return e
+}
+
+// PropertyUnknown is returned when a request body has a property that isn't in the schema
+func PropertyUnknown(property string) ExtendedInfo {
+	e := newBaseMessage("PropertyUnknown", "Warning",
+		fmt.Sprintf("The property %s is not in the list of valid properties for the resource.", property),
+		"Remove the unknown property from the request body and resubmit the request if the operation failed.",
+		property)
+	return e.relatedTo(property)
+}
+
+// PropertyMissing is returned when a request body leaves out a property that it has to set
+func PropertyMissing(property string) ExtendedInfo {
+	e := newBaseMessage("PropertyMissing", "Warning",
+		fmt.Sprintf("The property %s is a required property and must be included in the request.", property),
+		"Ensure that the property is in the request body and has a valid value and resubmit the request if the operation failed.",
+		property)
+	return e.relatedTo(property)
+}
+
+// PropertyNotWritable is returned when a request body tries to set a read only property
+func PropertyNotWritable(property string) ExtendedInfo {
+	e := newBaseMessage("PropertyNotWritable", "Warning",
+		fmt.Sprintf("The property %s is a read only property and cannot be assigned a value.", property),
+		"Remove the property from the request body and resubmit the request if the operation failed.",
+		property)
+	return e.relatedTo(property)
+}
+
+// PropertyValueTypeError is returned when a request body has a value of the wrong type for the property
+func PropertyValueTypeError(value string, property string) ExtendedInfo {
+	e := newBaseMessage("PropertyValueTypeError", "Warning",
+		fmt.Sprintf("The value %s for the property %s is of a different type than the property can accept.", value, property),
+		"Correct the value for the property in the request body and resubmit the request if the operation failed.",
+		value, property)
+	return e.relatedTo(property)
+}
+
+// PropertyValueNotInList is returned when a request body has a value that isn't one of the allowed enum values
+func PropertyValueNotInList(value string, property string) ExtendedInfo {
+	e := newBaseMessage("PropertyValueNotInList", "Warning",
+		fmt.Sprintf("The value %s for the property %s is not in the list of acceptable values.", value, property),
+		"Choose a value from the enumeration list that the implementation can support and resubmit the request if the operation failed.",
+		value, property)
+	return e.relatedTo(property)
+}
+
+// PropertyValueFormatError is returned when a request body has a value of the right type but in the wrong format
+func PropertyValueFormatError(value string, property string) ExtendedInfo {
+	e := newBaseMessage("PropertyValueFormatError", "Warning",
+		fmt.Sprintf("The value %s for the property %s is of a different format than the property can accept.", value, property),
+		"Correct the value for the property in the request body and resubmit the request if the operation failed.",
+		value, property)
+	return e.relatedTo(property)
+}
+
+// ResourceAlreadyExists is returned when a create would make a copy of a resource that is already there
+func ResourceAlreadyExists(resourceType string, property string, value string) ExtendedInfo {
+	e := newBaseMessage("ResourceAlreadyExists", "Critical",
+		fmt.Sprintf("The requested resource of type %s with the property %s with the value %s already exists.", resourceType, property, value),
+		"Do not repeat the create operation as the resource has already been created.",
+		resourceType, property, value)
+	return e.relatedTo(property)
+}
+
+// CreateLimitReachedForResource is returned when a collection already has as many members as it can
+func CreateLimitReachedForResource() ExtendedInfo {
+	return newBaseMessage("CreateLimitReachedForResource", "Critical",
+		"The create operation failed because the resource has reached the limit of possible resources.",
+		"Either delete resources and resubmit the request if the operation failed or do not resubmit the request.")
+}
+
+// ActionParameterUnknown is returned when an action is given a parameter it doesn't have
+func ActionParameterUnknown(action string, parameter string) ExtendedInfo {
+	e := newBaseMessage("ActionParameterUnknown", "Warning",
+		fmt.Sprintf("The action %s was submitted with the invalid parameter %s.", action, parameter),
+		"Correct the invalid parameter and resubmit the request if the operation failed.",
+		action, parameter)
+	return e.relatedTo(parameter)
+}
+
+// ActionParameterValueTypeError is returned when an action parameter has a value of the wrong type
+func ActionParameterValueTypeError(value string, parameter string, action string) ExtendedInfo {
+	e := newBaseMessage("ActionParameterValueTypeError", "Warning",
+		fmt.Sprintf("The value %s for the parameter %s in the action %s is of a different type than the parameter can accept.", value, parameter, action),
+		"Correct the value for the parameter in the request body and resubmit the request if the operation failed.",
+		value, parameter, action)
+	return e.relatedTo(parameter)
+}
+
+// ActionParameterValueFormatError is returned when an action parameter has a value of the right type but in the wrong format
+func ActionParameterValueFormatError(value string, parameter string, action string) ExtendedInfo {
+	e := newBaseMessage("ActionParameterValueFormatError", "Warning",
+		fmt.Sprintf("The value %s for the parameter %s in the action %s is of a different format than the parameter can accept.", value, parameter, action),
+		"Correct the value for the parameter in the request body and resubmit the request if the operation failed.",
+		value, parameter, action)
+	return e.relatedTo(parameter)
+}
+
+// relatedTo points RelatedProperties at a property path like "Status/Health"
+func (e ExtendedInfo) relatedTo(property string) ExtendedInfo {
+	e.RelatedProperties = []string{"#/" + property}
+	e.RelatedPropertiesCt = len(e.RelatedProperties)
+	return e
+}
+
+// ErrorResponse builds a redfish error body: {"error": {"code", "message", "@Message.ExtendedInfo"}}
+func ErrorResponse(msgs ...ExtendedInfo) map[string]interface{} {
+	response := map[string]interface{}{}
+	for _, m := range msgs {
+		addToEEMIList(response, m, false)
+	}
+	return response
+}
+
+// WriteErrorResponse sends a redfish error body with the given status for http handlers that fail before they have any results
+func WriteErrorResponse(w http.ResponseWriter, statusCode int, msgs ...ExtendedInfo) {
+	b, err := json.Marshal(ErrorResponse(msgs...))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("OData-Version", "4.0")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-Store,no-Cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.WriteHeader(statusCode)
+	w.Write(b)
+}
+
 type HTTP_code struct {
 	Err_message []string
 	Any_success int
@@ -64,27 +301,11 @@ func (e HTTP_code) Error() string {
 }
 
 func AddEEMIMessage(response map[string]interface{}, a *RedfishResourceAggregate, errorType string, errs *HTTP_code) error {
-	bad_json := ExtendedInfo{
-		Message:             "The request body submitted was malformed JSON and could not be parsed by the receiving service.",
-		MessageArgs:         []string{}, //FIX ME
-		MessageArgsCt:       0,          //FIX ME
-		MessageId:           "Base.1.0.MalformedJSON",
-		RelatedProperties:   []string{}, //FIX ME
-		RelatedPropertiesCt: 0,          //FIX ME
-		Resolution:          "Ensure that the request body is valid JSON and resubmit the request.",
-		Severity:            "Critical",
-	}
+	bad_json := MalformedJSON()
 
-	bad_request := ExtendedInfo{
-		Message:             "The service detected a malformed request body that it was unable to interpret.",
-		MessageArgs:         []string{},
-		MessageArgsCt:       0,
-		MessageId:           "Base.1.0.UnrecognizedRequestBody",
-		RelatedProperties:   []string{"Attributes"}, //FIX ME
-		RelatedPropertiesCt: 1,                      //FIX ME
-		Resolution:          "Correct the request body and resubmit the request if it failed.",
-		Severity:            "Warning",
-	}
+	bad_request := UnrecognizedRequestBody()
+	bad_request.RelatedProperties = []string{"Attributes"} //FIX ME
+	bad_request.RelatedPropertiesCt = 1                    //FIX ME
 
 	if errorType == "SUCCESS" {
 		a.StatusCode = 200
@@ -123,38 +344,35 @@ func AddEEMIMessage(response map[string]interface{}, a *RedfishResourceAggregate
 	return nil
 }
 
+// addToEEMIList appends to @Message.ExtendedInfo. Errors go under "error", where code and message
+// mirror the message when there is only one, and fall back to GeneralError when there are several.
 func addToEEMIList(response map[string]interface{}, eemi ExtendedInfo, isSuccess bool) {
-	extendedInfoL := &[]map[string]interface{}{}
-	var ok bool
-
 	if isSuccess {
+		extendedInfoL := &[]map[string]interface{}{}
 		response["@Message.ExtendedInfo"] = extendedInfoL
 		*extendedInfoL = append(*extendedInfoL, eemi.GetDefaultExtendedInfo())
 		return
 	}
 
 	// not success message
-	response["code"] = "Base.1.0.GeneralError"
-	response["message"] = "A general error has occurred.  See ExtendedInfo for more information"
-
-	t, ok := response["error"]
+	errObj, ok := response["error"].(map[string]interface{})
 	if !ok {
-		response["error"] = map[string]*[]map[string]interface{}{
-			"@Message.ExtendedInfo": extendedInfoL}
-		*extendedInfoL = append(*extendedInfoL, eemi.GetExtendedInfo())
-	} else {
-
-		if !ok {
-			return
-		}
-		t2, ok := t.(map[string]*[]map[string]interface{})
-
-		if !ok {
-			return
-		}
-		t3 := t2["@Message.ExtendedInfo"]
-		*t3 = append(*t3, eemi.GetExtendedInfo())
+		errObj = map[string]interface{}{"@Message.ExtendedInfo": &[]map[string]interface{}{}}
+		response["error"] = errObj
+	}
 
+	extendedInfoL, ok := errObj["@Message.ExtendedInfo"].(*[]map[string]interface{})
+	if !ok {
+		return
 	}
+	*extendedInfoL = append(*extendedInfoL, eemi.GetExtendedInfo())
 
+	if len(*extendedInfoL) == 1 {
+		errObj["code"] = eemi.MessageId
+		errObj["message"] = eemi.Message
+	} else {
+		general := GeneralError()
+		errObj["code"] = general.MessageId
+		errObj["message"] = general.Message
+	}
 }
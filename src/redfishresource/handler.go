@@ -49,6 +49,22 @@ type DomainObjects struct {
 
 	licensesMu sync.RWMutex
 	licenses   []string
+
+	// generated $metadata and odata service documents
+	metadata metadataCache
+
+	// optional, checks PATCH/PUT/POST bodies against the schema before dispatch
+	Validator RequestValidator
+
+	// collections with more members than this are split into pages, 0 to never split
+	PageSize int
+}
+
+// RequestValidator checks a request body against the schema for the resource @odata.type.
+// patchable reports if the resource has a PATCH plugin for a property path, which
+// makes it writable even if the schema says it is read only.
+type RequestValidator interface {
+	ValidateRequest(method string, odataType string, body map[string]interface{}, patchable func(path string) bool) []ExtendedInfo
 }
 
 // define the starting capacity
@@ -61,6 +77,7 @@ func NewDomainObjects() (*DomainObjects, error) {
 	d := DomainObjects{}
 
 	d.Tree = make(map[string]eh.UUID, INITIAL_CAPACITY)
+	d.PageSize = DefaultPageSize
 
 	// Create the repository and wrap in a version repository.
 	d.Repo = repo.NewRepo()
@@ -93,12 +110,24 @@ func NewDomainObjects() (*DomainObjects, error) {
 		}
 	})
 
+	eh.RegisterCommand(func() eh.Command {
+		return &HEAD{
+			HTTPEventBus: d.HTTPResultsBus,
+		}
+	})
+
 	eh.RegisterCommand(func() eh.Command {
 		return &PATCH{
 			HTTPEventBus: d.HTTPResultsBus,
 		}
 	})
 
+	eh.RegisterCommand(func() eh.Command {
+		return &PUT{
+			HTTPEventBus: d.HTTPResultsBus,
+		}
+	})
+
 	// set up our built-in observer
 	d.EventPublisher.AddObserver(&d)
 
@@ -258,6 +287,21 @@ func (d *DomainObjects) ExpandURI(ctx context.Context, uri string) (*RedfishReso
 	return &redfishResource.Properties, nil
 }
 
+// GetResourceType returns the schema name, like "Chassis", of the resource at uri, or "" if there isn't one
+func (d *DomainObjects) GetResourceType(ctx context.Context, uri string) string {
+	aggID, ok := d.GetAggregateIDOK(uri)
+	if !ok {
+		return ""
+	}
+	agg, _ := d.AggregateStore.Load(ctx, AggregateType, aggID)
+	redfishResource, ok := agg.(*RedfishResourceAggregate)
+	if !ok {
+		return ""
+	}
+	namespace, _ := splitODataType(redfishResource.Type)
+	return namespace
+}
+
 // Notify implements the Notify method of the EventObserver interface.
 func (d *DomainObjects) Notify(ctx context.Context, event eh.Event) {
 	logger := ContextLogger(ctx, "domain")
@@ -275,10 +319,12 @@ func (d *DomainObjects) Notify(ctx context.Context, event eh.Event) {
 				// TODO: need to actually run the removeredfishresource command here instead of directly removing resource
 				// TODO: Probably put this command into the inject queue?
 				d.Repo.Remove(ctx, UUID)
+				d.metadata.removeResource(UUID, data.ResourceURI)
 			}
 
 			// Next, attach this aggregate into the tree (possibly overwriting old def)
 			d.Tree[data.ResourceURI] = data.ID
+			d.metadata.addResource(data.ID, data.ResourceURI, data.Type)
 
 		}
 	} else if event.EventType() == RedfishResourceRemoved {
@@ -295,6 +341,7 @@ func (d *DomainObjects) Notify(ctx context.Context, event eh.Event) {
 			// if it's *this* specific aggregate still in the tree, remove it from the tree
 			if ok && UUID == data.ID {
 				delete(d.Tree, data.ResourceURI)
+				d.metadata.removeResource(data.ID, data.ResourceURI)
 
 				// remove any plugins linked to the now unlinked agg. Careful here
 				// because if a new aggregate is linked in we dont want to delete the
@@ -322,21 +369,26 @@ func (d *DomainObjects) GetInternalCommandHandler(backgroundCtx context.Context)
 		vars["command"] = "Event:Inject"
 		mux.SetURLVars(r, vars)
 
+		logger := ContextLogger(r.Context(), "internal_commands")
+
 		if r.Method != "POST" {
-			http.Error(w, "unsuported method: "+r.Method, http.StatusMethodNotAllowed)
+			w.Header().Set("Allow", "POST")
+			WriteErrorResponse(w, http.StatusMethodNotAllowed, ActionNotSupported(r.Method))
 			return
 		}
 
 		cmd, err := eh.CreateCommand(eh.CommandType("internal:" + vars["command"]))
 		if err != nil {
-			http.Error(w, "could not create command: "+err.Error(), http.StatusBadRequest)
+			logger.Warn("could not create command", "command", vars["command"], "err", err)
+			WriteErrorResponse(w, http.StatusBadRequest, ActionNotSupported(vars["command"]))
 			return
 		}
 
 		b, err := ioutil.ReadAll(r.Body)
 		r.Body.Close()
 		if err != nil {
-			http.Error(w, "could not read command: "+err.Error(), http.StatusBadRequest)
+			logger.Warn("could not read command", "err", err)
+			WriteErrorResponse(w, http.StatusBadRequest, UnrecognizedRequestBody())
 			return
 		}
 		//fmt.Printf("Sailfish-pump POST payload is %s\n", b)
@@ -345,12 +397,14 @@ func (d *DomainObjects) GetInternalCommandHandler(backgroundCtx context.Context)
 		contentType := r.Header.Get("Content-type")
 		if contentType == "application/xml" {
 			if err := xml.Unmarshal(b, &cmd); err != nil {
-				http.Error(w, "could not decode command: "+err.Error(), http.StatusBadRequest)
+				logger.Warn("could not decode command", "err", err)
+				WriteErrorResponse(w, http.StatusBadRequest, UnrecognizedRequestBody())
 				return
 			}
 		} else {
 			if err := json.Unmarshal(b, &cmd); err != nil {
-				http.Error(w, "could not decode command: "+err.Error(), http.StatusBadRequest)
+				logger.Warn("could not decode command", "err", err)
+				WriteErrorResponse(w, http.StatusBadRequest, MalformedJSON())
 				return
 			}
 		}
@@ -359,7 +413,8 @@ func (d *DomainObjects) GetInternalCommandHandler(backgroundCtx context.Context)
 		// the HTTP request which will cause projectors etc to fail if they run
 		// async in goroutines past the request.
 		if err := d.CommandHandler.HandleCommand(backgroundCtx, cmd); err != nil {
-			http.Error(w, "could not handle command: "+err.Error(), http.StatusBadRequest)
+			logger.Warn("could not handle command", "err", err)
+			WriteErrorResponse(w, http.StatusBadRequest, GeneralError())
 			return
 		}
 
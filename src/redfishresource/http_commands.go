@@ -13,20 +13,18 @@ func init() {
 	// implemented
 	eh.RegisterCommand(func() eh.Command { return &DELETE{} })
 
-	// GET/PATCH registered in handler.go as they pub on http event bus
+	eh.RegisterCommand(func() eh.Command { return &OPTIONS{} })
+
+	// GET/HEAD/PATCH/PUT registered in handler.go as they pub on http event bus
 
 	// TODO: not yet implemented
-	eh.RegisterCommand(func() eh.Command { return &PUT{} })
 	eh.RegisterCommand(func() eh.Command { return &POST{} })
-	eh.RegisterCommand(func() eh.Command { return &HEAD{} })
-	eh.RegisterCommand(func() eh.Command { return &OPTIONS{} })
 }
 
 const (
 	// All of these shortened from "http:RedfishResource:HTTP" to "R:HTTP" to save memory in the aggregate since this is the most common type
 	DELETECommand = eh.CommandType("R:DELETE")
 
-	PUTCommand     = eh.CommandType("R:PUT")
 	PATCHCommand   = eh.CommandType("R:PATCH")
 	POSTCommand    = eh.CommandType("R:POST")
 	HEADCommand    = eh.CommandType("R:HEAD")
@@ -36,16 +34,18 @@ const (
 // Static type checking for commands to prevent runtime errors due to typos
 var _ = eh.Command(&DELETE{})
 
-var _ = eh.Command(&PUT{})
 var _ = eh.Command(&PATCH{})
 var _ = eh.Command(&POST{})
 var _ = eh.Command(&HEAD{})
 var _ = eh.Command(&OPTIONS{})
 
 // HTTP DELETE Command
+// Only reaches here for resources that are Deletable, the http handler sends
+// back a 405 for everything else.
 type DELETE struct {
 	ID    eh.UUID `json:"id"`
 	CmdID eh.UUID `json:"cmdid"`
+	auth  *RedfishAuthorizationProperty
 }
 
 func (c *DELETE) AggregateType() eh.AggregateType { return AggregateType }
@@ -53,12 +53,24 @@ func (c *DELETE) AggregateID() eh.UUID            { return c.ID }
 func (c *DELETE) CommandType() eh.CommandType     { return DELETECommand }
 func (c *DELETE) SetAggID(id eh.UUID)             { c.ID = id }
 func (c *DELETE) SetCmdID(id eh.UUID)             { c.CmdID = id }
+func (c *DELETE) SetUserDetails(a *RedfishAuthorizationProperty) string {
+	c.auth = a
+	return "checkMaster"
+}
 func (c *DELETE) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
-	// TODO: "Services may return a representation of the just deleted resource in the response body."
-	// - can create a new CMD for GET with an identical CMD ID. Is that cheating?
-	// TODO: return http 405 status for undeletable objects. right now we use privileges
+	if msg := VetoDelete(ctx, a); msg != nil {
+		a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, &HTTPCmdProcessedData{
+			CommandID:  c.CmdID,
+			Results:    ErrorResponse(*msg),
+			StatusCode: http.StatusConflict,
+			Headers:    map[string]string{},
+		}, time.Now()))
+		return nil
+	}
 
-	//data.Results, _ = ProcessDELETE(ctx, a.Properties, c.Body)
+	// "Services may return a representation of the just deleted resource in the response body."
+	// grab it before the resource goes away
+	data := DeleteResponse(ctx, a, c.CmdID, c.auth)
 
 	// send event to trigger delete
 	a.PublishEvent(eh.NewEvent(RedfishResourceRemoved, &RedfishResourceRemovedData{
@@ -67,15 +79,37 @@ func (c *DELETE) Handle(ctx context.Context, a *RedfishResourceAggregate) error
 	}, time.Now()))
 
 	// send http response
-	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, &HTTPCmdProcessedData{
-		CommandID:  c.CmdID,
-		Results:    map[string]interface{}{},
-		StatusCode: 200,
-		Headers:    map[string]string{},
-	}, time.Now()))
+	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, data, time.Now()))
+	return nil
+}
+
+// VetoDelete asks the plugins for the resource if anything is stopping it from
+// being deleted. Plugin DELETE commands should call this before removing anything.
+func VetoDelete(ctx context.Context, a *RedfishResourceAggregate) *ExtendedInfo {
+	for _, pluginType := range []PluginType{PluginType(a.ResourceURI), PluginType(a.Plugin)} {
+		p, err := InstantiatePlugin(pluginType)
+		if err != nil {
+			continue
+		}
+		if v, ok := p.(DeleteVetoer); ok {
+			if msg := v.VetoDelete(ctx, a); msg != nil {
+				return msg
+			}
+		}
+	}
 	return nil
 }
 
+// DeleteResponse is the final GET representation of a resource that is being
+// deleted, for use as the DELETE response body.
+func DeleteResponse(ctx context.Context, a *RedfishResourceAggregate, cmdID eh.UUID, auth *RedfishAuthorizationProperty) *HTTPCmdProcessedData {
+	data := getResponse(ctx, a, cmdID, auth)
+	data.StatusCode = http.StatusOK
+	// headers like Location point at the resource, which is about to be gone
+	data.Headers = map[string]string{}
+	return data
+}
+
 // HTTP PATCH Command
 type PATCH struct {
 	ID    eh.UUID `json:"id"`
@@ -105,17 +139,26 @@ func (c *PATCH) ParseHTTPRequest(r *http.Request) error {
 }
 
 func (c *PATCH) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
+	c.HTTPEventBus.PublishEvent(ctx, eh.NewEvent(HTTPCmdProcessed, patchResponse(ctx, a, c.CmdID, c.auth, c.Body), time.Now()))
+	return nil
+}
+
+// patchResponse runs the body through the PATCH plugins and returns the
+// updated resource. Shared by PATCH and PUT.
+func patchResponse(ctx context.Context, a *RedfishResourceAggregate, cmdID eh.UUID, auth *RedfishAuthorizationProperty, body map[string]interface{}) *HTTPCmdProcessedData {
 	// set up the base response data
 	data := &HTTPCmdProcessedData{
-		CommandID: c.CmdID,
+		CommandID: cmdID,
 		Headers:   map[string]string{},
 	}
 	for k, v := range a.Headers {
 		data.Headers[k] = v
 	}
 	tmpResponse := map[string]interface{}{}
-	NewPatch(ctx, tmpResponse, a, &a.Properties, c.auth, c.Body)
+	NewPatch(ctx, tmpResponse, a, &a.Properties, auth, body)
 	data.Results = Flatten(&a.Properties, false)
+	// the ETag is for the resource, not the messages that go along with it
+	setETag(a, data)
 
 	r, ok := data.Results.(map[string]interface{})
 	if ok {
@@ -125,10 +168,9 @@ func (c *PATCH) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
 	}
 
 	data.StatusCode = a.StatusCode
-
-	c.HTTPEventBus.PublishEvent(ctx, eh.NewEvent(HTTPCmdProcessed, data, time.Now()))
-
-	return nil
+	// the status is only for this response, PATCH gets saved and it shouldn't stick around for the next GET
+	a.StatusCode = 0
+	return data
 }
 
 // HTTP POST Command
@@ -157,36 +199,13 @@ func (c *POST) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
 	return nil
 }
 
-// HTTP PUT Command
-type PUT struct {
-	ID    eh.UUID `json:"id"`
-	CmdID eh.UUID `json:"cmdid"`
-	Body  map[string]interface{}
-}
-
-func (c *PUT) AggregateType() eh.AggregateType { return AggregateType }
-func (c *PUT) AggregateID() eh.UUID            { return c.ID }
-func (c *PUT) CommandType() eh.CommandType     { return PUTCommand }
-func (c *PUT) SetAggID(id eh.UUID)             { c.ID = id }
-func (c *PUT) SetCmdID(id eh.UUID)             { c.CmdID = id }
-func (c *PUT) ParseHTTPRequest(r *http.Request) error {
-	json.NewDecoder(r.Body).Decode(&c.Body)
-	return nil
-}
-func (c *PUT) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
-	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, &HTTPCmdProcessedData{
-		CommandID:  c.CmdID,
-		Results:    map[string]interface{}{"ERROR": "This method not yet implemented"},
-		StatusCode: 501, // Not implemented
-		Headers:    map[string]string{},
-	}, time.Now()))
-	return nil
-}
-
 // HTTP HEAD Command
+// Runs exactly the same as GET, the http handler drops the body on the way out.
 type HEAD struct {
-	ID    eh.UUID `json:"id"`
-	CmdID eh.UUID `json:"cmdid"`
+	ID           eh.UUID `json:"id"`
+	CmdID        eh.UUID `json:"cmdid"`
+	HTTPEventBus eh.EventBus
+	auth         *RedfishAuthorizationProperty
 }
 
 func (c *HEAD) AggregateType() eh.AggregateType { return AggregateType }
@@ -194,17 +213,17 @@ func (c *HEAD) AggregateID() eh.UUID            { return c.ID }
 func (c *HEAD) CommandType() eh.CommandType     { return HEADCommand }
 func (c *HEAD) SetAggID(id eh.UUID)             { c.ID = id }
 func (c *HEAD) SetCmdID(id eh.UUID)             { c.CmdID = id }
+func (c *HEAD) SetUserDetails(a *RedfishAuthorizationProperty) string {
+	c.auth = a
+	return "checkMaster"
+}
 func (c *HEAD) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
-	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, &HTTPCmdProcessedData{
-		CommandID:  c.CmdID,
-		Results:    map[string]interface{}{"ERROR": "This method not yet implemented"},
-		StatusCode: 501, // Not implemented
-		Headers:    map[string]string{},
-	}, time.Now()))
+	c.HTTPEventBus.PublishEvent(ctx, eh.NewEvent(HTTPCmdProcessed, getResponse(ctx, a, c.CmdID, c.auth), time.Now()))
 	return nil
 }
 
 // HTTP OPTIONS Command
+// The Allow header is added by the http handler, so there is nothing to return here but an empty body.
 type OPTIONS struct {
 	ID    eh.UUID `json:"id"`
 	CmdID eh.UUID `json:"cmdid"`
@@ -218,8 +237,8 @@ func (c *OPTIONS) SetCmdID(id eh.UUID)             { c.CmdID = id }
 func (c *OPTIONS) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
 	a.PublishEvent(eh.NewEvent(HTTPCmdProcessed, &HTTPCmdProcessedData{
 		CommandID:  c.CmdID,
-		Results:    map[string]interface{}{"ERROR": "This method not yet implemented"},
-		StatusCode: 501, // Not implemented
+		Results:    map[string]interface{}{},
+		StatusCode: 200,
 		Headers:    map[string]string{},
 	}, time.Now()))
 	return nil
@@ -42,22 +42,26 @@ func (c *GET) SetUserDetails(a *RedfishAuthorizationProperty) string {
 	return "checkMaster"
 }
 func (c *GET) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
+	c.HTTPEventBus.PublishEvent(ctx, eh.NewEvent(HTTPCmdProcessed, getResponse(ctx, a, c.CmdID, c.auth), time.Now()))
+	return nil
+}
+
+// getResponse runs the GET pipeline for the aggregate. Shared by GET and HEAD so they always return identical headers.
+func getResponse(ctx context.Context, a *RedfishResourceAggregate, cmdID eh.UUID, auth *RedfishAuthorizationProperty) *HTTPCmdProcessedData {
 	// set up the base response data
 	data := &HTTPCmdProcessedData{
-		CommandID:  c.CmdID,
+		CommandID:  cmdID,
 		StatusCode: 200,
 		Headers:    map[string]string{},
 	}
-	// TODO: Should be able to discern supported methods from the meta and return those
 
 	for k, v := range a.Headers {
 		data.Headers[k] = v
 	}
 
-	NewGet(ctx, a, &a.Properties, c.auth)
+	NewGet(ctx, a, &a.Properties, auth)
 	data.Results = Flatten(&a.Properties, false)
 	data.StatusCode = a.StatusCode
-	c.HTTPEventBus.PublishEvent(ctx, eh.NewEvent(HTTPCmdProcessed, data, time.Now()))
-
-	return nil
+	setETag(a, data)
+	return data
 }
@@ -0,0 +1,173 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+const (
+	PUTCommand = eh.CommandType("R:PUT")
+)
+
+// Static type checking for commands to prevent runtime errors due to typos
+var _ = eh.Command(&PUT{})
+
+// HTTP PUT Command
+// PUT replaces all of the writable properties of the resource. It is turned
+// into the equivalent PATCH and runs through the same PATCH plugins.
+type PUT struct {
+	ID    eh.UUID `json:"id"`
+	CmdID eh.UUID `json:"cmdid"`
+
+	Body         map[string]interface{} `eh:"optional"`
+	auth         *RedfishAuthorizationProperty
+	HTTPEventBus eh.EventBus
+}
+
+func (c *PUT) AggregateType() eh.AggregateType { return AggregateType }
+func (c *PUT) AggregateID() eh.UUID            { return c.ID }
+func (c *PUT) CommandType() eh.CommandType     { return PUTCommand }
+func (c *PUT) SetAggID(id eh.UUID)             { c.ID = id }
+func (c *PUT) SetCmdID(id eh.UUID)             { c.CmdID = id }
+func (c *PUT) SetUserDetails(a *RedfishAuthorizationProperty) string {
+	c.auth = a
+	return "checkMaster"
+}
+func (c *PUT) ParseHTTPRequest(r *http.Request) error {
+	err := json.NewDecoder(r.Body).Decode(&c.Body)
+	if err != nil {
+		return err
+	}
+	if c.Body == nil {
+		return errors.New("PUT body has to be a JSON object")
+	}
+	return nil
+}
+
+func (c *PUT) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
+	body, errs := putBody(&a.Properties, c.Body, "")
+	if len(errs) > 0 {
+		data := &HTTPCmdProcessedData{
+			CommandID:  c.CmdID,
+			Results:    ErrorResponse(errs...),
+			StatusCode: http.StatusBadRequest,
+			Headers:    map[string]string{},
+		}
+		c.HTTPEventBus.PublishEvent(ctx, eh.NewEvent(HTTPCmdProcessed, data, time.Now()))
+		return nil
+	}
+
+	c.HTTPEventBus.PublishEvent(ctx, eh.NewEvent(HTTPCmdProcessed, patchResponse(ctx, a, c.CmdID, c.auth, body), time.Now()))
+	return nil
+}
+
+// putBody turns a PUT request into the equivalent PATCH body. Writable
+// properties (the ones with a PATCH plugin) that are left out are reset to the
+// DEFAULT they were created with, or reported missing if they don't have one.
+// Anything else in the request isn't writable and is rejected.
+func putBody(rrp *RedfishResourceProperty, request map[string]interface{}, path string) (map[string]interface{}, []ExtendedInfo) {
+	rrp.RLock()
+	defer rrp.RUnlock()
+
+	// a plugin that handles the whole object gets the request as is
+	if _, ok := rrp.Meta["PATCH"]; ok {
+		return request, nil
+	}
+
+	props, ok := rrp.Value.(map[string]interface{})
+	if !ok {
+		return request, nil
+	}
+
+	body := map[string]interface{}{}
+	errs := []ExtendedInfo{}
+
+	for name, reqVal := range request {
+		// annotations like @odata.etag aren't properties
+		if strings.Contains(name, "@") {
+			continue
+		}
+
+		child, ok := props[name].(*RedfishResourceProperty)
+		if !ok {
+			errs = append(errs, PropertyUnknown(path+name))
+			continue
+		}
+
+		if child.patchable() {
+			body[name] = reqVal
+			continue
+		}
+
+		if reqMap, ok := reqVal.(map[string]interface{}); ok && child.hasWritable() {
+			sub, e := putBody(child, reqMap, path+name+"/")
+			body[name] = sub
+			errs = append(errs, e...)
+			continue
+		}
+
+		errs = append(errs, PropertyNotWritable(path+name))
+	}
+
+	for name, v := range props {
+		if _, ok := request[name]; ok {
+			continue
+		}
+		child, ok := v.(*RedfishResourceProperty)
+		if !ok {
+			continue
+		}
+
+		if child.patchable() {
+			child.RLock()
+			def, ok := child.Meta["DEFAULT"]
+			child.RUnlock()
+			if ok {
+				body[name] = def
+			} else {
+				errs = append(errs, PropertyMissing(path+name))
+			}
+			continue
+		}
+
+		if child.hasWritable() {
+			sub, e := putBody(child, map[string]interface{}{}, path+name+"/")
+			if len(sub) > 0 {
+				body[name] = sub
+			}
+			errs = append(errs, e...)
+		}
+	}
+
+	return body, errs
+}
+
+func (rrp *RedfishResourceProperty) patchable() bool {
+	rrp.RLock()
+	defer rrp.RUnlock()
+	_, ok := rrp.Meta["PATCH"]
+	return ok
+}
+
+// hasWritable reports if anything below this property has a PATCH plugin
+func (rrp *RedfishResourceProperty) hasWritable() bool {
+	rrp.RLock()
+	defer rrp.RUnlock()
+
+	props, ok := rrp.Value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for _, v := range props {
+		if child, ok := v.(*RedfishResourceProperty); ok && (child.patchable() || child.hasWritable()) {
+			return true
+		}
+	}
+	return false
+}
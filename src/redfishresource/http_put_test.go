@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPutBody(t *testing.T) {
+	newResource := func() *RedfishResourceProperty {
+		rrp := &RedfishResourceProperty{}
+		rrp.Parse(map[string]interface{}{
+			"Id": "1",
+			"Enabled@meta": map[string]interface{}{
+				"DEFAULT": true,
+				"PATCH":   map[string]interface{}{"plugin": "GenericBool"}},
+			"Destination@meta": map[string]interface{}{
+				"PATCH": map[string]interface{}{"plugin": "Generic"}},
+			"Status": map[string]interface{}{
+				"Health": "OK",
+				"State@meta": map[string]interface{}{
+					"DEFAULT": "Enabled",
+					"PATCH":   map[string]interface{}{"plugin": "Generic"}},
+			},
+		})
+		return rrp
+	}
+
+	var tests = []struct {
+		testname string
+		request  map[string]interface{}
+		expected map[string]interface{}
+		errors   []string
+	}{
+		{"full replacement",
+			map[string]interface{}{"Enabled": false, "Destination": "https://x", "Status": map[string]interface{}{"State": "Disabled"}},
+			map[string]interface{}{"Enabled": false, "Destination": "https://x", "Status": map[string]interface{}{"State": "Disabled"}},
+			[]string{}},
+		{"omitted reset to default",
+			map[string]interface{}{"Destination": "https://x", "@odata.etag": "W/\"1\""},
+			map[string]interface{}{"Enabled": true, "Destination": "https://x", "Status": map[string]interface{}{"State": "Enabled"}},
+			[]string{}},
+		{"omitted without default", map[string]interface{}{}, nil, []string{"Base.1.0.PropertyMissing"}},
+		{"read only", map[string]interface{}{"Destination": "https://x", "Id": "2"}, nil, []string{"Base.1.0.PropertyNotWritable"}},
+		{"nested read only", map[string]interface{}{"Destination": "https://x", "Status": map[string]interface{}{"Health": "OK"}}, nil, []string{"Base.1.0.PropertyNotWritable"}},
+		{"unknown", map[string]interface{}{"Destination": "https://x", "Bogus": 1}, nil, []string{"Base.1.0.PropertyUnknown"}},
+	}
+	for _, subtest := range tests {
+		t.Run(subtest.testname, func(t *testing.T) {
+			body, errs := putBody(newResource(), subtest.request, "")
+			ids := []string{}
+			for _, e := range errs {
+				ids = append(ids, e.MessageId)
+			}
+			assert.Equal(t, subtest.errors, ids)
+			if subtest.expected != nil {
+				assert.Equal(t, subtest.expected, body)
+			}
+		})
+	}
+}
+
+func TestDefaultNotShared(t *testing.T) {
+	rrp := &RedfishResourceProperty{}
+	rrp.Parse(map[string]interface{}{
+		"Schedule@meta": map[string]interface{}{
+			"DEFAULT": map[string]interface{}{"RecurrenceInterval": "PT1M"},
+			"PATCH":   map[string]interface{}{"plugin": "Generic"}},
+	})
+
+	schedule := rrp.Value.(map[string]interface{})["Schedule"].(*RedfishResourceProperty)
+	schedule.Value.(map[string]interface{})["RecurrenceInterval"] = &RedfishResourceProperty{Value: "PT5M"}
+
+	assert.Equal(t, map[string]interface{}{"RecurrenceInterval": "PT5M"}, Flatten(schedule, false))
+	assert.Equal(t, map[string]interface{}{"RecurrenceInterval": "PT1M"}, schedule.Meta["DEFAULT"], "PUT still resets to what it was created with")
+}
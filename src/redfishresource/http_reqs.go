@@ -1,5 +1,11 @@
 package domain
 
+import (
+	"strings"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
 type HTTPReqType int
 
 const (
@@ -54,3 +60,73 @@ func MapHTTPReqToString(req HTTPReqType) string {
 		return "UNKNOWN"
 	}
 }
+
+// methods that the generic R: commands fully implement for any resource. R:POST
+// is only a placeholder, so that needs a uri or plugin command.
+var genericMethods = map[HTTPReqType]bool{
+	HTTP_GET:     true,
+	HTTP_HEAD:    true,
+	HTTP_OPTIONS: true,
+	HTTP_PATCH:   true,
+	HTTP_PUT:     true,
+	HTTP_DELETE:  true,
+}
+
+// PrivilegesFor returns the privileges needed for the request type. HEAD and
+// OPTIONS use the GET privileges unless the resource lists them explicitly.
+func (a *RedfishResourceAggregate) PrivilegesFor(req HTTPReqType) interface{} {
+	if privs, ok := a.PrivilegeMap[req]; ok {
+		return privs
+	}
+	if req == HTTP_HEAD || req == HTTP_OPTIONS {
+		return a.PrivilegeMap[HTTP_GET]
+	}
+	return nil
+}
+
+// MethodAllowed reports if the resource supports the request type at all,
+// independent of the privileges of any particular user. A method is
+// supported if the resource lists privileges for it and there is a command
+// that can handle it.
+func (a *RedfishResourceAggregate) MethodAllowed(req HTTPReqType) bool {
+	if req == HTTP_UNKNOWN {
+		return false
+	}
+	if req != HTTP_OPTIONS && len(privilegesToStrings(a.PrivilegesFor(req))) == 0 {
+		return false
+	}
+	// collections are never deleted, only their members
+	if req == HTTP_DELETE && (!a.Deletable || a.isCollection()) {
+		return false
+	}
+	if genericMethods[req] {
+		return true
+	}
+
+	method := MapHTTPReqToString(req)
+	for _, cmdType := range []eh.CommandType{
+		eh.CommandType(a.ResourceURI + ":" + method),
+		eh.CommandType(a.Plugin + ":" + method),
+	} {
+		if _, err := eh.CreateCommand(cmdType); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedMethods returns the list of methods supported by the resource, in the form used for the Allow header
+func (a *RedfishResourceAggregate) AllowedMethods() []string {
+	allowed := []string{}
+	for req := HTTP_GET; req < HTTP_UNKNOWN; req++ {
+		if a.MethodAllowed(req) {
+			allowed = append(allowed, MapHTTPReqToString(req))
+		}
+	}
+	return allowed
+}
+
+func (a *RedfishResourceAggregate) isCollection() bool {
+	namespace, _ := splitODataType(a.Type)
+	return strings.HasSuffix(namespace, "Collection")
+}
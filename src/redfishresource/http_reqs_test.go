@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteAllowed(t *testing.T) {
+	var tests = []struct {
+		testname  string
+		typ       string
+		deletable bool
+		privs     interface{}
+		expected  bool
+	}{
+		{"deletable member", "#Session.v1_0_0.Session", true, []string{"ConfigureManager"}, true},
+		{"not deletable", "#Session.v1_0_0.Session", false, []string{"ConfigureManager"}, false},
+		{"no privileges", "#Session.v1_0_0.Session", true, nil, false},
+		{"collection", "#SessionCollection.SessionCollection", true, []string{"ConfigureManager"}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testname, func(t *testing.T) {
+			a := &RedfishResourceAggregate{
+				Type:         tc.typ,
+				Deletable:    tc.deletable,
+				PrivilegeMap: map[HTTPReqType]interface{}{HTTP_GET: []string{"Login"}},
+			}
+			if tc.privs != nil {
+				a.PrivilegeMap[HTTP_DELETE] = tc.privs
+			}
+			assert.Equal(t, tc.expected, a.MethodAllowed(HTTP_DELETE))
+			assert.Equal(t, tc.expected, contains(a.AllowedMethods(), "DELETE"))
+		})
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -62,12 +62,14 @@ type CreateRedfishResource struct {
 	Privileges  map[string]interface{}
 
 	// optional stuff
-	Headers       map[string]string      `eh:"optional"`
-	Plugin        string                 `eh:"optional"`
-	DefaultFilter string                 `eh:"optional"`
-	Properties    map[string]interface{} `eh:"optional"`
-	Meta          map[string]interface{} `eh:"optional"`
-	Private       map[string]interface{} `eh:"optional"`
+	Headers        map[string]string      `eh:"optional"`
+	Plugin         string                 `eh:"optional"`
+	DefaultFilter  string                 `eh:"optional"`
+	Deletable      bool                   `eh:"optional"`
+	RequireIfMatch bool                   `eh:"optional"`
+	Properties     map[string]interface{} `eh:"optional"`
+	Meta           map[string]interface{} `eh:"optional"`
+	Private        map[string]interface{} `eh:"optional"`
 }
 
 // AggregateType satisfies base Aggregate interface
@@ -91,7 +93,12 @@ func (c *CreateRedfishResource) Handle(ctx context.Context, a *RedfishResourceAg
 	a.ID = c.ID
 	a.ResourceURI = c.ResourceURI
 	a.DefaultFilter = c.DefaultFilter
+	a.Deletable = c.Deletable
+	a.RequireIfMatch = c.RequireIfMatch
+	a.ETag = 1
 	a.Plugin = c.Plugin
+	a.Type = c.Type
+	a.Context = c.Context
 	a.Headers = make(map[string]string, len(c.Headers))
 	for k, v := range c.Headers {
 		a.Headers[k] = v
@@ -137,6 +144,7 @@ func (c *CreateRedfishResource) Handle(ctx context.Context, a *RedfishResourceAg
 	a.PublishEvent(eh.NewEvent(RedfishResourceCreated, &RedfishResourceCreatedData{
 		ID:          c.ID,
 		ResourceURI: c.ResourceURI,
+		Type:        c.Type,
 	}, time.Now()))
 
 	// then send out possible notifications about changes in the properties or meta
@@ -219,6 +227,12 @@ func (c *UpdateRedfishResourceProperties2) CommandType() eh.CommandType {
 // going through the aggregate it is [map]*RedfishResourceProperty...
 // Updated to append to list.  TODO need a way to clean lists and prevent duplicates
 func UpdateAgg(a *RedfishResourceAggregate, pathSlice []string, v interface{}, appendLimit int) error {
+	return updateAgg(a, pathSlice, v, appendLimit, false)
+}
+
+// updateAgg is UpdateAgg, but lists that have appendLimit entries can drop
+// their oldest entries to make room (wraps) instead of not taking any more
+func updateAgg(a *RedfishResourceAggregate, pathSlice []string, v interface{}, appendLimit int, wraps bool) error {
 	loc, ok := a.Properties.Value.(map[string]interface{})
 	if !ok {
 		return errors.New(fmt.Sprintf("Updateagg: aggregate wis wrong type %T", a.Properties.Value))
@@ -237,21 +251,18 @@ func UpdateAgg(a *RedfishResourceAggregate, pathSlice []string, v interface{}, a
 				return fmt.Errorf("UpdateAgg Failed, RedfishResourcePropertyFailed")
 			}
 			// metric events have the data appended
-			switch v.(type) {
-			case []interface{}, []map[string]interface{}:
-				j := k2.Value.([]interface{})
-				aggSLen := len(j)
-				v2 := v.([]interface{})
-				if aggSLen >= appendLimit {
-					continue
-				}
-				if appendLimit < aggSLen+len(v2) {
-					k2.Parse(v2[appendLimit-aggSLen:])
-				} else {
-					k2.Parse(v2)
+			switch v2 := v.(type) {
+			case []interface{}:
+				appendValues(k2, v2, appendLimit, wraps)
+				setCount(loc, p, k2.Value)
+				return nil
+			case []map[string]interface{}:
+				values := make([]interface{}, 0, len(v2))
+				for _, m := range v2 {
+					values = append(values, m)
 				}
-				k2.Parse(j)
-
+				appendValues(k2, values, appendLimit, wraps)
+				setCount(loc, p, k2.Value)
 				return nil
 			default:
 				if (plen == i) && (k2.Value != v) {
@@ -275,6 +286,77 @@ func UpdateAgg(a *RedfishResourceAggregate, pathSlice []string, v interface{}, a
 
 }
 
+// appendValues adds values to the end of the list in rrp. Once the list has
+// appendLimit entries the oldest ones are dropped to make room if wraps is set,
+// otherwise nothing more is added. A limit of 0 only means no limit if wraps is set.
+func appendValues(rrp *RedfishResourceProperty, values []interface{}, appendLimit int, wraps bool) {
+	old, _ := rrp.Value.([]interface{})
+	if !wraps {
+		room := appendLimit - len(old)
+		if room <= 0 {
+			return
+		}
+		if len(values) > room {
+			values = values[:room]
+		}
+	}
+
+	rrp.Parse(values)
+	l, _ := rrp.Value.([]interface{})
+	if wraps && appendLimit > 0 && len(l) > appendLimit {
+		rrp.Value = append([]interface{}{}, l[len(l)-appendLimit:]...)
+	}
+}
+
+// setCount keeps name@odata.count up to date for lists that have one
+func setCount(loc map[string]interface{}, name string, value interface{}) {
+	l, ok := value.([]interface{})
+	if !ok {
+		return
+	}
+	if count, ok := loc[name+"@odata.count"].(*RedfishResourceProperty); ok {
+		count.Lock()
+		count.Value = len(l)
+		count.Unlock()
+	}
+}
+
+// overwriteAgg replaces the values at each path instead of appending, for
+// metric reports that are built over again each time
+func overwriteAgg(a *RedfishResourceAggregate, properties map[string]interface{}) error {
+	a.Properties.Lock()
+	defer a.Properties.Unlock()
+	for k, v := range properties {
+		pathSlice := strings.Split(k, "/")
+		loc, ok := a.Properties.Value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("overwriteAgg: aggregate is wrong type %T", a.Properties.Value)
+		}
+		for _, p := range pathSlice[:len(pathSlice)-1] {
+			k2, ok := loc[p].(*RedfishResourceProperty)
+			if !ok {
+				return fmt.Errorf("overwriteAgg: can not find %s for %s", p, k)
+			}
+			if loc, ok = k2.Value.(map[string]interface{}); !ok {
+				return fmt.Errorf("overwriteAgg: %s is not a map for %s", p, k)
+			}
+		}
+
+		last := pathSlice[len(pathSlice)-1]
+		k2, ok := loc[last].(*RedfishResourceProperty)
+		if !ok {
+			k2 = &RedfishResourceProperty{}
+			loc[last] = k2
+		}
+		k2.Lock()
+		k2.Value = nil
+		k2.ParseUnlocked(v)
+		k2.Unlock()
+		setCount(loc, last, k2.Value)
+	}
+	return nil
+}
+
 func GetValueinAgg(a *RedfishResourceAggregate, pathSlice []string) interface{} {
 	a.Properties.Lock()
 	defer a.Properties.Unlock()
@@ -357,6 +439,7 @@ func (c *UpdateRedfishResourceProperties2) Handle(ctx context.Context, a *Redfis
 	}
 
 	if len(d.PropertyNames) > 0 {
+		a.ETag++
 		a.PublishEvent(eh.NewEvent(RedfishResourcePropertiesUpdated2, d, time.Now()))
 	}
 	return err
@@ -380,13 +463,18 @@ func (c *UpdateMetricRedfishResource) CommandType() eh.CommandType {
 	return UpdateMetricRedfishResourcePropertiesCommand
 }
 
-//reportUpdateType int // 0-AppendStopsWhenFull, 1-AppendWrapsWhenFull, 3- NewReport, 4-Overwrite
-
-// assume AppendStopsWhenFull
+// ReportUpdateType is the ReportUpdates of the MetricReportDefinition.
+// Overwrite and NewReport replace the values, AppendWrapsWhenFull and
+// AppendStopsWhenFull add to the lists. Anything else is AppendStopsWhenFull.
 func (c *UpdateMetricRedfishResource) Handle(ctx context.Context, a *RedfishResourceAggregate) error {
+	a.ETag++
+	if c.ReportUpdateType == "Overwrite" || c.ReportUpdateType == "NewReport" {
+		return overwriteAgg(a, c.Properties)
+	}
+
 	for k, v := range c.Properties {
 		pathSlice := strings.Split(k, "/")
-		if err := UpdateAgg(a, pathSlice, v, int(c.AppendLimit)); err != nil {
+		if err := updateAgg(a, pathSlice, v, int(c.AppendLimit), c.ReportUpdateType == "AppendWrapsWhenFull"); err != nil {
 			fmt.Println("failed to updated agg")
 			return err
 		}
@@ -428,8 +516,12 @@ func (c *UpdateRedfishResourceProperties) Handle(ctx context.Context, a *Redfish
 	}
 
 	a.Properties.Parse(c.Properties)
+	for k := range c.Properties {
+		d.PropertyNames = append(d.PropertyNames, strings.TrimSuffix(k, "@meta"))
+	}
 
 	if len(d.PropertyNames) > 0 {
+		a.ETag++
 		a.PublishEvent(eh.NewEvent(RedfishResourcePropertiesUpdated, d, time.Now()))
 	}
 	if len(e.Meta) > 0 {
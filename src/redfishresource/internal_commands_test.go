@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateMetricReportUpdates(t *testing.T) {
+	var tests = []struct {
+		testname    string
+		updateType  string
+		appendLimit int
+		expected    []interface{}
+	}{
+		{"wraps", "AppendWrapsWhenFull", 4, []interface{}{2, 3, 4, 5}},
+		{"wraps with room", "AppendWrapsWhenFull", 10, []interface{}{1, 2, 3, 4, 5}},
+		{"wraps without a limit", "AppendWrapsWhenFull", 0, []interface{}{1, 2, 3, 4, 5}},
+		{"stops", "AppendStopsWhenFull", 4, []interface{}{1, 2, 3, 4}},
+		{"stops when full", "AppendStopsWhenFull", 2, []interface{}{1, 2}},
+		{"overwrite", "Overwrite", 4, []interface{}{3, 4, 5}},
+		{"new report", "NewReport", 4, []interface{}{3, 4, 5}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testname, func(t *testing.T) {
+			a := &RedfishResourceAggregate{ID: "1", ResourceURI: "/redfish/v1/TelemetryService/MetricReports/Fans"}
+			a.Properties.Parse(map[string]interface{}{
+				"MetricValues":             []interface{}{1, 2},
+				"MetricValues@odata.count": 2,
+				"ReportSequence":           "1",
+			})
+
+			err := (&UpdateMetricRedfishResource{
+				ID:               "1",
+				AppendLimit:      tc.appendLimit,
+				ReportUpdateType: tc.updateType,
+				Properties: map[string]interface{}{
+					"MetricValues":   []interface{}{3, 4, 5},
+					"ReportSequence": "2",
+				},
+			}).Handle(context.Background(), a)
+			assert.Nil(t, err)
+
+			report := Flatten(&a.Properties, false).(map[string]interface{})
+			assert.Equal(t, tc.expected, report["MetricValues"])
+			assert.Equal(t, len(tc.expected), report["MetricValues@odata.count"])
+			assert.Equal(t, "2", report["ReportSequence"])
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MessageRegistry is a Redfish message registry, like the Base registry or the Dell EEMI messages
+type MessageRegistry struct {
+	Id              string
+	Name            string
+	Language        string
+	Description     string
+	RegistryPrefix  string
+	RegistryVersion string
+	OwningEntity    string
+	Messages        map[string]RegistryMessage
+}
+
+// RegistryMessage is one message in a registry. Message and Resolution have
+// %1, %2... where the MessageArgs go.
+type RegistryMessage struct {
+	Description  string
+	Message      string
+	Severity     string
+	NumberOfArgs int
+	ParamTypes   []string `json:",omitempty"`
+	Resolution   string
+}
+
+var registriesMu sync.RWMutex
+
+// messageRegistries are the loaded registries, by RegistryPrefix
+var messageRegistries = map[string]*MessageRegistry{}
+
+// LoadMessageRegistries loads every .json registry in dir. The registries that
+// load are kept even if some of them fail.
+func LoadMessageRegistries(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no message registries in %s", dir)
+	}
+
+	var loadErr error
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err == nil {
+			reg := &MessageRegistry{}
+			if err = json.Unmarshal(data, reg); err == nil {
+				err = AddMessageRegistry(reg)
+			}
+		}
+		if err != nil && loadErr == nil {
+			loadErr = fmt.Errorf("could not load message registry %s: %s", file, err)
+		}
+	}
+	return loadErr
+}
+
+// AddMessageRegistry makes the messages in reg available. If there is already
+// a registry with the same prefix, the newer version is kept.
+func AddMessageRegistry(reg *MessageRegistry) error {
+	if reg.RegistryPrefix == "" || strings.Contains(reg.RegistryPrefix, ".") {
+		return fmt.Errorf("bad RegistryPrefix '%s'", reg.RegistryPrefix)
+	}
+
+	registriesMu.Lock()
+	defer registriesMu.Unlock()
+	if old, ok := messageRegistries[reg.RegistryPrefix]; ok && compareVersions(old.RegistryVersion, reg.RegistryVersion) > 0 {
+		return nil
+	}
+	messageRegistries[reg.RegistryPrefix] = reg
+	return nil
+}
+
+// RemoveMessageRegistry drops the registry with the prefix, if there is one
+func RemoveMessageRegistry(prefix string) {
+	registriesMu.Lock()
+	defer registriesMu.Unlock()
+	delete(messageRegistries, prefix)
+}
+
+// MessageRegistries returns the loaded registries, sorted by prefix
+func MessageRegistries() []*MessageRegistry {
+	registriesMu.RLock()
+	defer registriesMu.RUnlock()
+	ret := []*MessageRegistry{}
+	for _, reg := range messageRegistries {
+		ret = append(ret, reg)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].RegistryPrefix < ret[j].RegistryPrefix })
+	return ret
+}
+
+// LookupMessage finds a message by MessageId. The id can be
+// "Registry.Major.Minor.Key", or "Registry.Key". A versioned id has to have the
+// same major version as the loaded registry, the minor version doesn't have to
+// match. A bare "Key", like the ones in the LCL, is looked for in Base first
+// and then the other registries in prefix order, so it always finds the same one.
+func LookupMessage(messageId string) (RegistryMessage, bool) {
+	registriesMu.RLock()
+	defer registriesMu.RUnlock()
+
+	parts := strings.Split(messageId, ".")
+	key := parts[len(parts)-1]
+	if key == "" {
+		return RegistryMessage{}, false
+	}
+
+	if len(parts) == 1 {
+		prefixes := []string{}
+		for prefix := range messageRegistries {
+			if prefix != "Base" {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+		sort.Strings(prefixes)
+		for _, prefix := range append([]string{"Base"}, prefixes...) {
+			if reg, ok := messageRegistries[prefix]; ok {
+				if msg, ok := reg.Messages[key]; ok {
+					return msg, true
+				}
+			}
+		}
+		return RegistryMessage{}, false
+	}
+
+	reg, ok := messageRegistries[parts[0]]
+	if !ok {
+		return RegistryMessage{}, false
+	}
+	if len(parts) > 2 && parts[1] != strings.Split(reg.RegistryVersion, ".")[0] {
+		return RegistryMessage{}, false
+	}
+	msg, ok := reg.Messages[key]
+	return msg, ok
+}
+
+// Format fills in the message text with args
+func (m RegistryMessage) Format(args []string) string {
+	return formatMessageArgs(m.Message, args)
+}
+
+// FormatResolution fills in the resolution text with args
+func (m RegistryMessage) FormatResolution(args []string) string {
+	return formatMessageArgs(m.Resolution, args)
+}
+
+func formatMessageArgs(text string, args []string) string {
+	// go backwards so %1 doesn't eat the front of %10
+	for i := len(args); i > 0; i-- {
+		text = strings.Replace(text, "%"+strconv.Itoa(i), args[i-1], -1)
+	}
+	return text
+}
+
+// FillMessage sets message, severity, and resolution from the registry for any
+// of them that are empty. It returns false if the MessageId isn't in a registry.
+func FillMessage(messageId string, args []string, message, severity, resolution *string) bool {
+	msg, ok := LookupMessage(messageId)
+	if !ok {
+		return false
+	}
+	if *message == "" {
+		*message = msg.Format(args)
+	}
+	if *severity == "" {
+		*severity = msg.Severity
+	}
+	if *resolution == "" {
+		*resolution = msg.FormatResolution(args)
+	}
+	return true
+}
+
+// compareVersions compares "1.0.10" style versions
+func compareVersions(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var an, bn int
+		if i < len(as) {
+			an, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			bn, _ = strconv.Atoi(bs[i])
+		}
+		if an != bn {
+			return an - bn
+		}
+	}
+	return 0
+}
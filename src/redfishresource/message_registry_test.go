@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageRegistry(t *testing.T) {
+	defer RemoveMessageRegistry("TestReg")
+	assert.NotNil(t, AddMessageRegistry(&MessageRegistry{RegistryPrefix: "Bad.Prefix"}))
+	assert.Nil(t, AddMessageRegistry(&MessageRegistry{
+		RegistryPrefix:  "TestReg",
+		RegistryVersion: "1.0.2",
+		Messages: map[string]RegistryMessage{
+			"PropertyOff": {Message: "The property %1 on %2 is off.", Severity: "Warning", NumberOfArgs: 2, Resolution: "Turn %1 on."},
+		},
+	}))
+	// older versions don't replace the newer one
+	assert.Nil(t, AddMessageRegistry(&MessageRegistry{
+		RegistryPrefix:  "TestReg",
+		RegistryVersion: "1.0.10",
+		Messages:        map[string]RegistryMessage{"PropertyOff": {Message: "newer", Severity: "OK"}},
+	}))
+	assert.Nil(t, AddMessageRegistry(&MessageRegistry{
+		RegistryPrefix:  "TestReg",
+		RegistryVersion: "1.0.9",
+		Messages:        map[string]RegistryMessage{"PropertyOff": {Message: "older", Severity: "OK"}},
+	}))
+
+	var tests = []struct {
+		testname  string
+		messageId string
+		expected  string
+		found     bool
+	}{
+		{"versioned", "TestReg.1.0.PropertyOff", "newer", true},
+		{"other minor version", "TestReg.1.2.PropertyOff", "newer", true},
+		{"other major version", "TestReg.2.0.PropertyOff", "", false},
+		{"versionless", "TestReg.PropertyOff", "newer", true},
+		{"bare key", "PropertyOff", "newer", true},
+		{"unknown key", "TestReg.1.0.PropertyOn", "", false},
+		{"unknown registry", "NoReg.1.0.PropertyOff", "", false},
+		{"empty", "", "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testname, func(t *testing.T) {
+			msg, ok := LookupMessage(tc.messageId)
+			assert.Equal(t, tc.found, ok)
+			assert.Equal(t, tc.expected, msg.Message)
+		})
+	}
+}
+
+func TestLookupBareKey(t *testing.T) {
+	defer RemoveMessageRegistry("BReg")
+	defer RemoveMessageRegistry("AReg")
+	defer RemoveMessageRegistry("Base")
+	for _, prefix := range []string{"BReg", "AReg"} {
+		assert.Nil(t, AddMessageRegistry(&MessageRegistry{
+			RegistryPrefix:  prefix,
+			RegistryVersion: "1.0.0",
+			Messages:        map[string]RegistryMessage{"Dup": {Message: prefix}},
+		}))
+	}
+	msg, _ := LookupMessage("Dup")
+	assert.Equal(t, "AReg", msg.Message, "the first registry by prefix")
+
+	assert.Nil(t, AddMessageRegistry(&MessageRegistry{
+		RegistryPrefix:  "Base",
+		RegistryVersion: "1.0.0",
+		Messages:        map[string]RegistryMessage{"Dup": {Message: "Base"}},
+	}))
+	msg, _ = LookupMessage("Dup")
+	assert.Equal(t, "Base", msg.Message, "Base comes before the others")
+
+	RemoveMessageRegistry("Base")
+	_, ok := LookupMessage("Base.1.0.Dup")
+	assert.False(t, ok)
+}
+
+func TestFillMessage(t *testing.T) {
+	defer RemoveMessageRegistry("FillReg")
+	assert.Nil(t, AddMessageRegistry(&MessageRegistry{
+		RegistryPrefix:  "FillReg",
+		RegistryVersion: "1.0.0",
+		Messages: map[string]RegistryMessage{
+			"Args": {Message: "%1 of %10 on %2.", Severity: "Critical", Resolution: "Look at %2."},
+		},
+	}))
+	args := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}
+
+	var message, severity, resolution string
+	assert.True(t, FillMessage("FillReg.1.0.Args", args, &message, &severity, &resolution))
+	assert.Equal(t, "a of j on b.", message)
+	assert.Equal(t, "Critical", severity)
+	assert.Equal(t, "Look at b.", resolution)
+
+	// whatever is already set is left alone
+	message, severity, resolution = "set", "", ""
+	assert.True(t, FillMessage("FillReg.Args", args, &message, &severity, &resolution))
+	assert.Equal(t, "set", message)
+	assert.Equal(t, "Critical", severity)
+
+	message, severity, resolution = "", "", ""
+	assert.False(t, FillMessage("FillReg.1.0.Nope", args, &message, &severity, &resolution))
+	assert.Equal(t, "", message)
+}
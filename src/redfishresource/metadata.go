@@ -0,0 +1,261 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	eh "github.com/looplab/eventhorizon"
+)
+
+const (
+	serviceRootURI = "/redfish/v1"
+	sessionsURI    = "/redfish/v1/SessionService/Sessions"
+)
+
+// metadataCache holds the generated $metadata and odata service documents.
+// Created/Removed events mark it dirty when the set of types or top level uris
+// changes, and it is rebuilt on the next request rather than on every event,
+// since resources come and go in bursts. The generation is atomic so that
+// invalidate() never takes the lock: it is called with the tree lock held, and a
+// rebuild takes the tree lock while holding ours.
+type metadataCache struct {
+	sync.Mutex
+	generation uint64
+	built      bool
+	builtGen   uint64
+	metadata   []byte
+	odata      []byte
+
+	// protected by the tree lock
+	types     map[eh.UUID]string
+	typeCount map[string]int
+}
+
+func (m *metadataCache) invalidate() {
+	atomic.AddUint64(&m.generation, 1)
+}
+
+// called with the tree lock held
+func (m *metadataCache) addResource(id eh.UUID, uri string, typ string) {
+	if m.types == nil {
+		m.types = map[eh.UUID]string{}
+		m.typeCount = map[string]int{}
+	}
+	m.types[id] = typ
+	m.typeCount[typ]++
+	if m.typeCount[typ] == 1 || inServiceDocument(uri) {
+		m.invalidate()
+	}
+}
+
+// called with the tree lock held
+func (m *metadataCache) removeResource(id eh.UUID, uri string) {
+	typ, ok := m.types[id]
+	if !ok {
+		return
+	}
+	delete(m.types, id)
+	m.typeCount[typ]--
+	if m.typeCount[typ] <= 0 {
+		delete(m.typeCount, typ)
+		m.invalidate()
+	} else if inServiceDocument(uri) {
+		m.invalidate()
+	}
+}
+
+// MetadataHandler serves the $metadata CSDL document referencing the schemas for the types currently in the tree
+func (d *DomainObjects) MetadataHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metadata, _ := d.getMetadata()
+		writeMetadata(w, "application/xml", metadata)
+	})
+}
+
+// ODataHandler serves the odata service document listing the top level resources currently in the tree
+func (d *DomainObjects) ODataHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, odata := d.getMetadata()
+		writeMetadata(w, "application/json; charset=utf-8", odata)
+	})
+}
+
+func writeMetadata(w http.ResponseWriter, contentType string, b []byte) {
+	w.Header().Set("OData-Version", "4.0")
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.Write(b)
+}
+
+func (d *DomainObjects) getMetadata() ([]byte, []byte) {
+	d.metadata.Lock()
+	defer d.metadata.Unlock()
+
+	gen := atomic.LoadUint64(&d.metadata.generation)
+	if !d.metadata.built || gen != d.metadata.builtGen {
+		types, uris := d.collectTypes()
+		d.metadata.metadata = generateMetadata(types)
+		d.metadata.odata = generateOData(uris)
+		d.metadata.built = true
+		d.metadata.builtGen = gen
+	}
+
+	return d.metadata.metadata, d.metadata.odata
+}
+
+// collectTypes returns the set of @odata.type in use and all of the uris in the tree
+func (d *DomainObjects) collectTypes() (map[string]bool, []string) {
+	d.treeMu.RLock()
+	defer d.treeMu.RUnlock()
+
+	types := make(map[string]bool, len(d.metadata.typeCount))
+	for typ := range d.metadata.typeCount {
+		if typ != "" {
+			types[typ] = true
+		}
+	}
+
+	uris := make([]string, 0, len(d.Tree))
+	for uri := range d.Tree {
+		uris = append(uris, uri) // preallocated
+	}
+
+	return types, uris
+}
+
+// splitODataType splits "#Chassis.v1_2_0.Chassis" into "Chassis" and "Chassis.v1_2_0".
+// Collections aren't versioned, so "#ChassisCollection.ChassisCollection" only has the first.
+func splitODataType(typ string) (namespace string, versioned string) {
+	parts := strings.Split(strings.TrimPrefix(typ, "#"), ".")
+	if len(parts) < 2 || !isIdentifier(parts[0]) {
+		return "", ""
+	}
+	namespace = parts[0]
+	if len(parts) == 3 && isIdentifier(parts[1]) {
+		versioned = namespace + "." + parts[1]
+	}
+	return
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// baseMetadataTypes are referenced by the properties of other resources, so
+// CSDL clients need them even though no resource has them as its @odata.type
+var baseMetadataTypes = []string{
+	"#Resource.v1_0_0.Resource",
+	"#Message.v1_0_2.Message",
+	"#IPAddresses.v1_0_2.IPAddresses",
+	"#PhysicalContext.v1_0_2.PhysicalContext",
+	"#Privileges.v1_0_2.Privileges",
+}
+
+func generateMetadata(types map[string]bool) []byte {
+	all := make(map[string]bool, len(types)+len(baseMetadataTypes))
+	for _, typ := range baseMetadataTypes {
+		all[typ] = true
+	}
+	for typ := range types {
+		all[typ] = true
+	}
+
+	// namespace -> set of versioned namespaces
+	namespaces := map[string]map[string]bool{}
+	serviceRoot := "ServiceRoot.v1_0_0"
+	for typ := range all {
+		namespace, versioned := splitODataType(typ)
+		if namespace == "" {
+			continue
+		}
+		if _, ok := namespaces[namespace]; !ok {
+			namespaces[namespace] = map[string]bool{}
+		}
+		if versioned != "" {
+			namespaces[namespace][versioned] = true
+			if namespace == "ServiceRoot" {
+				serviceRoot = versioned
+			}
+		}
+	}
+
+	names := make([]string, 0, len(namespaces))
+	for namespace := range namespaces {
+		names = append(names, namespace) // preallocated
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<edmx:Edmx xmlns:edmx="http://docs.oasis-open.org/odata/ns/edmx" Version="4.0">` + "\n\n")
+	for _, namespace := range names {
+		fmt.Fprintf(&b, "  <edmx:Reference Uri=\"/schemas/v1/%s_v1.xml\">\n", namespace)
+		fmt.Fprintf(&b, "    <edmx:Include Namespace=\"%s\"/>\n", namespace)
+
+		versions := make([]string, 0, len(namespaces[namespace]))
+		for v := range namespaces[namespace] {
+			versions = append(versions, v) // preallocated
+		}
+		sort.Strings(versions)
+		for _, v := range versions {
+			fmt.Fprintf(&b, "    <edmx:Include Namespace=\"%s\"/>\n", v)
+		}
+		b.WriteString("  </edmx:Reference>\n")
+	}
+	b.WriteString("  <edmx:Reference Uri=\"/schemas/v1/RedfishExtensions_v1.xml\">\n")
+	b.WriteString("    <edmx:Include Namespace=\"RedfishExtensions.v1_0_0\" Alias=\"Redfish\"/>\n")
+	b.WriteString("  </edmx:Reference>\n\n")
+
+	b.WriteString("  <edmx:DataServices>\n")
+	b.WriteString("    <Schema xmlns=\"http://docs.oasis-open.org/odata/ns/edm\" Namespace=\"Service\">\n")
+	fmt.Fprintf(&b, "      <EntityContainer Name=\"Service\" Extends=\"%s.ServiceContainer\"/>\n", serviceRoot)
+	b.WriteString("    </Schema>\n")
+	b.WriteString("  </edmx:DataServices>\n")
+	b.WriteString("</edmx:Edmx>\n")
+
+	return b.Bytes()
+}
+
+type odataService struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	URL  string `json:"url"`
+}
+
+func inServiceDocument(uri string) bool {
+	return uri == sessionsURI || path.Dir(uri) == serviceRootURI
+}
+
+// generateOData lists the service root, everything directly under it, and the sessions collection (required by DSP0266)
+func generateOData(uris []string) []byte {
+	services := []odataService{}
+	for _, uri := range uris {
+		if inServiceDocument(uri) {
+			services = append(services, odataService{Name: path.Base(uri), Kind: "Singleton", URL: uri})
+		}
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].URL < services[j].URL })
+	services = append([]odataService{{Name: "Service", Kind: "Singleton", URL: serviceRootURI + "/"}}, services...)
+
+	b, _ := json.MarshalIndent(map[string]interface{}{
+		"@odata.context": serviceRootURI + "/$metadata",
+		"value":          services,
+	}, "", "    ")
+	return b
+}
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	eh "github.com/looplab/eventhorizon"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateMetadata(t *testing.T) {
+	d := &DomainObjects{Tree: map[string]eh.UUID{}}
+	add := func(uri, typ string) eh.UUID {
+		id := eh.NewUUID()
+		d.Tree[uri] = id
+		d.metadata.addResource(id, uri, typ)
+		return id
+	}
+	add("/redfish/v1", "#ServiceRoot.v1_2_0.ServiceRoot")
+	add("/redfish/v1/Chassis", "#ChassisCollection.ChassisCollection")
+	add("/redfish/v1/Chassis/System.Chassis.1", "#Chassis.v1_2_0.Chassis")
+	id := add("/redfish/v1/Chassis/System.Chassis.1/Power", "#Power.v1_0_2.Power")
+	add("/redfish/v1/SessionService/Sessions", "#SessionCollection.SessionCollection")
+	add("/redfish/v1/Oem/Thing", "not a type")
+
+	types, uris := d.collectTypes()
+	assert.Len(t, uris, 6)
+	assert.True(t, types["#Power.v1_0_2.Power"])
+
+	metadata := string(generateMetadata(types))
+	assert.Contains(t, metadata, `<edmx:Reference Uri="/schemas/v1/Chassis_v1.xml">
+    <edmx:Include Namespace="Chassis"/>
+    <edmx:Include Namespace="Chassis.v1_2_0"/>
+  </edmx:Reference>`)
+	assert.Contains(t, metadata, `<edmx:Include Namespace="ChassisCollection"/>`)
+	assert.Contains(t, metadata, `<edmx:Include Namespace="Power.v1_0_2"/>`)
+	assert.Contains(t, metadata, `Extends="ServiceRoot.v1_2_0.ServiceContainer"`)
+	assert.NotContains(t, metadata, "not a type")
+	for _, base := range []string{"Resource", "Message", "IPAddresses", "PhysicalContext", "Privileges"} {
+		assert.Contains(t, metadata, `<edmx:Reference Uri="/schemas/v1/`+base+`_v1.xml">`, "base references are always there")
+	}
+
+	d.metadata.removeResource(id, "/redfish/v1/Chassis/System.Chassis.1/Power")
+	types, _ = d.collectTypes()
+	assert.NotContains(t, string(generateMetadata(types)), "Power_v1.xml")
+
+	odata := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(generateOData(uris), &odata))
+	urls := []string{}
+	for _, v := range odata["value"].([]interface{}) {
+		urls = append(urls, v.(map[string]interface{})["url"].(string))
+	}
+	assert.Equal(t, []string{"/redfish/v1/", "/redfish/v1/Chassis", "/redfish/v1/SessionService/Sessions"}, urls)
+}
@@ -36,6 +36,14 @@ type AggregatePlugin interface {
 	UpdateAggregate(context.Context, *RedfishResourceAggregate, *sync.WaitGroup, string, string)
 }
 
+// DeleteVetoer is implemented by plugins that need to refuse the DELETE of a
+// resource that is otherwise deletable, for example one that is still in use.
+// The plugin is looked up by the resource URI and by the Plugin of the
+// aggregate. Return nil to let the delete go ahead.
+type DeleteVetoer interface {
+	VetoDelete(context.Context, *RedfishResourceAggregate) *ExtendedInfo
+}
+
 var plugins = make(map[PluginType]func() Plugin)
 var pluginsMu sync.RWMutex
 
@@ -0,0 +1,189 @@
+package domain
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// MaxExpandLevels is the deepest $levels we will honor for $expand. Anything
+// larger is clamped to this value to bound the work done per request.
+const MaxExpandLevels = 6
+
+const (
+	expandAll     = "*" // expand all hyperlinks, including those under Links
+	expandNoLinks = "." // expand subordinate hyperlinks only (not under Links)
+	expandLinks   = "~" // expand only hyperlinks found under Links
+)
+
+// parses the $expand (and optional standalone $levels) query options
+// supported forms:
+//
+//	$expand=*  $expand=.  $expand=~
+//	$expand=.($levels=2)
+//	$expand=.&$levels=2
+//
+// returns false if the expand option could not be parsed
+func expandSetup(auth *RedfishAuthorizationProperty, expand string, levels string) bool {
+	expand = strings.TrimSpace(expand)
+	if expand == "" {
+		return false
+	}
+
+	typ := expand[:1]
+	if typ != expandAll && typ != expandNoLinks && typ != expandLinks {
+		return false
+	}
+
+	auth.expand = typ
+	auth.levels = 1
+
+	opts := strings.TrimSpace(expand[1:])
+	if opts != "" {
+		if opts[0] != '(' || opts[len(opts)-1] != ')' {
+			return false
+		}
+		opts = opts[1 : len(opts)-1]
+		kv := strings.SplitN(opts, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) != "$levels" {
+			return false
+		}
+		levels = kv[1]
+	}
+
+	if levels != "" {
+		l, err := strconv.Atoi(strings.TrimSpace(levels))
+		if err != nil || l < 1 {
+			return false
+		}
+		auth.levels = l
+	}
+
+	if auth.levels > MaxExpandLevels {
+		auth.levels = MaxExpandLevels
+	}
+
+	auth.doExpand = true
+	return true
+}
+
+// handleExpand walks the output replacing hyperlinks with the full GET
+// representation of the resource they point to. Each expanded resource is
+// processed through the same meta plugins and privilege checks as a direct GET.
+func (rh *RedfishHandler) handleExpand(ctx context.Context, a *RedfishAuthorizationProperty, d *HTTPCmdProcessedData) *HTTPCmdProcessedData {
+	if !a.doExpand {
+		return d
+	}
+
+	res, ok := d.Results.(map[string]interface{})
+	if !ok {
+		return d
+	}
+
+	d.Results = rh.expandChildren(ctx, a, res, a.levels, false)
+	return d
+}
+
+// expandChildren returns a copy of the map with every child property processed for expansion.
+func (rh *RedfishHandler) expandChildren(ctx context.Context, a *RedfishAuthorizationProperty, m map[string]interface{}, levels int, inLinks bool) map[string]interface{} {
+	ret := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		ret[k] = rh.expandValue(ctx, a, v, levels, inLinks || k == "Links")
+	}
+	return ret
+}
+
+func (rh *RedfishHandler) expandValue(ctx context.Context, a *RedfishAuthorizationProperty, v interface{}, levels int, inLinks bool) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if uri, ok := isODataReference(t); ok && wantExpand(a.expand, inLinks) {
+			expanded, ok := rh.getExpandedResource(ctx, a, uri)
+			if !ok {
+				return t
+			}
+			if levels > 1 {
+				return rh.expandChildren(ctx, a, expanded, levels-1, false)
+			}
+			return expanded
+		}
+		return rh.expandChildren(ctx, a, t, levels, inLinks)
+
+	case []interface{}:
+		ret := make([]interface{}, len(t))
+		for i, item := range t {
+			ret[i] = rh.expandValue(ctx, a, item, levels, inLinks)
+		}
+		return ret
+
+	default:
+		return v
+	}
+}
+
+func wantExpand(typ string, inLinks bool) bool {
+	switch typ {
+	case expandAll:
+		return true
+	case expandNoLinks:
+		return !inLinks
+	case expandLinks:
+		return inLinks
+	}
+	return false
+}
+
+// a reference is an object that has nothing but an @odata.id pointing at another resource
+func isODataReference(m map[string]interface{}) (string, bool) {
+	if len(m) != 1 {
+		return "", false
+	}
+	uri, ok := m["@odata.id"].(string)
+	if !ok || uri == "" {
+		return "", false
+	}
+	// references to a fragment inside another resource can't be expanded on their own
+	if strings.Contains(uri, "#") {
+		return "", false
+	}
+	return uri, true
+}
+
+func (rh *RedfishHandler) getExpandedResource(ctx context.Context, a *RedfishAuthorizationProperty, uri string) (map[string]interface{}, bool) {
+	// @odata.id is path escaped on the way out, tree is not
+	if u, err := url.PathUnescape(uri); err == nil {
+		uri = u
+	}
+
+	aggID, ok := rh.d.GetAggregateIDOK(uri)
+	if !ok {
+		return nil, false
+	}
+
+	agg, err := rh.d.AggregateStore.Load(ctx, AggregateType, aggID)
+	if err != nil {
+		return nil, false
+	}
+	redfishResource, ok := agg.(*RedfishResourceAggregate)
+	if !ok {
+		return nil, false
+	}
+
+	// same privilege check as a direct GET on this resource
+	if rh.isAuthorized(privilegesToStrings(redfishResource.PrivilegeMap[HTTP_GET])) != "authorized" {
+		ContextLogger(ctx, "expand").Debug("Not authorized to expand resource", "uri", uri)
+		return nil, false
+	}
+
+	subAuth := &RedfishAuthorizationProperty{
+		UserName:   a.UserName,
+		Privileges: a.Privileges,
+		Licenses:   a.Licenses,
+		Path:       uri,
+		sel:        []string{},
+	}
+
+	NewGet(ctx, redfishResource, &redfishResource.Properties, subAuth)
+	expanded, ok := Flatten(&redfishResource.Properties, false).(map[string]interface{})
+	return expanded, ok
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandSetup(t *testing.T) {
+	var tests = []struct {
+		testname string
+		expand   string
+		levels   string
+		valid    bool
+		typ      string
+		expected int
+	}{
+		{"all", "*", "", true, expandAll, 1},
+		{"subordinate", ".", "", true, expandNoLinks, 1},
+		{"links", "~", "", true, expandLinks, 1},
+		{"levels inline", ".($levels=2)", "", true, expandNoLinks, 2},
+		{"levels separate", "*", "3", true, expandAll, 3},
+		{"levels clamped", "*($levels=100)", "", true, expandAll, MaxExpandLevels},
+		{"empty", "", "", false, "", 0},
+		{"unknown type", "Members", "", false, "", 0},
+		{"unclosed", ".($levels=2", "", false, "", 0},
+		{"other option", ".($top=2)", "", false, "", 0},
+		{"zero levels", ".", "0", false, "", 0},
+		{"bad levels", ".($levels=two)", "", false, "", 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testname, func(t *testing.T) {
+			a := &RedfishAuthorizationProperty{}
+			assert.Equal(t, tc.valid, expandSetup(a, tc.expand, tc.levels))
+			assert.Equal(t, tc.valid, a.doExpand)
+			if tc.valid {
+				assert.Equal(t, tc.typ, a.expand)
+				assert.Equal(t, tc.expected, a.levels)
+			}
+		})
+	}
+}
+
+func TestExpandReferences(t *testing.T) {
+	uri, ok := isODataReference(map[string]interface{}{"@odata.id": "/redfish/v1/Chassis/System.Chassis.1"})
+	assert.True(t, ok)
+	assert.Equal(t, "/redfish/v1/Chassis/System.Chassis.1", uri)
+
+	_, ok = isODataReference(map[string]interface{}{"@odata.id": "/redfish/v1/Chassis/System.Chassis.1", "Name": "chassis"})
+	assert.False(t, ok, "objects with more than the link are already expanded")
+	_, ok = isODataReference(map[string]interface{}{"@odata.id": "/redfish/v1/Chassis/System.Chassis.1/Power#/PowerSupplies/0"})
+	assert.False(t, ok, "fragments can't be expanded on their own")
+	_, ok = isODataReference(map[string]interface{}{"Name": "chassis"})
+	assert.False(t, ok)
+
+	assert.True(t, wantExpand(expandAll, true))
+	assert.True(t, wantExpand(expandAll, false))
+	assert.True(t, wantExpand(expandNoLinks, false))
+	assert.False(t, wantExpand(expandNoLinks, true))
+	assert.True(t, wantExpand(expandLinks, true))
+	assert.False(t, wantExpand(expandLinks, false))
+}
@@ -1,35 +1,15 @@
 package domain
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
-	"time"
 
 	"regexp"
 )
 
-const (
-	BLANK    = 0
-	EQ       = 1
-	LT       = 2
-	GT       = 3
-	GE       = 4
-	LE       = 5
-	CONTAINS = 6
-)
-
-var comparisonString = map[int]string{
-	BLANK:    "",
-	EQ:       " eq ",
-	LT:       " lt ",
-	GT:       " gt ",
-	GE:       " ge ",
-	LE:       " le ",
-	CONTAINS: "contains",
-}
-
 var sevInteger = map[string]int{
 	"Fatal":    4,
 	"Critical": 3,
@@ -37,17 +17,12 @@ var sevInteger = map[string]int{
 	"OK":       1,
 }
 
-type FilterTest struct {
-	Category   string //What field we compare against
-	SearchTerm string //For string search the second term
-	Comparator int    //Operator ==, <, >, >=, <=
-}
-
 func (rh *RedfishHandler) SetupAuthorization(r *http.Request, redfishResource *RedfishResourceAggregate) *RedfishAuthorizationProperty {
 	var err error
 	var qm url.Values
 
-	if r.Method != "GET" {
+	// HEAD has to see the same query options as GET to return the same headers
+	if r.Method != "GET" && r.Method != "HEAD" {
 		auth := &RedfishAuthorizationProperty{
 			UserName:   rh.UserName,
 			Privileges: rh.Privileges,
@@ -76,30 +51,56 @@ func (rh *RedfishHandler) SetupAuthorization(r *http.Request, redfishResource *R
 		Path:       r.URL.Path,
 	}
 
-	auth.top = 50
-	auth.doTop = true
 	auth.sel = []string{}
 
-	// default to top=50 to reduce cpu
+	// big collections get split into pages to reduce cpu
+	auth.pageSize = rh.d.PageSize
+
 	if tstr := qm.Get("$top"); tstr != "" {
 		auth.top, err = strconv.Atoi(tstr)
-		auth.doTop = (err == nil)
+		auth.doTop = (err == nil && auth.top >= 0)
+		if !auth.doTop {
+			auth.queryErrors = append(auth.queryErrors, QueryParameterValueFormatError(tstr, "$top"))
+		}
 	}
 
 	if tstr := qm.Get("$skip"); tstr != "" {
 		auth.skip, err = strconv.Atoi(tstr)
-		auth.doSkip = (err == nil)
+		auth.doSkip = (err == nil && auth.skip >= 0)
+		if !auth.doSkip {
+			auth.queryErrors = append(auth.queryErrors, QueryParameterValueFormatError(tstr, "$skip"))
+		}
+	}
+
+	if tstr := qm.Get("$skiptoken"); tstr != "" {
+		auth.skipToken, err = parseSkipToken(tstr)
+		auth.doSkipToken = (err == nil)
+		if !auth.doSkipToken {
+			auth.queryErrors = append(auth.queryErrors, QueryParameterValueFormatError(tstr, "$skiptoken"))
+		}
 	}
 
 	if tstr := qm.Get("$filter"); tstr != "" {
-		auth.filter = qm.Get("$filter")
-		auth.doFilter = true
+		auth.filter = tstr
+		auth.filterExpr, err = parseFilter(tstr)
+		if err != nil {
+			rh.logger.Info("Bad $filter query", "filter", tstr, "err", err)
+			auth.queryErrors = append(auth.queryErrors, QueryParameterValueFormatError(tstr, "$filter"))
+		} else {
+			auth.doFilter = true
+		}
 	}
 
 	if tstr := qm.Get("$select"); tstr != "" {
 		selectSetup(auth, qm["$select"])
 	}
 
+	if tstr := qm.Get("$expand"); tstr != "" {
+		if !expandSetup(auth, tstr, qm.Get("$levels")) {
+			auth.queryErrors = append(auth.queryErrors, QueryParameterValueFormatError(tstr, "$expand"))
+		}
+	}
+
 	return auth
 
 }
@@ -176,96 +177,26 @@ func removeNegSelects(negSl []string, selSl []string) {
 
 // TODO:  if filter has a query parameter with '$' not supported,  and extended error should be returned with the requested query parameter(s) not supported.
 // RedfishFilter in runmeta would reduce the amount of loops.
-func (rh *RedfishHandler) DoFilter(auth *RedfishAuthorizationProperty, data *HTTPCmdProcessedData) {
+func (rh *RedfishHandler) DoFilter(ctx context.Context, auth *RedfishAuthorizationProperty, data *HTTPCmdProcessedData) {
 	if auth == nil {
 		return
 	}
 
-	if auth.doSkip || auth.doTop || auth.doFilter {
-		data = handleCollectionQueryOptions(auth, data)
+	// $filter needs to see the expanded members, otherwise only expand what survives $skip/$top
+	if auth.doExpand && auth.doFilter {
+		data = rh.handleExpand(ctx, auth, data)
 	}
 
-	if auth.doSel {
-		data = handleSelect(auth, data)
-	}
-}
+	// always run this one, it does the paging for big collections
+	data = handleCollectionQueryOptions(auth, data)
 
-// input is a string with pattern ( val1, val2) or  [ val1, val2 ] or { val1, val2 }
-// return is a list  [ val1, val2 ]
-// quotes and spaces are trimmed from val1 and val2
-func regexGetStrInParanth(val string) ([]string, bool) {
-	m := make([]string, 2)
-	re := regexp.MustCompile(`[\(\[\{](.*)\,(.*)[\)\]\}]`)
-
-	matches := re.FindStringSubmatch(val)
-	if len(matches) == 0 {
-		return m, false
-	}
-
-	val1 := matches[1]
-	val2 := matches[2]
-
-	//cleanup
-	val1 = strings.TrimSpace(val1)
-	val2 = strings.TrimSpace(val2)
-
-	re = regexp.MustCompile(`^['"].*['"]$`)
-	lenStr := len(val1)
-	if re.FindString(val1) != "" {
-		val1 = val1[1 : lenStr-1]
+	if auth.doExpand && !auth.doFilter {
+		data = rh.handleExpand(ctx, auth, data)
 	}
 
-	lenStr = len(val2)
-	if re.FindString(val2) != "" {
-		val2 = val2[1 : lenStr-1]
-	}
-
-	m[0] = val1
-	m[1] = val2
-
-	return m, true
-
-}
-
-// takes a url filter and organizes it in a list of structures
-func createFilterArray(filter string) ([]FilterTest, bool) {
-	filterArray := []FilterTest{}
-	//TODO Right now only working with filter 'and' filter, 'or' is a whole 'nother ballgame
-	splitFilter := strings.Split(filter, " and ")
-	//For whatever filters may have been found, parse them out into a structure we can use
-	// iterates list providing the index and element
-	for _, tok := range splitFilter {
-		//Have a 'token' get the parts of it
-		searchTok := BLANK
-		for k := EQ; k <= CONTAINS; k += 1 {
-			if strings.Contains(tok, comparisonString[k]) {
-				searchTok = k
-				break
-			}
-		}
-		if searchTok == CONTAINS {
-			// matches (<string>, <string>)
-			subSplit, ok := regexGetStrInParanth(tok)
-			if ok {
-				filterArray = append(filterArray, FilterTest{subSplit[0], subSplit[1], CONTAINS})
-			}
-
-		} else if searchTok != BLANK {
-			subSplit := strings.Split(tok, comparisonString[searchTok])
-			if strings.Contains(subSplit[0], "MessageID") {
-				subSplit[0] = "MessageId" //Bug fix to Handle MSM
-			}
-			if subSplit[1] == "Ok" {
-				subSplit[1] = "OK" //Bug fix to Handle MSM
-			}
-			filterArray = append(filterArray, FilterTest{subSplit[0], subSplit[1], searchTok})
-
-		} else {
-			//Filter syntax violation
-			return []FilterTest{}, false
-		}
+	if auth.doSel {
+		data = handleSelect(auth, data)
 	}
-	return filterArray, true
 }
 
 // goes through a layered map (memberInstance) using the list (p) to find the final value
@@ -290,179 +221,46 @@ func getValueWithPath(memberInstance map[string]interface{}, p []string) (interf
 
 }
 
-// looks for string (c) in nested map (memberInstance)
-func getCategoryValue(memberInstance map[string]interface{}, c string) (interface{}, bool) {
-	//Find the  object we're trying to match
-	ok := false
-	var mVal interface{}
-
+// looks for the property path (cL) in nested map (memberInstance)
+func getCategoryValue(memberInstance map[string]interface{}, cL []string) (interface{}, bool) {
 	// for filters providing a path to filter value (like faults)
-	cL := strings.Split(c, "/")
-	mVal, ok = getValueWithPath(memberInstance, cL)
-
+	mVal, ok := getValueWithPath(memberInstance, cL)
 	if ok {
 		return mVal, true
 	}
 
 	// check if this is a log message filter
-	logPathL := []string{"Oem", "Dell", c}
-	mVal, ok = getValueWithPath(memberInstance, logPathL)
+	if len(cL) == 1 {
+		logPathL := []string{"Oem", "Dell", cL[0]}
+		mVal, ok = getValueWithPath(memberInstance, logPathL)
+	}
 
 	return mVal, ok
 }
 
-// returns true if the memberInstance matches with the url filter, stored as filterArray
-func processFilterOneObject(memberInstance map[string]interface{}, filterArray []FilterTest) bool {
-
-	for j := 0; j < len(filterArray); j += 1 {
-		currentMember, rc := getCategoryValue(memberInstance, filterArray[j].Category)
-		if rc == false {
-			return false
-		}
-
-		//Only keep item IF we find at least one term to search against
-		//Reason for this is we keep only things that match all terms, and no match no keep
-		keepElement := false
-		//We could have multiple member types
-		switch localMember := currentMember.(type) {
-		//========= String members =======
-		case string:
-			if filterArray[j].Category == "Severity" {
-				//Severity comparisons string to ENUM sorta
-				memberSev := sevInteger[localMember]
-				searchSev := sevInteger[filterArray[j].SearchTerm]
-				if memberSev > 0 && memberSev < 5 && searchSev > 0 && searchSev < 5 {
-					switch filterArray[j].Comparator {
-					case GE:
-						keepElement = memberSev >= searchSev
-					case GT:
-						keepElement = memberSev > searchSev
-					case LE:
-						keepElement = memberSev <= searchSev
-					case LT:
-						keepElement = memberSev < searchSev
-					case EQ:
-						keepElement = memberSev == searchSev
-					}
-				}
-
-			} else if filterArray[j].Category == "Created" {
-				//Handle time comparisons
-				memberTime, err := time.Parse(time.RFC3339, localMember)
-				if err != nil {
-					//If there is no : in the time adjustment RFC3339 breaks
-					memberTime, err = time.Parse("2006-01-02T15:04:05-0700", localMember)
-				}
-
-				//Use fallback custom parser because of bug in Go time RFC3339 implementation
-				searchTime, err := time.Parse(time.RFC3339, filterArray[j].SearchTerm)
-				if err != nil {
-					//If there is no : in the time adjustment RFC3339 breaks
-					searchTime, err = time.Parse("2006-01-02T15:04:05-0700", filterArray[j].SearchTerm)
-				}
-				if err == nil {
-					//If we were able to parse the input time with one of the methods
-					switch filterArray[j].Comparator {
-					case GE:
-						keepElement = memberTime.Equal(searchTime)
-						if !keepElement {
-							keepElement = memberTime.After(searchTime)
-						}
-					case GT:
-						keepElement = memberTime.After(searchTime)
-					case LE:
-						keepElement = memberTime.Equal(searchTime)
-						if !keepElement {
-							keepElement = memberTime.Before(searchTime)
-						}
-					case LT:
-						keepElement = memberTime.Before(searchTime)
-					case EQ:
-						keepElement = memberTime.Equal(searchTime)
-					}
-				}
-
-			} else {
-				//fmt.Println(localMember, filterArray[j].SearchTerm, strings.Contains(localMember, filterArray[j].SearchTerm))
-				keepElement = strings.Contains(localMember, filterArray[j].SearchTerm)
-			}
-		//========= Integer members =======
-		case int:
-			//Currently no fields like this?
-			searchInt, err := strconv.Atoi(filterArray[j].SearchTerm)
-			if err == nil {
-				switch filterArray[j].Comparator {
-				case GE:
-					keepElement = localMember >= searchInt
-				case GT:
-					keepElement = localMember > searchInt
-				case LE:
-					keepElement = localMember <= searchInt
-				case LT:
-					keepElement = localMember < searchInt
-				case EQ:
-					keepElement = localMember == searchInt
-				}
-			}
-		//========= String array members =======
-		case []interface{}:
-			//For this one, assume we're not going to find it until proven wrong
-			//If we drop out of the loop without finding it, the we don't keep it
-			for b := 0; b < len(localMember) && !keepElement; b += 1 {
-				localMemberStr := localMember[b].(string)
-				keepElement = strings.Contains(localMemberStr, filterArray[j].SearchTerm)
-			}
-		//========= The what?! members =======
-		default:
-			//Most like map[string]interface{}, problem is we don't know how to drill down
-			//Give it a pass
-			keepElement = true
-		}
-		if !keepElement {
-			return false
-		}
+func handleCollectionFilter(filter filterExpr, membersArr []interface{}) []interface{} {
+	if filter == nil {
+		return membersArr
 	}
-	//We get through the loop without any early outs, we have a success
-	return true
-}
 
-func handleCollectionFilter(filter string, membersArr []interface{}) ([]interface{}, bool) {
-
-	filterArray, ok := createFilterArray(filter)
-	//If filter violation return nothing
-	//If empty filters return everything
-	if !ok {
-		return []interface{}{}, ok
-	}
-	if len(filterArray) == 0 {
-		return membersArr, ok
-	}
-	keepArray := []int{}
-
-	//For each element in the log array apply all filters
-	for i := 0; i < len(membersArr); i += 1 {
-		memberInstance, ok := membersArr[i].(map[string]interface{})
+	returnArr := make([]interface{}, 0, len(membersArr))
+	//For each element in the log array apply the filter
+	for _, member := range membersArr {
+		memberInstance, ok := member.(map[string]interface{})
 		if !ok {
-			return membersArr, ok
+			// not something we can filter on, leave the collection alone
+			return membersArr
 		}
-		//Invert logic to determine delete
-		if processFilterOneObject(memberInstance, filterArray) {
-			keepArray = append(keepArray, i)
+		if filter.eval(memberInstance) {
+			returnArr = append(returnArr, member) // preallocated
 		}
-
 	}
 
-	//We have all logs we want to keep in an array, put those in our output
-	returnArr := []interface{}{}
 	//Special case to same some cycles, if we filtered out nothing in the end, just return the original
-	if len(keepArray) == len(membersArr) {
-		returnArr = membersArr
-	} else {
-		for _, index := range keepArray {
-			returnArr = append(returnArr, membersArr[index])
-		}
+	if len(returnArr) == len(membersArr) {
+		return membersArr
 	}
-	return returnArr, ok
+	return returnArr
 }
 
 func handleCollectionQueryOptions(a *RedfishAuthorizationProperty, d *HTTPCmdProcessedData) *HTTPCmdProcessedData {
@@ -487,53 +285,29 @@ func handleCollectionQueryOptions(a *RedfishAuthorizationProperty, d *HTTPCmdPro
 	// Need to make a one-level deep copy to not disturb the original data
 	newResults := map[string]interface{}{}
 	for k, v := range res {
-		// skip 'members', that will be copied separately, next
-		if k == "members" {
+		// skip 'Members', that will be copied separately, next
+		if k == "Members" {
 			continue
 		}
 		newResults[k] = v
 	}
 
 	if a.doFilter {
-		//TODO handle bad filter request with HTTP error
-		//
 		// redfish standard says that filtering changes odata.count
 		// but top and skip do not
-		membersArr, _ = handleCollectionFilter(a.filter, membersArr)
+		membersArr = handleCollectionFilter(a.filterExpr, membersArr)
 	}
 	//Always update count, sometimes it comes out wrong for some reason
 	newResults["Members@odata.count"] = len(membersArr)
 
-	// figure out parameters for the final slice
-	beginning := 0
-	end := len(membersArr)
-
-	if a.doSkip && a.skip > 0 {
-		if a.skip < len(membersArr) {
-			beginning = a.skip
-		} else {
-			beginning = end
-		}
-	}
-
-	if a.doTop && a.top > 0 {
-		if a.top+beginning < len(membersArr) {
-			end = beginning + a.top
-
-			// since we sliced off the end, add a nextlink user can follow to
-			// get the rest (per redfish spec)
-			// we'll be nice and preserve all the original query options
-			a.Query.Set("$skip", strconv.Itoa(a.skip+a.top))
-			a.Query.Set("$top", strconv.Itoa(a.top))
-			nextlink := url.URL{Path: a.Path, RawQuery: a.Encode}
-			newResults["Members@odata.nextlink"] = nextlink.String()
-		}
-	}
-
 	// so we are going to return pointer to the records from the original cached
 	// array. Note that we should have a read lock on this data until it's
 	// serialized to user, so it shouldn't change under us
-	newResults["Members"] = membersArr[beginning:end]
+	page, nextLink := pageMembers(a, membersArr)
+	newResults["Members"] = page
+	if nextLink != "" {
+		newResults["Members@odata.nextLink"] = nextLink
+	}
 
 	d.Results = newResults
 	return d
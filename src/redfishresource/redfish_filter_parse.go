@@ -0,0 +1,653 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OData $filter support
+//
+// Grammar (subset of OData 4.0 used by DSP0266):
+//     expr     := andExpr ( "or" andExpr )*
+//     andExpr  := notExpr ( "and" notExpr )*
+//     notExpr  := "not" notExpr | primary
+//     primary  := "(" expr ")"
+//               | function "(" operand "," operand ")"
+//               | operand [ compareOp operand ]
+//     compareOp:= eq | ne | gt | ge | lt | le
+//     function := contains | startswith | endswith
+//     operand  := property path (Status/Health) | 'string' | number | true | false | null
+//
+// Filters are parsed once when the request comes in, and the resulting tree is
+// evaluated against each collection member.
+
+type filterTokenType int
+
+const (
+	tokEOF filterTokenType = iota
+	tokWord
+	tokString
+	tokNumber
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type filterToken struct {
+	typ filterTokenType
+	val string
+	pos int
+}
+
+func tokenizeFilter(filter string) ([]filterToken, error) {
+	tokens := []filterToken{}
+	i := 0
+	for i < len(filter) {
+		c := filter[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, filterToken{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, filterToken{tokRParen, ")", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, filterToken{tokComma, ",", i})
+			i++
+		case c == '\'' || c == '"':
+			// string literal, quote char is escaped by doubling it
+			start := i
+			i++
+			var sb strings.Builder
+			closed := false
+			for i < len(filter) {
+				if filter[i] == c {
+					if i+1 < len(filter) && filter[i+1] == c {
+						sb.WriteByte(c)
+						i += 2
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(filter[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string starting at position %d", start)
+			}
+			tokens = append(tokens, filterToken{tokString, sb.String(), start})
+		default:
+			start := i
+			for i < len(filter) && isFilterWordChar(filter[i]) {
+				i++
+			}
+			if start == i {
+				return nil, fmt.Errorf("unexpected character '%c' at position %d", c, i)
+			}
+			word := filter[start:i]
+			if _, err := strconv.ParseFloat(word, 64); err == nil {
+				tokens = append(tokens, filterToken{tokNumber, word, start})
+			} else {
+				tokens = append(tokens, filterToken{tokWord, word, start})
+			}
+		}
+	}
+	tokens = append(tokens, filterToken{tokEOF, "", len(filter)})
+	return tokens, nil
+}
+
+// word characters include everything needed for property paths and unquoted
+// timestamps (which some clients send without quotes)
+func isFilterWordChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9') ||
+		strings.IndexByte("_@#/.:-+", c) >= 0
+}
+
+type filterExpr interface {
+	eval(member map[string]interface{}) bool
+}
+
+type filterAnd struct{ left, right filterExpr }
+type filterOr struct{ left, right filterExpr }
+type filterNot struct{ expr filterExpr }
+
+type filterCompare struct {
+	op          string
+	left, right *filterOperand
+}
+
+type filterFunc struct {
+	name        string
+	left, right *filterOperand
+}
+
+// a bare operand used as a boolean, ie: "$filter=ServiceEnabled"
+type filterBool struct{ operand *filterOperand }
+
+type filterOperand struct {
+	path    []string
+	literal interface{}
+	isPath  bool
+	raw     string
+}
+
+func (e *filterAnd) eval(m map[string]interface{}) bool { return e.left.eval(m) && e.right.eval(m) }
+func (e *filterOr) eval(m map[string]interface{}) bool  { return e.left.eval(m) || e.right.eval(m) }
+func (e *filterNot) eval(m map[string]interface{}) bool { return !e.expr.eval(m) }
+
+func (e *filterBool) eval(m map[string]interface{}) bool {
+	v, ok := e.operand.resolve(m, false)
+	b, isBool := v.(bool)
+	return ok && isBool && b
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+var filterCompareOps = map[string]bool{"eq": true, "ne": true, "gt": true, "ge": true, "lt": true, "le": true}
+var filterFunctions = map[string]bool{"contains": true, "startswith": true, "endswith": true}
+
+// parseFilter turns a $filter query string into an expression tree
+func parseFilter(filter string) (filterExpr, error) {
+	tokens, err := tokenizeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 1 {
+		return nil, errors.New("empty filter")
+	}
+
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokEOF {
+		return nil, fmt.Errorf("unexpected '%s' at position %d", t.val, t.pos)
+	}
+	return expr, nil
+}
+
+func (p *filterParser) peek() filterToken { return p.tokens[p.pos] }
+
+func (p *filterParser) next() filterToken {
+	t := p.tokens[p.pos]
+	if t.typ != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *filterParser) peekKeyword(kw string) bool {
+	t := p.peek()
+	return t.typ == tokWord && strings.EqualFold(t.val, kw)
+}
+
+func (p *filterParser) expect(typ filterTokenType, what string) error {
+	t := p.next()
+	if t.typ != typ {
+		if t.typ == tokEOF {
+			return fmt.Errorf("expected %s at end of filter", what)
+		}
+		return fmt.Errorf("expected %s at position %d, got '%s'", what, t.pos, t.val)
+	}
+	return nil
+}
+
+func (p *filterParser) parseOr() (filterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (filterExpr, error) {
+	if p.peekKeyword("not") {
+		p.next()
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &filterNot{expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (filterExpr, error) {
+	t := p.peek()
+
+	if t.typ == tokLParen {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+
+	if t.typ == tokWord && filterFunctions[strings.ToLower(t.val)] && p.tokens[p.pos+1].typ == tokLParen {
+		p.next()
+		p.next()
+		left, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokComma, "','"); err != nil {
+			return nil, err
+		}
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return &filterFunc{name: strings.ToLower(t.val), left: left, right: right}, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	op := p.peek()
+	if op.typ == tokWord && filterCompareOps[strings.ToLower(op.val)] {
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &filterCompare{op: strings.ToLower(op.val), left: left, right: right}, nil
+	}
+
+	if !left.isPath {
+		return nil, fmt.Errorf("expected comparison after '%s' at position %d", t.val, t.pos)
+	}
+	return &filterBool{left}, nil
+}
+
+func (p *filterParser) parseOperand() (*filterOperand, error) {
+	t := p.next()
+	switch t.typ {
+	case tokString:
+		val := t.val
+		if val == "Ok" {
+			val = "OK" //Bug fix to Handle MSM
+		}
+		return &filterOperand{literal: val, raw: t.val}, nil
+	case tokNumber:
+		f, _ := strconv.ParseFloat(t.val, 64)
+		return &filterOperand{literal: f, raw: t.val}, nil
+	case tokWord:
+		switch lw := strings.ToLower(t.val); {
+		case lw == "true":
+			return &filterOperand{literal: true, raw: t.val}, nil
+		case lw == "false":
+			return &filterOperand{literal: false, raw: t.val}, nil
+		case lw == "null":
+			return &filterOperand{literal: nil, raw: t.val}, nil
+		case filterCompareOps[lw] || lw == "and" || lw == "or" || lw == "not":
+			return nil, fmt.Errorf("unexpected operator '%s' at position %d", t.val, t.pos)
+		}
+		path := t.val
+		if strings.Contains(path, "MessageID") {
+			path = strings.Replace(path, "MessageID", "MessageId", -1) //Bug fix to Handle MSM
+		}
+		return &filterOperand{path: strings.Split(path, "/"), isPath: true, raw: t.val}, nil
+	case tokEOF:
+		return nil, errors.New("unexpected end of filter")
+	default:
+		return nil, fmt.Errorf("unexpected '%s' at position %d", t.val, t.pos)
+	}
+}
+
+// resolve looks up the operand value in the member. If the operand is a path
+// that doesn't exist in the member and literalFallback is set, the raw text is
+// used instead. This keeps unquoted values like timestamps working.
+func (o *filterOperand) resolve(m map[string]interface{}, literalFallback bool) (interface{}, bool) {
+	if !o.isPath {
+		return o.literal, true
+	}
+	if v, ok := getCategoryValue(m, o.path); ok {
+		return v, true
+	}
+	if literalFallback {
+		return o.raw, true
+	}
+	return nil, false
+}
+
+func (o *filterOperand) name() string {
+	if !o.isPath || len(o.path) == 0 {
+		return ""
+	}
+	return o.path[len(o.path)-1]
+}
+
+func (e *filterCompare) eval(m map[string]interface{}) bool {
+	l, lok := e.left.resolve(m, false)
+	r, rok := e.right.resolve(m, true)
+	if !rok {
+		return false
+	}
+	if !lok {
+		// a missing property only compares equal to null
+		return (e.op == "eq" && r == nil) || (e.op == "ne" && r != nil)
+	}
+
+	name := e.left.name()
+	if name == "" {
+		name = e.right.name()
+	}
+
+	// collection valued properties match if any of the members match
+	if arr, ok := l.([]interface{}); ok {
+		if e.op == "ne" {
+			for _, item := range arr {
+				if compareFilterValues("eq", name, item, r) {
+					return false
+				}
+			}
+			return true
+		}
+		for _, item := range arr {
+			if compareFilterValues(e.op, name, item, r) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return compareFilterValues(e.op, name, l, r)
+}
+
+func (e *filterFunc) eval(m map[string]interface{}) bool {
+	l, lok := e.left.resolve(m, false)
+	r, rok := e.right.resolve(m, true)
+	if !lok || !rok {
+		return false
+	}
+	needle, ok := r.(string)
+	if !ok {
+		needle = fmt.Sprintf("%v", r)
+	}
+
+	test := func(v interface{}) bool {
+		s, ok := v.(string)
+		if !ok {
+			return false
+		}
+		switch e.name {
+		case "contains":
+			return strings.Contains(s, needle)
+		case "startswith":
+			return strings.HasPrefix(s, needle)
+		case "endswith":
+			return strings.HasSuffix(s, needle)
+		}
+		return false
+	}
+
+	if arr, ok := l.([]interface{}); ok {
+		for _, item := range arr {
+			if test(item) {
+				return true
+			}
+		}
+		return false
+	}
+	return test(l)
+}
+
+// properties whose string values have a defined ordering
+var orderedEnumProperties = map[string]map[string]int{
+	"Severity":     sevInteger,
+	"Health":       sevInteger,
+	"HealthRollup": sevInteger,
+}
+
+func compareFilterValues(op string, name string, l, r interface{}) bool {
+	if l == nil || r == nil {
+		switch op {
+		case "eq":
+			return l == nil && r == nil
+		case "ne":
+			return !(l == nil && r == nil)
+		}
+		return false
+	}
+
+	if lb, ok := l.(bool); ok {
+		rb, ok := r.(bool)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "eq":
+			return lb == rb
+		case "ne":
+			return lb != rb
+		}
+		return false
+	}
+
+	lf, lnum := filterToFloat(l)
+	rf, rnum := filterToFloat(r)
+	if lnum && rnum {
+		return applyCompare(op, compareFloat(lf, rf))
+	}
+
+	ls, lstr := l.(string)
+	rs, rstr := r.(string)
+	if !lstr || !rstr {
+		// number compared against string: try to interpret the string as a number
+		if lnum && rstr {
+			if rf, err := strconv.ParseFloat(rs, 64); err == nil {
+				return applyCompare(op, compareFloat(lf, rf))
+			}
+		}
+		if rnum && lstr {
+			if lf, err := strconv.ParseFloat(ls, 64); err == nil {
+				return applyCompare(op, compareFloat(lf, rf))
+			}
+		}
+		return false
+	}
+
+	if ranks, ok := orderedEnumProperties[name]; ok {
+		lr, lok := ranks[ls]
+		rr, rok := ranks[rs]
+		if lok && rok {
+			return applyCompare(op, lr-rr)
+		}
+	}
+
+	if lt, ok := parseFilterTime(ls); ok {
+		if rt, ok := parseFilterTime(rs); ok {
+			switch {
+			case lt.Before(rt):
+				return applyCompare(op, -1)
+			case lt.After(rt):
+				return applyCompare(op, 1)
+			default:
+				return applyCompare(op, 0)
+			}
+		}
+	}
+
+	return applyCompare(op, strings.Compare(ls, rs))
+}
+
+func applyCompare(op string, cmp int) bool {
+	switch op {
+	case "eq":
+		return cmp == 0
+	case "ne":
+		return cmp != 0
+	case "gt":
+		return cmp > 0
+	case "ge":
+		return cmp >= 0
+	case "lt":
+		return cmp < 0
+	case "le":
+		return cmp <= 0
+	}
+	return false
+}
+
+func compareFloat(l, r float64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	}
+	return 0
+}
+
+func filterToFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func parseFilterTime(s string) (time.Time, bool) {
+	// only bother trying for things that look like a date
+	if len(s) < 10 || s[4] != '-' {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		//If there is no : in the time adjustment RFC3339 breaks
+		t, err = time.Parse("2006-01-02T15:04:05-0700", s)
+	}
+	return t, err == nil
+}
+
+// Filter is a parsed $filter for code that matches its own data instead of
+// collection members, like the SSE stream
+type Filter struct {
+	expr filterExpr
+}
+
+// ParseFilter parses a $filter query string
+func ParseFilter(filter string) (*Filter, error) {
+	expr, err := parseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{expr: expr}, nil
+}
+
+// Matches evaluates the filter against m
+func (f *Filter) Matches(m map[string]interface{}) bool {
+	return f.expr.eval(m)
+}
+
+// Properties returns the property paths the filter uses
+func (f *Filter) Properties() []string {
+	props := []string{}
+	walkFilter(f.expr, func(l, r *filterOperand, op string) {
+		for _, o := range []*filterOperand{l, r} {
+			if o != nil && o.isPath {
+				props = append(props, strings.Join(o.path, "/"))
+			}
+		}
+	})
+	return props
+}
+
+// Compares is true if the filter has "property eq value" anywhere in it. A
+// bare boolean property counts as "property eq true".
+func (f *Filter) Compares(property string, value interface{}) bool {
+	found := false
+	walkFilter(f.expr, func(l, r *filterOperand, op string) {
+		switch op {
+		case "":
+			found = found || (l.isPath && l.raw == property && value == true)
+		case "eq":
+			if !l.isPath {
+				l, r = r, l
+			}
+			found = found || (l.isPath && l.raw == property && !r.isPath && r.literal == value)
+		}
+	})
+	return found
+}
+
+// walkFilter calls fn with the operands of every comparison, function, and
+// bare boolean in the tree. op is "" for bare booleans, which have no right side.
+func walkFilter(e filterExpr, fn func(l, r *filterOperand, op string)) {
+	switch n := e.(type) {
+	case *filterAnd:
+		walkFilter(n.left, fn)
+		walkFilter(n.right, fn)
+	case *filterOr:
+		walkFilter(n.left, fn)
+		walkFilter(n.right, fn)
+	case *filterNot:
+		walkFilter(n.expr, fn)
+	case *filterCompare:
+		fn(n.left, n.right, n.op)
+	case *filterFunc:
+		fn(n.left, n.right, n.name)
+	case *filterBool:
+		fn(n.operand, nil, "")
+	}
+}
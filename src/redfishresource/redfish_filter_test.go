@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	member := map[string]interface{}{
+		"Id":       "1234",
+		"Severity": "Warning",
+		"Created":  "2019-03-01T10:00:00-06:00",
+		"Message":  "The fan is running slow",
+		"Count":    42,
+		"Enabled":  true,
+		"Status":   map[string]interface{}{"Health": "Critical", "State": "Enabled"},
+		"Oem":      map[string]interface{}{"Dell": map[string]interface{}{"Category": "Audit"}},
+		"Tags":     []interface{}{"fan", "thermal"},
+	}
+
+	var tests = []struct {
+		testname string
+		filter   string
+		expected bool
+	}{
+		{"simple eq", "Id eq '1234'", true},
+		{"simple ne", "Id ne '1234'", false},
+		{"nested path", "Status/Health eq 'Critical'", true},
+		{"oem fallback", "Category eq 'Audit'", true},
+		{"severity ordering", "Severity ge 'Warning'", true},
+		{"severity ordering 2", "Severity gt 'Warning'", false},
+		{"health ordering", "Status/Health le 'Warning'", false},
+		{"number", "Count gt 40 and Count le 42", true},
+		{"string with spaces", "Message eq 'The fan is running slow'", true},
+		{"escaped quote", "Message eq 'it''s'", false},
+		{"or", "Id eq 'nope' or Severity eq 'Warning'", true},
+		{"not", "not (Id eq '1234')", false},
+		{"parentheses", "(Id eq 'nope' or Count eq 42) and not Status/State eq 'Disabled'", true},
+		{"contains", "contains(Message, 'running')", true},
+		{"startswith", "startswith(Message, 'fan')", false},
+		{"endswith", "endswith(Message, 'slow')", true},
+		{"array", "Tags eq 'thermal'", true},
+		{"unquoted time", "Created ge 2019-01-01T00:00:00-06:00", true},
+		{"quoted time", "Created lt '2019-01-01T00:00:00-06:00'", false},
+		{"bool", "Enabled eq true", true},
+		{"bare bool", "not Enabled", false},
+		{"missing property", "Missing eq 'x'", false},
+		{"missing property null", "Missing eq null", true},
+		{"msm MessageID", "MessageID eq 'x'", false},
+	}
+	for _, subtest := range tests {
+		t.Run(subtest.testname, func(t *testing.T) {
+			expr, err := parseFilter(subtest.filter)
+			assert.Nil(t, err)
+			if err == nil {
+				assert.Equal(t, subtest.expected, expr.eval(member))
+			}
+		})
+	}
+}
+
+func TestFilterSyntaxErrors(t *testing.T) {
+	for _, filter := range []string{
+		"",
+		"Id eq",
+		"Id eq 'unterminated",
+		"(Id eq '1'",
+		"Id eq '1')",
+		"contains(Id '1')",
+		"Id eq '1' and",
+		"eq '1'",
+		"'1'",
+	} {
+		t.Run(filter, func(t *testing.T) {
+			_, err := parseFilter(filter)
+			assert.NotNil(t, err)
+		})
+	}
+}
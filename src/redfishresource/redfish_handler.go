@@ -1,7 +1,9 @@
 package domain
 
 import (
+	"bytes"
 	"context"
+	"io/ioutil"
 	"net/http"
 	"strconv"
 	"strings"
@@ -68,6 +70,61 @@ outer:
 	return
 }
 
+// validateRequest checks JSON request bodies against the schema for the
+// resource, and writes the error response if it fails. The body is buffered so
+// that the command can still parse it afterwards.
+func (rh *RedfishHandler) validateRequest(w http.ResponseWriter, r *http.Request, redfishResource *RedfishResourceAggregate) bool {
+	if rh.d.Validator == nil || (r.Method != "PATCH" && r.Method != "PUT" && r.Method != "POST") {
+		return true
+	}
+
+	// leave uploads and other non-json bodies alone
+	ct := r.Header.Get("Content-Type")
+	if ct != "" && !strings.Contains(ct, "json") {
+		return true
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, UnrecognizedRequestBody())
+		return false
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	// malformed bodies are reported by the command parsers like they always have been
+	body := map[string]interface{}{}
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(b, &body); err != nil {
+		return true
+	}
+
+	if errs := rh.d.Validator.ValidateRequest(r.Method, redfishResource.Type, body, redfishResource.Properties.HasPatchMeta); len(errs) > 0 {
+		rh.logger.Info("Request body failed schema validation", "url", r.URL.Path, "type", redfishResource.Type)
+		WriteErrorResponse(w, http.StatusBadRequest, errs...)
+		return false
+	}
+	return true
+}
+
+// convert Privileges from []interface{} to []string (way more code than there should be for something this simple)
+func privilegesToStrings(privsToCheck interface{}) []string {
+	var t []string
+	switch privs := privsToCheck.(type) {
+	case []string:
+		t = make([]string, 0, len(privs))
+		t = append(t, privs...) // preallocated
+	case []interface{}:
+		t = make([]string, 0, len(privs))
+		for _, v := range privs {
+			if a, ok := v.(string); ok {
+				t = append(t, a) // preallocated
+			}
+		}
+	default:
+		t = make([]string, 0, 0)
+	}
+	return t
+}
+
 func (rh *RedfishHandler) verifyLocationURL(reqCtx context.Context, url string) bool {
 
 	// check the existance early to avoid setting up listener.
@@ -131,7 +188,7 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	aggID, ok := rh.d.GetAggregateIDOK(r.URL.Path)
 	if !ok {
 		rh.logger.Warn("Could not find URL", "url", r.URL.Path)
-		http.Error(w, "Could not find URL: "+r.URL.Path, http.StatusNotFound)
+		WriteErrorResponse(w, http.StatusNotFound, ResourceMissingAtURI(r.URL.Path))
 		return
 	}
 
@@ -151,6 +208,14 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// long version for backwards compat (old style)
 	search = append(search, eh.CommandType("http:RedfishResource:"+r.Method)) // preallocated
 
+	// per DSP0266, methods the resource doesn't support get a 405 along with what it does support
+	if ok && !redfishResource.MethodAllowed(MapStringToHTTPReq(r.Method)) {
+		rh.logger.Warn("Method not allowed", "url", r.URL.Path, "method", r.Method)
+		w.Header().Set("Allow", strings.Join(redfishResource.AllowedMethods(), ", "))
+		WriteErrorResponse(w, http.StatusMethodNotAllowed, ActionNotSupported(r.Method))
+		return
+	}
+
 	// search through the commands until we find one that exists
 	var cmd eh.Command
 	for _, cmdType := range search {
@@ -163,7 +228,7 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// with a proper error if we couldnt create a command of any kind
 	if cmd == nil {
 		rh.logger.Warn("could not create command", "url", r.URL.Path)
-		http.Error(w, "could not create command", http.StatusBadRequest)
+		WriteErrorResponse(w, http.StatusBadRequest, ActionNotSupported(r.Method))
 		return
 	}
 
@@ -188,55 +253,21 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	// if command does not implement userdetails setter, we always check privs here
 	if !implementsAuthorization || authAction == "checkMaster" {
-		privsToCheck := redfishResource.PrivilegeMap[MapStringToHTTPReq(r.Method)]
-
-		// convert Privileges from []interface{} to []string (way more code than there should be for something this simple)
-		var t []string
-		switch privs := privsToCheck.(type) {
-		case []string:
-			t = make([]string, 0, len(privs))
-			t = append(t, privs...) // preallocated
-		case []interface{}:
-			t = make([]string, 0, len(privs))
-			for _, v := range privs {
-				if a, ok := v.(string); ok {
-					t = append(t, a) // preallocated
-				}
-			}
-		default:
-			t = make([]string, 0, 0)
-		}
-
-		authAction = rh.isAuthorized(t)
+		privsToCheck := redfishResource.PrivilegesFor(MapStringToHTTPReq(r.Method))
+		authAction = rh.isAuthorized(privilegesToStrings(privsToCheck))
 	}
 
 	if authAction != "authorized" {
 		rh.logger.Warn("Not authorized to access this resource.", "url", r.URL.Path)
-		http.Error(w, "Not authorized to access this resource: ", http.StatusMethodNotAllowed)
+		WriteErrorResponse(w, http.StatusForbidden, InsufficientPrivilege())
 		return
 	}
 
-	// for intial implementation of etags, we will check etags right here. we may need to move this around later. For example, the command might need to handle it
-	// TODO: this all has to happen after the privilege check
-	if match := r.Header.Get("If-None-Match"); match != "" {
-		e := getResourceEtag(reqCtx, redfishResource, auth)
-		if e != "" {
-			if match == e {
-				w.WriteHeader(http.StatusNotModified)
-				return
-			}
-		}
-	}
-
-	// TODO: If-Match must be able to match comma separated list
-	if match := r.Header.Get("If-Match"); match != "" {
-		e := getResourceEtag(reqCtx, redfishResource, auth)
-		if e != "" {
-			if match != e {
-				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
-				return
-			}
-		}
+	// bad query options fail the whole request, before anything is changed
+	if len(auth.queryErrors) > 0 {
+		rh.logger.Warn("Bad query options", "url", r.URL.Path, "query", r.URL.RawQuery)
+		WriteErrorResponse(w, http.StatusBadRequest, auth.queryErrors...)
+		return
 	}
 
 	// to avoid races, set up our listener first
@@ -253,7 +284,7 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	})
 	if err != nil {
 		rh.logger.Warn("could not create waiter", "err", err.Error(), "url", r.URL.Path)
-		http.Error(w, "could not create waiter"+err.Error(), http.StatusInternalServerError)
+		WriteErrorResponse(w, http.StatusInternalServerError, InternalError())
 		return
 	}
 
@@ -262,20 +293,27 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// don't run parse until after privilege checks have been done
 	defer r.Body.Close()
+	if !rh.validateRequest(w, r, redfishResource) {
+		return
+	}
+
 	if t, ok := cmd.(HTTPParser); ok {
 		err := t.ParseHTTPRequest(r)
 		if err != nil {
 			rh.logger.Warn("Problems parsing http request: ", "err", err.Error(), "url", r.URL.Path)
-			http.Error(w, "Problems parsing http request: "+err.Error(), http.StatusBadRequest)
+			WriteErrorResponse(w, http.StatusBadRequest, MalformedJSON())
 			return
 		}
 	}
 
 	ctx := WithRequestID(context.Background(), cmdID)
 
+	// If-Match/If-None-Match are checked against the current ETag of the resource when the command runs
+	cmd = newConditionalRequest(cmd, r, cmdID, auth)
+
 	if err := rh.d.CommandHandler.HandleCommand(ctx, cmd); err != nil {
 		rh.logger.Warn("redfish handler could not handle command", "type", string(cmd.CommandType()), "err", err.Error(), "url", r.URL.Path, "resource", redfishResource, "cmd", cmd)
-		http.Error(w, "redfish handler could not handle command (type: "+string(cmd.CommandType())+"): "+err.Error(), http.StatusBadRequest)
+		WriteErrorResponse(w, http.StatusBadRequest, GeneralError())
 		return
 	}
 
@@ -288,7 +326,7 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case event = <-l.Inbox():
 	case <-reqCtx.Done():
 		rh.logger.Warn("Request cancelled, aborting http response", "url", r.URL.Path)
-		http.Error(w, "Request cancelled, aborting http response", http.StatusInternalServerError)
+		WriteErrorResponse(w, http.StatusInternalServerError, InternalError())
 		return
 	}
 
@@ -300,12 +338,14 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	data, ok := event.Data().(*HTTPCmdProcessedData)
 	if !ok {
 		rh.logger.Warn("Did not get an HTTPCmdProcessedData event, that's wierd.", "url", r.URL.Path, "event", event.Data())
-		http.Error(w, "Did not get an HTTPCmdProcessedData event, that's wierd", http.StatusInternalServerError)
+		WriteErrorResponse(w, http.StatusInternalServerError, InternalError())
 		return
 	}
 
 	// filter redfish data
-	rh.DoFilter(auth, data)
+	if data.StatusCode != http.StatusNotModified {
+		rh.DoFilter(reqCtx, auth, data)
+	}
 
 	// set headers first
 	w.Header().Set("OData-Version", "4.0")
@@ -326,7 +366,10 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// compatibility headers
 	w.Header().Set("X-UA-Compatible", "IE=11")
 
-	addEtag(w, data)
+	if r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS" {
+		w.Header().Set("Allow", strings.Join(redfishResource.AllowedMethods(), ", "))
+		addLink(w, data)
+	}
 
 	// check if k has 'location' and if so check if URI exists, and if not add a new listener
 	// and wait for it to show up
@@ -338,10 +381,6 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add(k, v)
 	}
 
-	if data.StatusCode != 0 {
-		w.WriteHeader(data.StatusCode)
-	}
-
 	/*
 		   // START
 		   // STREAMING ENCODE TO OUTPUT (not possible to get content length)
@@ -367,12 +406,26 @@ func (rh *RedfishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		rh.logger.Warn("Error encoding JSON for output: ", "err", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		WriteErrorResponse(w, http.StatusInternalServerError, InternalError())
 		return
 	}
+
+	// OPTIONS and Not Modified only have headers
+	if r.Method == "OPTIONS" || data.StatusCode == http.StatusNotModified {
+		b = []byte{}
+	}
+
+	// headers have to be complete before WriteHeader()
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
-	w.Write(b)
+	if data.StatusCode != 0 {
+		w.WriteHeader(data.StatusCode)
+	}
+
+	// HEAD gets the same headers as GET, including Content-Length, but no body
+	if r.Method != "HEAD" {
+		w.Write(b)
+	}
 	// END
 
 	return
@@ -410,23 +463,24 @@ func getResourceEtag(ctx context.Context, agg *RedfishResourceAggregate, auth *R
 	return etagstr
 }
 
-func addEtag(w http.ResponseWriter, d *HTTPCmdProcessedData) *HTTPCmdProcessedData {
+// addLink adds the Link header pointing at the schema for the resource type, derived from @odata.type
+func addLink(w http.ResponseWriter, d *HTTPCmdProcessedData) *HTTPCmdProcessedData {
 	res, ok := d.Results.(map[string]interface{})
 	if !ok {
-		// no way it has an etag, return
 		return d
 	}
 
-	etag, ok := res["@odata.etag"]
+	typ, ok := res["@odata.type"].(string)
 	if !ok {
-		// no etag
 		return d
 	}
 
-	etagStr, ok := etag.(string)
-	if ok {
-		w.Header().Add("Etag", etagStr)
+	// "#Chassis.v1_6_0.Chassis" is described by "Chassis_v1.xml"
+	namespace := strings.SplitN(strings.TrimPrefix(typ, "#"), ".", 2)[0]
+	if namespace == "" {
+		return d
 	}
+	w.Header().Set("Link", "</schemas/v1/"+namespace+"_v1.xml>; rel=describedby")
 
 	return d
 }
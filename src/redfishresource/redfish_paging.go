@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultPageSize is how many members a collection returns before it is split
+// into pages with Members@odata.nextLink, unless DomainObjects.PageSize says otherwise.
+const DefaultPageSize = 50
+
+// A $skiptoken remembers the @odata.id of the last member on the previous page
+// along with its position. The next page starts right after that member, so
+// entries added while a client is paging through don't shift the pages. The
+// position is only used if the member went away in the meantime.
+type skipToken struct {
+	offset int
+	after  string
+}
+
+func (t skipToken) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(t.offset) + ":" + t.after))
+}
+
+func parseSkipToken(s string) (skipToken, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return skipToken{}, err
+	}
+	parts := strings.SplitN(string(b), ":", 2)
+	if len(parts) != 2 {
+		return skipToken{}, errors.New("malformed skiptoken")
+	}
+	offset, err := strconv.Atoi(parts[0])
+	if err != nil || offset < 0 {
+		return skipToken{}, errors.New("malformed skiptoken")
+	}
+	return skipToken{offset: offset, after: parts[1]}, nil
+}
+
+// resume returns the index of the first member of the page the token points to
+func (t skipToken) resume(members []interface{}) int {
+	if t.after != "" {
+		for i, m := range members {
+			if memberID(m) == t.after {
+				return i + 1
+			}
+		}
+	}
+	if t.offset > len(members) {
+		return len(members)
+	}
+	return t.offset
+}
+
+func memberID(member interface{}) string {
+	m, ok := member.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	id, _ := m["@odata.id"].(string)
+	return id
+}
+
+// pageMembers applies $skip/$skiptoken, $top, and the server page size to the
+// (already filtered) members. It returns the slice to send back and, if there is
+// more to come, the nextLink for the rest.
+func pageMembers(a *RedfishAuthorizationProperty, members []interface{}) ([]interface{}, string) {
+	beginning := 0
+	switch {
+	case a.doSkipToken:
+		beginning = a.skipToken.resume(members)
+	case a.doSkip && a.skip > 0:
+		beginning = a.skip
+		if beginning > len(members) {
+			beginning = len(members)
+		}
+	}
+
+	// what the client asked for
+	end := len(members)
+	if a.doTop && beginning+a.top < end {
+		end = beginning + a.top
+	}
+
+	// what we are willing to send in one go
+	if a.pageSize <= 0 || end-beginning <= a.pageSize {
+		return members[beginning:end], ""
+	}
+	pageEnd := beginning + a.pageSize
+
+	// keep the rest of the query (like $filter and $select) for the next page.
+	// $skip was used up getting here, and $top counts down what's left.
+	q := url.Values{}
+	for k, v := range a.Query {
+		q[k] = v
+	}
+	q.Del("$skip")
+	if a.doTop {
+		q.Set("$top", strconv.Itoa(end-pageEnd))
+	}
+	q.Set("$skiptoken", skipToken{offset: pageEnd, after: memberID(members[pageEnd-1])}.encode())
+
+	nextLink := url.URL{Path: a.Path, RawQuery: q.Encode()}
+	return members[beginning:pageEnd], nextLink.String()
+}
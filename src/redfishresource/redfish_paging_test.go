@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageMembers(t *testing.T) {
+	// newest first, like the log collections
+	makeMembers := func(newest, oldest int) []interface{} {
+		members := []interface{}{}
+		for i := newest; i >= oldest; i-- {
+			members = append(members, map[string]interface{}{"@odata.id": "/redfish/v1/Logs/" + strconv.Itoa(i)})
+		}
+		return members
+	}
+	ids := func(members []interface{}) []string {
+		ret := []string{}
+		for _, m := range members {
+			ret = append(ret, memberID(m))
+		}
+		return ret
+	}
+	follow := func(t *testing.T, nextLink string) *RedfishAuthorizationProperty {
+		u, err := url.Parse(nextLink)
+		assert.Nil(t, err)
+		a := &RedfishAuthorizationProperty{Path: u.Path, Query: u.Query(), pageSize: 3}
+		if tok := a.Query.Get("$skiptoken"); tok != "" {
+			a.skipToken, err = parseSkipToken(tok)
+			a.doSkipToken = (err == nil)
+		}
+		if top := a.Query.Get("$top"); top != "" {
+			a.top, _ = strconv.Atoi(top)
+			a.doTop = true
+		}
+		return a
+	}
+
+	t.Run("small collection is not paged", func(t *testing.T) {
+		a := &RedfishAuthorizationProperty{Path: "/redfish/v1/Logs", Query: url.Values{}, pageSize: 3}
+		page, next := pageMembers(a, makeMembers(3, 1))
+		assert.Len(t, page, 3)
+		assert.Equal(t, "", next)
+	})
+
+	t.Run("pages resume after entries are added", func(t *testing.T) {
+		a := &RedfishAuthorizationProperty{Path: "/redfish/v1/Logs", Query: url.Values{"$filter": []string{"Severity eq 'OK'"}}, pageSize: 3}
+		page, next := pageMembers(a, makeMembers(7, 1))
+		assert.Equal(t, []string{"/redfish/v1/Logs/7", "/redfish/v1/Logs/6", "/redfish/v1/Logs/5"}, ids(page))
+		assert.Contains(t, next, "%24filter=")
+
+		// two new entries show up at the front
+		page, next = pageMembers(follow(t, next), makeMembers(9, 1))
+		assert.Equal(t, []string{"/redfish/v1/Logs/4", "/redfish/v1/Logs/3", "/redfish/v1/Logs/2"}, ids(page))
+
+		page, next = pageMembers(follow(t, next), makeMembers(9, 1))
+		assert.Equal(t, []string{"/redfish/v1/Logs/1"}, ids(page))
+		assert.Equal(t, "", next)
+	})
+
+	t.Run("client top and skip", func(t *testing.T) {
+		a := &RedfishAuthorizationProperty{Path: "/redfish/v1/Logs", Query: url.Values{"$skip": []string{"1"}, "$top": []string{"5"}}, pageSize: 3, skip: 1, doSkip: true, top: 5, doTop: true}
+		page, next := pageMembers(a, makeMembers(10, 1))
+		assert.Equal(t, []string{"/redfish/v1/Logs/9", "/redfish/v1/Logs/8", "/redfish/v1/Logs/7"}, ids(page))
+		assert.NotContains(t, next, "%24skip=")
+
+		page, next = pageMembers(follow(t, next), makeMembers(10, 1))
+		assert.Equal(t, []string{"/redfish/v1/Logs/6", "/redfish/v1/Logs/5"}, ids(page))
+		assert.Equal(t, "", next)
+	})
+
+	t.Run("anchor gone falls back to the offset", func(t *testing.T) {
+		a := &RedfishAuthorizationProperty{Path: "/redfish/v1/Logs", Query: url.Values{}, pageSize: 2}
+		_, next := pageMembers(a, makeMembers(6, 1))
+		b := follow(t, next)
+		b.pageSize = 2
+
+		page, _ := pageMembers(b, makeMembers(10, 7))
+		assert.Equal(t, []string{"/redfish/v1/Logs/8", "/redfish/v1/Logs/7"}, ids(page))
+
+		page, next = pageMembers(b, makeMembers(1, 1))
+		assert.Len(t, page, 0)
+		assert.Equal(t, "", next)
+	})
+
+	_, err := parseSkipToken("not a token")
+	assert.NotNil(t, err)
+}
@@ -15,13 +15,13 @@ type RedfishAuthorizationProperty struct {
 	Licenses   []string
 	Query      url.Values
 	Path       string
-	Encode     string
 
 	// pass the supported query options to the backend
 	// re-arranged to hopefully be more memory efficient
 	skip       int
 	top        int
 	filter     string
+	filterExpr filterExpr
 	sel        []string
 	selT       bool
 	doFilter   bool
@@ -32,6 +32,16 @@ type RedfishAuthorizationProperty struct {
 	skipDone   bool
 	doSel      bool
 	selDone    bool
+	expand     string
+	levels     int
+	doExpand   bool
+
+	// server driven paging
+	skipToken   skipToken
+	doSkipToken bool
+	pageSize    int
+
+	queryErrors []ExtendedInfo
 }
 
 type RedfishResourceProperty struct {
@@ -57,6 +67,35 @@ func (rrp *RedfishResourceProperty) RUnlock() {
 	rrp.lock.RUnlock()
 }
 
+// HasPatchMeta reports if a PATCH plugin is attached to the property at the
+// given path (like "Status/Health") or to anything above it.
+func (rrp *RedfishResourceProperty) HasPatchMeta(path string) bool {
+	rrp.RLock()
+	defer rrp.RUnlock()
+
+	if _, ok := rrp.Meta["PATCH"]; ok {
+		return true
+	}
+	if path == "" {
+		return false
+	}
+
+	v, ok := rrp.Value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	segments := strings.SplitN(path, "/", 2)
+	child, ok := v[segments[0]].(*RedfishResourceProperty)
+	if !ok {
+		return false
+	}
+	rest := ""
+	if len(segments) > 1 {
+		rest = segments[1]
+	}
+	return child.HasPatchMeta(rest)
+}
+
 func (rrp *RedfishResourceProperty) Parse(thing interface{}) {
 	rrp.Lock()
 	defer rrp.Unlock()
@@ -83,9 +122,9 @@ func (rrp *RedfishResourceProperty) ParseUnlocked(thing interface{}) {
 				name := k.String()[:len(k.String())-5]
 				newEntry := &RedfishResourceProperty{}
 				if vMap, ok := rv.Interface().(map[string]interface{}); ok {
-					if newEntry.Value, ok = vMap["DEFAULT"]; ok {
-						delete(vMap, "DEFAULT")
-					}
+					// DEFAULT stays in the meta so that PUT can reset the property, the
+					// value gets its own copy so updates in place don't change it
+					newEntry.ParseUnlocked(vMap["DEFAULT"])
 					newEntry.Meta = vMap
 					v[name] = newEntry
 				}